@@ -0,0 +1,88 @@
+package application
+
+import (
+	"time"
+
+	batchConfig "github.com/cam-inc/mxtransporter/config/batch"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultBatchFlushInterval bounds how long a partial batch waits for more events,
+// so that it and its resume token are not held until the next event arrives.
+const defaultBatchFlushInterval = time.Second
+
+// changeStreamsBatch collects change events until one of the configured limits is reached.
+// Without any configuration every event is flushed on its own, which is the original behavior.
+type changeStreamsBatch struct {
+	maxEvents     int
+	maxBytes      int
+	flushInterval time.Duration
+	flushTimer    *time.Timer
+	css           []primitive.M
+	bytes         int
+}
+
+func newChangeStreamsBatch(cfg batchConfig.Batch) *changeStreamsBatch {
+	maxEvents := cfg.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = 1
+	}
+	flushInterval := time.Duration(cfg.FlushIntervalMSec) * time.Millisecond
+	if flushInterval <= 0 && (maxEvents > 1 || cfg.MaxBytes > 0) {
+		flushInterval = defaultBatchFlushInterval
+	}
+	return &changeStreamsBatch{
+		maxEvents:     maxEvents,
+		maxBytes:      cfg.MaxBytes,
+		flushInterval: flushInterval,
+	}
+}
+
+func (b *changeStreamsBatch) add(cs primitive.M) {
+	if b.empty() && b.flushInterval > 0 {
+		b.flushTimer = time.NewTimer(b.flushInterval)
+	}
+	b.css = append(b.css, cs)
+	if b.maxBytes <= 0 {
+		return
+	}
+	// The size is only an estimate of the payload, so a marshal failure is left to the exporters to report.
+	if raw, err := bson.Marshal(cs); err == nil {
+		b.bytes += len(raw)
+	}
+}
+
+func (b *changeStreamsBatch) full() bool {
+	if len(b.css) >= b.maxEvents {
+		return true
+	}
+	return b.maxBytes > 0 && b.bytes >= b.maxBytes
+}
+
+func (b *changeStreamsBatch) empty() bool {
+	return len(b.css) == 0
+}
+
+// expired fires when the flush interval has passed since the first event of the batch was added.
+// It returns nil, which blocks forever in a select, while there is no pending batch or every event is flushed on its own.
+func (b *changeStreamsBatch) expired() <-chan time.Time {
+	if b.flushTimer == nil {
+		return nil
+	}
+	return b.flushTimer.C
+}
+
+func (b *changeStreamsBatch) reset() {
+	if b.flushTimer != nil {
+		b.flushTimer.Stop()
+		b.flushTimer = nil
+	}
+	b.css = nil
+	b.bytes = 0
+}
+
+// lastResumeToken returns the resume token of the newest event in the batch.
+func (b *changeStreamsBatch) lastResumeToken() string {
+	return b.css[len(b.css)-1]["_id"].(primitive.M)["_data"].(string)
+}
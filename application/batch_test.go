@@ -0,0 +1,115 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"testing"
+	"time"
+
+	batchConfig "github.com/cam-inc/mxtransporter/config/batch"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_changeStreamsBatch(t *testing.T) {
+	cs := func(rt string) primitive.M {
+		return primitive.M{
+			"_id":           primitive.M{"_data": rt},
+			"operationType": "insert",
+			"fullDocument":  primitive.M{"xxxxx": "test full document"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Every event is full without configuration.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{})
+				b.add(cs("00000"))
+				if !b.full() {
+					t.Fatalf("Expected the batch to be full after one event.")
+				}
+				if b.expired() != nil {
+					t.Fatalf("Expected no flush timer without flush interval.")
+				}
+			},
+		},
+		{
+			name: "Full by max events.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{MaxEvents: 2})
+				b.add(cs("00000"))
+				if b.full() {
+					t.Fatalf("Expected the batch not to be full after one event.")
+				}
+				b.add(cs("00001"))
+				if !b.full() {
+					t.Fatalf("Expected the batch to be full after two events.")
+				}
+				if e, a := "00001", b.lastResumeToken(); e != a {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Full by max bytes.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{MaxEvents: 100, MaxBytes: 1})
+				b.add(cs("00000"))
+				if !b.full() {
+					t.Fatalf("Expected the batch to be full by bytes.")
+				}
+			},
+		},
+		{
+			name: "Expired by flush interval and reset.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{MaxEvents: 100, FlushIntervalMSec: 1})
+				b.add(cs("00000"))
+				select {
+				case <-b.expired():
+				case <-time.After(time.Second):
+					t.Fatalf("Expected the flush timer to fire.")
+				}
+				b.reset()
+				if !b.empty() || b.expired() != nil {
+					t.Fatalf("Expected the batch to be reset.")
+				}
+			},
+		},
+		{
+			name: "A partial batch is flushed by the default flush interval.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{MaxEvents: 10})
+				if e, a := defaultBatchFlushInterval, b.flushInterval; e != a {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+				b.add(cs("00000"))
+				if b.full() {
+					t.Fatalf("Expected the batch not to be full after one event.")
+				}
+				select {
+				case <-b.expired():
+				case <-time.After(defaultBatchFlushInterval + time.Second):
+					t.Fatalf("Expected the single event to be flushed by the default flush interval.")
+				}
+			},
+		},
+		{
+			name: "The default flush interval applies to max bytes as well.",
+			runner: func(t *testing.T) {
+				b := newChangeStreamsBatch(batchConfig.Batch{MaxBytes: 1048576})
+				if e, a := defaultBatchFlushInterval, b.flushInterval; e != a {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
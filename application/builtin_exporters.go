@@ -0,0 +1,241 @@
+package application
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/pubsub"
+	"github.com/cam-inc/mxtransporter/config"
+	bqconfig "github.com/cam-inc/mxtransporter/config/bigquery"
+	ksconfig "github.com/cam-inc/mxtransporter/config/kinesis-stream"
+	pconfig "github.com/cam-inc/mxtransporter/config/pubsub"
+	interfaceForBigquery "github.com/cam-inc/mxtransporter/interfaces/bigquery"
+	iff "github.com/cam-inc/mxtransporter/interfaces/file"
+	interfaceForKinesisStream "github.com/cam-inc/mxtransporter/interfaces/kinesis-stream"
+	interfaceForPubsub "github.com/cam-inc/mxtransporter/interfaces/pubsub"
+	"github.com/cam-inc/mxtransporter/pkg/client"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func init() {
+	RegisterExporter(string(BigQuery), newBigqueryExporter)
+	RegisterExporter(string(CloudPubSub), newPubsubExporter)
+	RegisterExporter(string(KinesisStream), newKinesisStreamExporter)
+	RegisterExporter(string(File), newFileExporter)
+}
+
+type (
+	bigqueryExporter struct {
+		client *bigquery.Client
+		bq     interfaceForBigquery.BigqueryImpl
+	}
+
+	pubsubExporter struct {
+		client *pubsub.Client
+		pubsub interfaceForPubsub.PubsubImpl
+	}
+
+	kinesisStreamExporter struct {
+		kinesisStream interfaceForKinesisStream.KinesisStreamImpl
+	}
+
+	fileExporter struct {
+		fileExporter iff.Exporter
+	}
+)
+
+func newBigqueryExporter(ctx context.Context, _ *zap.SugaredLogger) (Exporter, error) {
+	canonical, err := encoder.CanonicalExtJSON(string(BigQuery))
+	if err != nil {
+		return nil, err
+	}
+	bqCfg := bqconfig.BigqueryConfig()
+	dataset, err := naming.Parse(bqCfg.DataSet)
+	if err != nil {
+		return nil, err
+	}
+	table, err := naming.Parse(bqCfg.Table)
+	if err != nil {
+		return nil, err
+	}
+	projectID, err := config.FetchGcpProject()
+	if err != nil {
+		return nil, err
+	}
+	bqClient, err := client.NewBigqueryClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	bqClientImpl := &interfaceForBigquery.BigqueryClientImpl{BqClient: bqClient}
+	return &bigqueryExporter{
+		client: bqClient,
+		bq:     interfaceForBigquery.BigqueryImpl{Bq: bqClientImpl, CanonicalExtJSON: canonical, Dataset: dataset, Table: table},
+	}, nil
+}
+
+func (*bigqueryExporter) Init(_ context.Context) error {
+	return nil
+}
+
+func (e *bigqueryExporter) Export(ctx context.Context, css []primitive.M) error {
+	return e.bq.ExportToBigquery(ctx, css)
+}
+
+func (*bigqueryExporter) Flush(_ context.Context) error {
+	return nil
+}
+
+func (e *bigqueryExporter) Close(_ context.Context) error {
+	return e.client.Close()
+}
+
+func newPubsubExporter(ctx context.Context, log *zap.SugaredLogger) (Exporter, error) {
+	enc, err := encoder.New(string(CloudPubSub))
+	if err != nil {
+		return nil, err
+	}
+	psCfg := pconfig.PubSubConfig()
+	attrs, err := interfaceForPubsub.ParseAttributes(psCfg.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := naming.Parse(psCfg.TopicName)
+	if err != nil {
+		return nil, err
+	}
+	orderingBy, err := interfaceForPubsub.ParseOrderingBy(psCfg.OrderingBy)
+	if err != nil {
+		return nil, err
+	}
+	projectID, err := config.FetchGcpProject()
+	if err != nil {
+		return nil, err
+	}
+	psClient, err := client.NewPubsubClient(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	psClientImpl := &interfaceForPubsub.PubsubClientImpl{
+		PubsubClient: psClient,
+		Log:          log,
+		Settings:     interfaceForPubsub.NewPublishSettings(psCfg),
+		Ordering:     psCfg.OrderingBy != "",
+	}
+	return &pubsubExporter{
+		client: psClient,
+		pubsub: interfaceForPubsub.PubsubImpl{
+			Pubsub:     psClientImpl,
+			Log:        log,
+			Topic:      topic,
+			OrderingBy: orderingBy,
+			Encoder:    enc,
+			Attributes: attrs,
+		},
+	}, nil
+}
+
+func (e *pubsubExporter) Init(ctx context.Context) error {
+	return e.pubsub.Init(ctx)
+}
+
+func (e *pubsubExporter) Export(ctx context.Context, css []primitive.M) error {
+	return e.pubsub.ExportToPubsub(ctx, css)
+}
+
+func (e *pubsubExporter) Flush(ctx context.Context) error {
+	return e.pubsub.Flush(ctx)
+}
+
+func (e *pubsubExporter) Close(_ context.Context) error {
+	e.pubsub.Close()
+	return e.client.Close()
+}
+
+func newKinesisStreamExporter(ctx context.Context, log *zap.SugaredLogger) (Exporter, error) {
+	enc, err := encoder.New(string(KinesisStream))
+	if err != nil {
+		return nil, err
+	}
+	if err := encoder.WithoutAttributes(enc); err != nil {
+		return nil, err
+	}
+	ksCfg := ksconfig.KinesisStreamConfig()
+	stream, err := naming.Parse(ksCfg.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	partitionKey, err := interfaceForKinesisStream.ParsePartitionKey(ksCfg.PartitionKey)
+	if err != nil {
+		return nil, err
+	}
+	if partitionKey.SingleShard() {
+		log.Warnf("KINESIS_PARTITION_KEY is %s, which puts every record into one shard and limits the throughput to that of a shard.", ksCfg.PartitionKey)
+	}
+	ksClient, err := client.NewKinesisClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ksClientImpl := &interfaceForKinesisStream.KinesisStreamClientImpl{KinesisStreamClient: ksClient}
+	return &kinesisStreamExporter{
+		kinesisStream: interfaceForKinesisStream.KinesisStreamImpl{
+			KinesisStream:             ksClientImpl,
+			Encoder:                   enc,
+			Stream:                    stream,
+			Retry:                     interfaceForKinesisStream.NewRetryPolicy(ksCfg),
+			PartitionKey:              partitionKey,
+			SequenceNumberForOrdering: ksCfg.SequenceNumberForOrdering,
+		},
+	}, nil
+}
+
+func (*kinesisStreamExporter) Init(_ context.Context) error {
+	return nil
+}
+
+func (e *kinesisStreamExporter) Export(ctx context.Context, css []primitive.M) error {
+	return e.kinesisStream.ExportToKinesisStream(ctx, css)
+}
+
+func (e *kinesisStreamExporter) Flush(ctx context.Context) error {
+	return e.kinesisStream.Flush(ctx)
+}
+
+func (*kinesisStreamExporter) Close(_ context.Context) error {
+	return nil
+}
+
+func newFileExporter(_ context.Context, _ *zap.SugaredLogger) (Exporter, error) {
+	canonical, err := encoder.CanonicalExtJSON(string(File))
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := encoder.Envelope(string(File))
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.FileExportConfig()
+	cfg.CanonicalExtJSON = canonical
+	cfg.Envelope = envelope
+	return &fileExporter{
+		fileExporter: iff.New(cfg),
+	}, nil
+}
+
+func (*fileExporter) Init(_ context.Context) error {
+	return nil
+}
+
+func (e *fileExporter) Export(ctx context.Context, css []primitive.M) error {
+	return e.fileExporter.Export(ctx, css)
+}
+
+func (*fileExporter) Flush(_ context.Context) error {
+	return nil
+}
+
+func (e *fileExporter) Close(_ context.Context) error {
+	return e.fileExporter.Close()
+}
@@ -0,0 +1,61 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeStreamsOptions are the options of the change streams which do not change on reconnection.
+type changeStreamsOptions struct {
+	fullDocument             options.FullDocument
+	fullDocumentBeforeChange options.FullDocument
+}
+
+func newChangeStreamsOptions(cfg mongodb.ChangeStream) (changeStreamsOptions, error) {
+	// updateLookup is the default, since MxTransporter has always exported the full document of updates.
+	o := changeStreamsOptions{
+		fullDocument:             options.UpdateLookup,
+		fullDocumentBeforeChange: options.Off,
+	}
+
+	switch fd := options.FullDocument(cfg.FullDocument); fd {
+	case "":
+	case options.Default, options.UpdateLookup, options.WhenAvailable, options.Required:
+		o.fullDocument = fd
+	default:
+		return changeStreamsOptions{}, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("CHANGE_STREAM_FULL_DOCUMENT must be default, updateLookup, whenAvailable or required. you set %s", cfg.FullDocument))
+	}
+
+	// Pre-images need MongoDB 6.0 or later, and changeStreamPreAndPostImages enabled on the collections.
+	switch fd := options.FullDocument(cfg.FullDocumentBeforeChange); fd {
+	case "":
+	case options.Off, options.WhenAvailable, options.Required:
+		o.fullDocumentBeforeChange = fd
+	default:
+		return changeStreamsOptions{}, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("CHANGE_STREAM_FULL_DOCUMENT_BEFORE_CHANGE must be off, whenAvailable or required. you set %s", cfg.FullDocumentBeforeChange))
+	}
+
+	return o, nil
+}
+
+// at returns the options to open the change streams from pos.
+func (o changeStreamsOptions) at(pos changeStreamsPosition) *options.ChangeStreamOptions {
+	ops := options.ChangeStream().SetFullDocument(o.fullDocument)
+	// off is not sent, so that servers older than 6.0 accept the options.
+	if o.fullDocumentBeforeChange != options.Off {
+		ops.SetFullDocumentBeforeChange(o.fullDocumentBeforeChange)
+	}
+	if pos.resumeToken != "" {
+		var rt interface{} = map[string]string{"_data": pos.resumeToken}
+		ops.SetResumeAfter(rt)
+	} else if pos.startAfter != "" {
+		var rt interface{} = map[string]string{"_data": pos.startAfter}
+		ops.SetStartAfter(rt)
+	} else if pos.startAt != nil {
+		ops.SetStartAtOperationTime(pos.startAt)
+	}
+	return ops
+}
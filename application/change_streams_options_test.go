@@ -0,0 +1,61 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func Test_newChangeStreamsOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to look up the full document of updates without pre-images by default.",
+			runner: func(t *testing.T) {
+				o, err := newChangeStreamsOptions(mongodb.ChangeStream{})
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				ops := o.at(changeStreamsPosition{resumeToken: "00000"})
+				if *ops.FullDocument != options.UpdateLookup || ops.FullDocumentBeforeChange != nil || ops.ResumeAfter == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to open change streams with the configured full document modes.",
+			runner: func(t *testing.T) {
+				o, err := newChangeStreamsOptions(mongodb.ChangeStream{FullDocument: "whenAvailable", FullDocumentBeforeChange: "required"})
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				ops := o.at(changeStreamsPosition{startAt: &primitive.Timestamp{T: 1}})
+				if *ops.FullDocument != options.WhenAvailable || *ops.FullDocumentBeforeChange != options.Required || ops.StartAtOperationTime == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by full document modes which are wrong.",
+			runner: func(t *testing.T) {
+				if _, err := newChangeStreamsOptions(mongodb.ChangeStream{FullDocument: "off"}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if _, err := newChangeStreamsOptions(mongodb.ChangeStream{FullDocumentBeforeChange: "updateLookup"}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
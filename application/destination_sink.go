@@ -0,0 +1,214 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultMaxLagBatches = 10
+
+// sinkBatch is a batch of change streams with its sequence number in the change streams.
+type sinkBatch struct {
+	seq         uint64
+	css         []primitive.M
+	resumeToken string
+}
+
+// lowWatermark commits the resume token of the change streams up to the batch every destination has exported,
+// from which the change streams are reopened.
+type lowWatermark struct {
+	mu        sync.Mutex
+	acked     map[string]uint64
+	tokens    map[uint64]string
+	committed uint64
+	commit    func(rt string) error
+}
+
+func newLowWatermark(dsts []exportDestination, commit func(rt string) error) *lowWatermark {
+	w := &lowWatermark{
+		acked:  make(map[string]uint64, len(dsts)),
+		tokens: map[uint64]string{},
+		commit: commit,
+	}
+	for _, dst := range dsts {
+		w.acked[dst.name] = 0
+	}
+	return w
+}
+
+func (w *lowWatermark) add(b sinkBatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tokens[b.seq] = b.resumeToken
+}
+
+// ack records that dst has exported the batch, and commits the resume token if every destination has exported it.
+func (w *lowWatermark) ack(dst string, seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.acked[dst] = seq
+
+	low := seq
+	for _, s := range w.acked {
+		if s < low {
+			low = s
+		}
+	}
+	if low <= w.committed {
+		return nil
+	}
+	// The batch stays uncommitted until the resume token is saved, so that acking it again retries the save.
+	if err := w.commit(w.tokens[low]); err != nil {
+		return err
+	}
+	for s := range w.tokens {
+		if s <= low {
+			delete(w.tokens, s)
+		}
+	}
+	w.committed = low
+	return nil
+}
+
+// destinationSinks export the batches to each destination independently. A destination can be behind the others
+// by up to maxLag batches, after which reading change streams waits for it.
+type destinationSinks struct {
+	ctx       context.Context
+	eg        *errgroup.Group
+	queues    map[string]chan sinkBatch
+	watermark *lowWatermark
+	seq       uint64
+}
+
+func (c *ChangeStreamsExporterImpl) startSinks(ctx context.Context, dsts []exportDestination, maxLag int) *destinationSinks {
+	if maxLag <= 0 {
+		maxLag = defaultMaxLagBatches
+	}
+	eg, sctx := errgroup.WithContext(ctx)
+	s := &destinationSinks{
+		ctx:    sctx,
+		eg:     eg,
+		queues: make(map[string]chan sinkBatch, len(dsts)),
+		watermark: newLowWatermark(dsts, func(rt string) error {
+			return c.exporter.saveResumeToken(ctx, rt)
+		}),
+	}
+	for _, dst := range dsts {
+		dst := dst
+		q := make(chan sinkBatch, maxLag)
+		s.queues[dst.name] = q
+		// The change streams are reopened from the resume token of the slowest destination, so the others skip what they have exported.
+		after := c.exporter.destinationResumeToken(ctx, dst.name)
+		// A failing destination retries in its own sink, so it holds only its queue and not the exports of the others.
+		eg.Go(func() error {
+			return c.runSink(ctx, dst, q, after, s.watermark)
+		})
+	}
+	return s
+}
+
+// push queues the batch for every destination. It waits while the queue of a destination is full, and fails when a sink has stopped.
+func (s *destinationSinks) push(css []primitive.M, rt string) error {
+	s.seq++
+	b := sinkBatch{seq: s.seq, css: css, resumeToken: rt}
+	s.watermark.add(b)
+	for _, q := range s.queues {
+		select {
+		case q <- b:
+		case <-s.ctx.Done():
+			return s.wait()
+		}
+	}
+	return nil
+}
+
+// failed is closed when a sink has stopped, e.g. by the shutdown timeout. It blocks forever with a single destination, which has no sinks.
+func (s *destinationSinks) failed() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.ctx.Done()
+}
+
+// wait waits until every destination has exported the queued batches.
+func (s *destinationSinks) wait() error {
+	if s == nil {
+		return nil
+	}
+	for name, q := range s.queues {
+		close(q)
+		delete(s.queues, name)
+	}
+	return s.eg.Wait()
+}
+
+// runSink exports the batches to dst in order. The change streams up to after, which dst has already exported before
+// a restart while the other destinations had not, are skipped.
+// A batch which fails is retried with the backoff of dst until it succeeds or ctx is done. Meanwhile the other destinations
+// go on until they are the queue size ahead of dst, after which reading change streams waits for it.
+func (c *ChangeStreamsExporterImpl) runSink(ctx context.Context, dst exportDestination, batches <-chan sinkBatch, after string, w *lowWatermark) error {
+	for b := range batches {
+		css := b.css
+		if after != "" {
+			css = skipExported(css, after)
+			if len(css) > 0 {
+				after = ""
+			}
+		}
+
+		// The steps done are not repeated by the retries, so that a failed save does not export the batch again.
+		exported, saved := len(css) == 0, len(css) == 0
+		sink := func() error {
+			if !exported {
+				if err := c.exportToDestination(ctx, dst, css); err != nil {
+					return err
+				}
+				exported = true
+			}
+			if !saved {
+				if err := c.exporter.saveDestinationResumeToken(ctx, dst.name, b.resumeToken); err != nil {
+					return err
+				}
+				saved = true
+			}
+			return w.ack(dst.name, b.seq)
+		}
+		for attempt := 1; ; attempt++ {
+			err := sink()
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			wait := dst.retryPolicy.Backoff(attempt)
+			c.log.Errorf("Failed to export change streams to %s, retrying the batch in %s while the other destinations go on: %v", dst.name, wait, err)
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return err
+			case <-t.C:
+			}
+		}
+	}
+	return nil
+}
+
+// skipExported drops the change streams up to the resume token after.
+// It assumes that the _data of resume tokens, the hex string of the KeyString format starting with the cluster time,
+// is ordered as strings in the order of the change streams of a deployment. It is how servers encode resume tokens
+// rather than a documented guarantee, so the change streams are not skipped from one whose resume token has no _data.
+func skipExported(css []primitive.M, after string) []primitive.M {
+	for i, cs := range css {
+		id, _ := cs["_id"].(primitive.M)
+		if rt, ok := id["_data"].(string); !ok || rt == "" || rt > after {
+			return css[i:]
+		}
+	}
+	return nil
+}
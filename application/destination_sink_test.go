@@ -0,0 +1,205 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_destinationSinks(t *testing.T) {
+	ctx := context.Background()
+	l := logger.New(config.LogConfig())
+
+	event := func(rt string) primitive.M {
+		return primitive.M{"_id": primitive.M{"_data": rt}, "operationType": "insert"}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to save the resume token of each destination and the one every destination has exported.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_DESTINATION, "bigquery,pubsub")
+				mockExporterClient := &mockChangeStreamsExporterClientImpl{
+					cs:           event("00001"),
+					csCursorFlag: true,
+				}
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := map[string]string{"bigquery": "00001", "pubsub": "00001"}
+				if !reflect.DeepEqual(mockExporterClient.destinationResumeTokens, want) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, mockExporterClient.destinationResumeTokens)
+				}
+				if !reflect.DeepEqual(mockExporterClient.savedResumeTokens, []string{"00001"}) {
+					t.Fatalf("Testing Error, ErrorMessage: the resume token is not saved once, got %v.", mockExporterClient.savedResumeTokens)
+				}
+			},
+		},
+		{
+			name: "Pass to retry a failing destination without stopping the others or saving the resume token before it exports.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_DESTINATION, "bigquery,pubsub")
+				mockExporterClient := &mockChangeStreamsExporterClientImpl{
+					cs:                   event("00001"),
+					csCursorFlag:         true,
+					failDestination:      "bigquery",
+					failDestinationTimes: 2,
+				}
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockExporterClient.failedExports != 2 || mockExporterClient.bqPassCheck != "OK" {
+					t.Fatalf("Testing Error, ErrorMessage: bigquery is not retried, got %d failures.", mockExporterClient.failedExports)
+				}
+				want := map[string]string{"bigquery": "00001", "pubsub": "00001"}
+				if !reflect.DeepEqual(mockExporterClient.destinationResumeTokens, want) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, mockExporterClient.destinationResumeTokens)
+				}
+				if !reflect.DeepEqual(mockExporterClient.savedResumeTokens, []string{"00001"}) {
+					t.Fatalf("Testing Error, ErrorMessage: the resume token is not saved once, got %v.", mockExporterClient.savedResumeTokens)
+				}
+			},
+		},
+		{
+			name: "Pass to go on exporting to the others while a destination keeps failing.",
+			runner: func(t *testing.T) {
+				mockExporterClient := &mockChangeStreamsExporterClientImpl{failDestination: "bigquery"}
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				dsts := []exportDestination{exporter.newExportDestination("bigquery"), exporter.newExportDestination("pubsub")}
+				sctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				sinks := exporter.startSinks(sctx, dsts, 2)
+				for _, rt := range []string{"00001", "00002"} {
+					if err := sinks.push([]primitive.M{event(rt)}, rt); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+				}
+				deadline := time.Now().Add(5 * time.Second)
+				for exporter.exporter.destinationResumeToken(ctx, "pubsub") != "00002" {
+					if time.Now().After(deadline) {
+						t.Fatalf("Testing Error, ErrorMessage: pubsub is stopped by bigquery.")
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				select {
+				case <-sinks.failed():
+					t.Fatalf("Testing Error, ErrorMessage: the sinks are stopped by bigquery.")
+				default:
+				}
+
+				cancel()
+				if err := sinks.wait(); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if len(mockExporterClient.savedResumeTokens) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: the resume token is saved before bigquery exports, got %v.", mockExporterClient.savedResumeTokens)
+				}
+			},
+		},
+		{
+			name: "Pass to skip the change streams a destination has exported before a restart.",
+			runner: func(t *testing.T) {
+				mockExporterClient := &mockChangeStreamsExporterClientImpl{
+					destinationResumeTokens: map[string]string{"bigquery": "00001", "pubsub": "00002"},
+				}
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				dsts := []exportDestination{exporter.newExportDestination("bigquery"), exporter.newExportDestination("pubsub")}
+				sinks := exporter.startSinks(ctx, dsts, 1)
+				if err := sinks.push([]primitive.M{event("00002")}, "00002"); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := sinks.wait(); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockExporterClient.exportCount != 1 || mockExporterClient.bqPassCheck != "OK" || mockExporterClient.pubsubPassCheck != "" {
+					t.Fatalf("Testing Error, ErrorMessage: the exported change stream is exported again to pubsub.")
+				}
+				if !reflect.DeepEqual(mockExporterClient.savedResumeTokens, []string{"00002"}) {
+					t.Fatalf("Testing Error, ErrorMessage: the resume token is not saved, got %v.", mockExporterClient.savedResumeTokens)
+				}
+			},
+		},
+		{
+			name: "Pass to stop skipping at a change stream whose resume token has no _data.",
+			runner: func(t *testing.T) {
+				noData := primitive.M{"_id": primitive.M{}, "operationType": "insert"}
+				css := []primitive.M{event("00001"), noData, event("00001")}
+				if got := skipExported(css, "00001"); len(got) != 2 || !reflect.DeepEqual(got[0], noData) {
+					t.Fatalf("Testing Error, ErrorMessage: got %v.", got)
+				}
+				if got := skipExported([]primitive.M{event("00001"), event("00002")}, "00001"); len(got) != 1 {
+					t.Fatalf("Testing Error, ErrorMessage: got %v.", got)
+				}
+			},
+		},
+		{
+			name: "Pass to commit the resume token of the batch the slowest destination has exported.",
+			runner: func(t *testing.T) {
+				var committed []string
+				w := newLowWatermark([]exportDestination{{name: "bigquery"}, {name: "pubsub"}}, func(rt string) error {
+					committed = append(committed, rt)
+					return nil
+				})
+				for i, rt := range []string{"00001", "00002", "00003"} {
+					w.add(sinkBatch{seq: uint64(i + 1), resumeToken: rt})
+				}
+				acks := []struct {
+					dst string
+					seq uint64
+				}{{"pubsub", 1}, {"pubsub", 2}, {"pubsub", 3}, {"bigquery", 2}, {"bigquery", 3}}
+				for _, a := range acks {
+					if err := w.ack(a.dst, a.seq); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+				}
+				if want := []string{"00002", "00003"}; !reflect.DeepEqual(committed, want) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, committed)
+				}
+			},
+		},
+		{
+			name: "Pass to commit the resume token again by the ack after the commit fails.",
+			runner: func(t *testing.T) {
+				var committed []string
+				fail := true
+				w := newLowWatermark([]exportDestination{{name: "bigquery"}}, func(rt string) error {
+					if fail {
+						fail = false
+						return fmt.Errorf("storage error")
+					}
+					committed = append(committed, rt)
+					return nil
+				})
+				w.add(sinkBatch{seq: 1, resumeToken: "00001"})
+				if err := w.ack("bigquery", 1); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if err := w.ack("bigquery", 1); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if want := []string{"00001"}; !reflect.DeepEqual(committed, want) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, committed)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -4,21 +4,22 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
-	"cloud.google.com/go/bigquery"
-	"cloud.google.com/go/pubsub"
-	"github.com/aws/aws-sdk-go-v2/service/kinesis"
 	"github.com/cam-inc/mxtransporter/config"
-	pconfig "github.com/cam-inc/mxtransporter/config/pubsub"
-	interfaceForBigquery "github.com/cam-inc/mxtransporter/interfaces/bigquery"
-	iff "github.com/cam-inc/mxtransporter/interfaces/file"
-	interfaceForKinesisStream "github.com/cam-inc/mxtransporter/interfaces/kinesis-stream"
+	batchConfig "github.com/cam-inc/mxtransporter/config/batch"
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	retryConfig "github.com/cam-inc/mxtransporter/config/retry"
+	routingConfig "github.com/cam-inc/mxtransporter/config/routing"
+	snapshotConfig "github.com/cam-inc/mxtransporter/config/snapshot"
 	mongoConnection "github.com/cam-inc/mxtransporter/interfaces/mongo"
-	interfaceForPubsub "github.com/cam-inc/mxtransporter/interfaces/pubsub"
-	"github.com/cam-inc/mxtransporter/pkg/client"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/retry"
+	idl "github.com/cam-inc/mxtransporter/usecases/dead-letter"
+	"github.com/cam-inc/mxtransporter/usecases/redaction"
 	irt "github.com/cam-inc/mxtransporter/usecases/resume-token"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -37,19 +38,21 @@ const (
 
 type (
 	changeStreamsWatcher interface {
-		newBigqueryClient(ctx context.Context, projectID string) (*bigquery.Client, error)
-		newPubsubClient(ctx context.Context, projectID string) (*pubsub.Client, error)
-		newKinesisClient(ctx context.Context) (*kinesis.Client, error)
 		watch(ctx context.Context, ops *options.ChangeStreamOptions) (*mongo.ChangeStream, error)
-		newFileClient(ctx context.Context) (iff.Exporter, error)
+		newExporter(ctx context.Context, name string, log *zap.SugaredLogger) (Exporter, error)
 		setCsExporter(exporter ChangeStreamsExporterImpl)
 		exportChangeStreams(ctx context.Context) error
+		oldestOplogTime(ctx context.Context) (primitive.Timestamp, error)
+		clusterTime(ctx context.Context) (primitive.Timestamp, error)
+		snapshotSplitPoints(ctx context.Context, n int) ([]bson.RawValue, error)
+		scanSnapshot(ctx context.Context, r snapshotRange) (*mongo.Cursor, error)
 	}
 
 	ChangeStreamsWatcherImpl struct {
 		Watcher            changeStreamsWatcher
 		Log                *zap.SugaredLogger
 		resumeTokenManager irt.ResumeToken
+		redactor           redaction.Redactor
 	}
 
 	ChangeStreamsWatcherClientImpl struct {
@@ -58,40 +61,32 @@ type (
 	}
 )
 
-func (*ChangeStreamsWatcherClientImpl) newBigqueryClient(ctx context.Context, projectID string) (*bigquery.Client, error) {
-	bqClient, err := client.NewBigqueryClient(ctx, projectID)
-	if err != nil {
-		return nil, err
-	}
-	return bqClient, nil
+func (*ChangeStreamsWatcherClientImpl) newExporter(ctx context.Context, name string, log *zap.SugaredLogger) (Exporter, error) {
+	return newExporter(ctx, name, log)
 }
 
-func (*ChangeStreamsWatcherClientImpl) newPubsubClient(ctx context.Context, projectID string) (*pubsub.Client, error) {
-	psClient, err := client.NewPubsubClient(ctx, projectID)
+func (c *ChangeStreamsWatcherClientImpl) watch(ctx context.Context, ops *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	cs, err := mongoConnection.Watch(ctx, c.MongoClient, ops)
 	if err != nil {
 		return nil, err
 	}
-	return psClient, nil
+	return cs, nil
 }
 
-func (*ChangeStreamsWatcherClientImpl) newKinesisClient(ctx context.Context) (*kinesis.Client, error) {
-	ksClient, err := client.NewKinesisClient(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return ksClient, nil
+func (c *ChangeStreamsWatcherClientImpl) oldestOplogTime(ctx context.Context) (primitive.Timestamp, error) {
+	return mongoConnection.OldestOplogTime(ctx, c.MongoClient)
 }
 
-func (*ChangeStreamsWatcherClientImpl) newFileClient(_ context.Context) (iff.Exporter, error) {
-	return iff.New(config.FileExportConfig()), nil
+func (c *ChangeStreamsWatcherClientImpl) clusterTime(ctx context.Context) (primitive.Timestamp, error) {
+	return mongoConnection.ClusterTime(ctx, c.MongoClient)
 }
 
-func (c *ChangeStreamsWatcherClientImpl) watch(ctx context.Context, ops *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
-	cs, err := mongoConnection.Watch(ctx, c.MongoClient, ops)
-	if err != nil {
-		return nil, err
-	}
-	return cs, nil
+func (c *ChangeStreamsWatcherClientImpl) snapshotSplitPoints(ctx context.Context, n int) ([]bson.RawValue, error) {
+	return mongoConnection.SnapshotSplitPoints(ctx, c.MongoClient, n)
+}
+
+func (c *ChangeStreamsWatcherClientImpl) scanSnapshot(ctx context.Context, r snapshotRange) (*mongo.Cursor, error) {
+	return mongoConnection.ScanSnapshot(ctx, c.MongoClient, r.Min, r.Max, r.Last)
 }
 
 func (c *ChangeStreamsWatcherClientImpl) setCsExporter(exporter ChangeStreamsExporterImpl) {
@@ -115,21 +110,34 @@ func (c *ChangeStreamsWatcherImpl) WatchChangeStreams(ctx context.Context) error
 		c.resumeTokenManager = rtImpl
 	}
 
-	rt := c.resumeTokenManager.ReadResumeToken(ctx)
-	ops := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	csCfg := mongodb.ChangeStreamConfig()
+	csOpts, err := newChangeStreamsOptions(csCfg)
+	if err != nil {
+		return err
+	}
+	rc, err := newReconnector(csCfg, c.Log)
+	if err != nil {
+		return err
+	}
+	snapshotCfg := snapshotConfig.SnapshotConfig()
+	snapshotMode, err := parseSnapshotMode(snapshotCfg.Mode)
+	if err != nil {
+		return err
+	}
+
+	rt := strings.TrimRight(c.resumeTokenManager.ReadResumeToken(ctx), "\n")
 
 	if len(rt) == 0 {
 		c.Log.Info("File saved resume token in is not exists. Get from the current change streams.")
-	} else {
-		var rt interface{} = map[string]string{"_data": strings.TrimRight(rt, "\n")}
-
-		ops.SetResumeAfter(rt)
 	}
 
-	cs, err := c.Watcher.watch(ctx, ops)
+	pos, err := startPosition(csCfg, rt, c.Log)
 	if err != nil {
 		return err
 	}
+	if err := c.validateOplogWindow(ctx, pos); err != nil {
+		return err
+	}
 
 	expDst, err := config.FetchExportDestination()
 	if err != nil {
@@ -138,60 +146,103 @@ func (c *ChangeStreamsWatcherImpl) WatchChangeStreams(ctx context.Context) error
 
 	expDstList := strings.Split(expDst, ",")
 
-	projectID, err := config.FetchGcpProject()
-	if err != nil && (strings.Contains(expDst, string(BigQuery)) || strings.Contains(expDst, string(CloudPubSub))) {
+	router, err := newRouter(routingConfig.RoutingConfig(), expDstList)
+	if err != nil {
 		return err
 	}
 
-	var (
-		bqImpl interfaceForBigquery.BigqueryImpl
-		psImpl interfaceForPubsub.PubsubImpl
-		ksImpl interfaceForKinesisStream.KinesisStreamImpl
-		fe     iff.Exporter
-	)
-
+	exporters := make(map[string]Exporter, len(expDstList))
+	// The exporters are closed on shutdown too, so ctx is not used for it.
+	defer closeExporters(context.WithoutCancel(ctx), c.Log, exporters)
 	for i := 0; i < len(expDstList); i++ {
 		eDst := expDstList[i]
-		switch agent(eDst) {
-		case BigQuery:
-			bqClient, err := c.Watcher.newBigqueryClient(ctx, projectID)
-			if err != nil {
-				return err
-			}
-			bqClientImpl := &interfaceForBigquery.BigqueryClientImpl{bqClient}
-			bqImpl = interfaceForBigquery.BigqueryImpl{bqClientImpl}
-		case CloudPubSub:
-			psClient, err := c.Watcher.newPubsubClient(ctx, projectID)
-			if err != nil {
-				return err
-			}
-			psClientImpl := &interfaceForPubsub.PubsubClientImpl{psClient, c.Log}
-			psImpl = interfaceForPubsub.PubsubImpl{psClientImpl, c.Log, pconfig.PubSubConfig().OrderingBy}
-		case KinesisStream:
-			ksClient, err := c.Watcher.newKinesisClient(ctx)
-			if err != nil {
-				return err
-			}
-			ksClientImpl := &interfaceForKinesisStream.KinesisStreamClientImpl{ksClient}
-			ksImpl = interfaceForKinesisStream.KinesisStreamImpl{ksClientImpl}
-		case File:
-			fCli, err := c.Watcher.newFileClient(ctx)
-			if err != nil {
-				return err
+		exporter, err := c.Watcher.newExporter(ctx, eDst, c.Log)
+		if err != nil {
+			return err
+		}
+		if err := exporter.Init(ctx); err != nil {
+			return err
+		}
+		exporter, err = withProjection(exporter, eDst)
+		if err != nil {
+			return err
+		}
+		// The change streams are routed before the projection, so that the routes match the fields the projection removes.
+		exporters[eDst] = withRouting(exporter, eDst, router)
+	}
+
+	redactor, err := redaction.New()
+	if err != nil {
+		return err
+	}
+	c.redactor = redactor
+
+	deadLetter, err := idl.New(ctx, c.Log)
+	if err != nil {
+		return err
+	}
+	if deadLetter != nil {
+		defer deadLetter.Close()
+	}
+
+	// The snapshot is taken only when change streams would start from the current time, i.e. at the first start.
+	if snapshotMode == snapshotInitial && rt == "" && pos == (changeStreamsPosition{}) {
+		startAt, err := c.snapshot(ctx, snapshotCfg, exporters, deadLetter)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.Log.Info("Stopped the snapshot by shutdown.")
+				return nil
 			}
-			fe = fCli
-		default:
-			return errors.InternalServerError.Wrap("The export destination is wrong.", fmt.Errorf("you need to set the export destination in the environment variable correctly. you set %s", eDst))
+			return err
 		}
+		pos = changeStreamsPosition{startAt: startAt}
+	}
+
+	for {
+		lastRT, err := c.watchAndExport(ctx, csOpts.at(pos), exporters, deadLetter)
+		if lastRT != "" {
+			pos = changeStreamsPosition{resumeToken: lastRT}
+			rc.progressed()
+		}
+		if err == nil {
+			return nil
+		}
+
+		var wait time.Duration
+		pos, wait, err = rc.reconnect(err, pos)
+		if err != nil {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// watchAndExport opens the change streams with ops and exports them until they stop.
+// It returns the resume token of the last exported change stream, or an empty string if none is exported.
+func (c *ChangeStreamsWatcherImpl) watchAndExport(ctx context.Context, ops *options.ChangeStreamOptions, exporters map[string]Exporter, deadLetter idl.DeadLetter) (string, error) {
+	cs, err := c.Watcher.watch(ctx, ops)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Shut down while opening the change streams.
+			return "", nil
+		}
+		return "", errors.InternalServerErrorChangeStreams.Wrap("Failed to open change streams.", err)
 	}
 
 	exporterClient := &changeStreamsExporterClientImpl{
-		cs:            cs,
-		bq:            bqImpl,
-		pubsub:        psImpl,
-		kinesisStream: ksImpl,
-		fileExporter:  fe,
-		resumeToken:   c.resumeTokenManager,
+		cs:          cs,
+		exporters:   exporters,
+		resumeToken: c.resumeTokenManager,
+		deadLetter:  deadLetter,
+		redactor:    c.redactor,
+		resumed:     ops.ResumeAfter != nil,
 	}
 	exporter := ChangeStreamsExporterImpl{
 		exporter: exporterClient,
@@ -200,11 +251,16 @@ func (c *ChangeStreamsWatcherImpl) WatchChangeStreams(ctx context.Context) error
 
 	c.Watcher.setCsExporter(exporter)
 
-	if err := c.Watcher.exportChangeStreams(ctx); err != nil {
-		return err
-	}
+	err = c.Watcher.exportChangeStreams(ctx)
+	return exporterClient.lastResumeToken, err
+}
 
-	return nil
+func closeExporters(ctx context.Context, log *zap.SugaredLogger, exporters map[string]Exporter) {
+	for dst, exporter := range exporters {
+		if err := exporter.Close(ctx); err != nil {
+			log.Error(errors.InternalServerError.Wrap(fmt.Sprintf("Failed to close %s exporter.", dst), err))
+		}
+	}
 }
 
 type (
@@ -212,11 +268,12 @@ type (
 		next(ctx context.Context) bool
 		decode() (primitive.M, error)
 		close(ctx context.Context) error
-		exportToBigquery(ctx context.Context, cs primitive.M) error
-		exportToPubsub(ctx context.Context, cs primitive.M) error
-		exportToKinesisStream(ctx context.Context, cs primitive.M) error
-		exportToFile(ctx context.Context, cs primitive.M) error
+		export(ctx context.Context, dst string, css []primitive.M) error
 		saveResumeToken(ctx context.Context, rt string) error
+		flushResumeToken(ctx context.Context) error
+		destinationResumeToken(ctx context.Context, dst string) string
+		saveDestinationResumeToken(ctx context.Context, dst string, rt string) error
+		sendToDeadLetter(ctx context.Context, dst string, cs primitive.M, cause error) error
 		err() error
 	}
 
@@ -226,12 +283,16 @@ type (
 	}
 
 	changeStreamsExporterClientImpl struct {
-		cs            *mongo.ChangeStream
-		bq            interfaceForBigquery.BigqueryImpl
-		pubsub        interfaceForPubsub.PubsubImpl
-		kinesisStream interfaceForKinesisStream.KinesisStreamImpl
-		fileExporter  iff.Exporter
-		resumeToken   irt.ResumeToken
+		cs          *mongo.ChangeStream
+		exporters   map[string]Exporter
+		resumeToken irt.ResumeToken
+		deadLetter  idl.DeadLetter
+		redactor    redaction.Redactor
+		// lastResumeToken is the resume token of the last exported change stream, which the change streams are reopened from.
+		lastResumeToken string
+		// resumed is whether the change streams are resumed from a saved resume token, after which the saved resume token
+		// of each destination is valid.
+		resumed bool
 	}
 )
 
@@ -245,39 +306,101 @@ func (c *changeStreamsExporterClientImpl) decode() (primitive.M, error) {
 	if err := c.cs.Decode(&csMap); err != nil {
 		return nil, errors.InternalServerError.Wrap("Failed to decode change stream.", err)
 	}
+	// The change stream is redacted here, so that no exporter, nor the dead letter, sees the fields before redaction.
+	if c.redactor != nil {
+		c.redactor.Redact(csMap)
+	}
 	return csMap, nil
 }
 
+// close closes the change streams. The exporters are kept open for the change streams reopened after a resumable error.
 func (c *changeStreamsExporterClientImpl) close(ctx context.Context) error {
-	return c.cs.Close(ctx)
+	if err := c.cs.Close(ctx); err != nil {
+		return errors.InternalServerErrorMongoDbOperate.Wrap("Failed to close change streams.", err)
+	}
+	return nil
 }
 
-func (c *changeStreamsExporterClientImpl) exportToBigquery(ctx context.Context, cs primitive.M) error {
-	return c.bq.ExportToBigquery(ctx, cs)
+func (c *changeStreamsExporterClientImpl) export(ctx context.Context, dst string, css []primitive.M) error {
+	exporter, ok := c.exporters[dst]
+	if !ok {
+		return errors.InternalServerError.Wrap("The export destination is wrong.", fmt.Errorf("you need to set the export destination in the environment variable correctly. you set %s", dst))
+	}
+	if err := exporter.Export(ctx, css); err != nil {
+		return err
+	}
+	return exporter.Flush(ctx)
 }
 
-func (c *changeStreamsExporterClientImpl) exportToPubsub(ctx context.Context, cs primitive.M) error {
-	return c.pubsub.ExportToPubsub(ctx, cs)
+func (c *changeStreamsExporterClientImpl) saveResumeToken(ctx context.Context, rt string) error {
+	c.lastResumeToken = rt
+	return c.resumeToken.SaveResumeToken(ctx, rt)
 }
 
-func (c *changeStreamsExporterClientImpl) exportToKinesisStream(ctx context.Context, cs primitive.M) error {
-	return c.kinesisStream.ExportToKinesisStream(ctx, cs)
+func (c *changeStreamsExporterClientImpl) flushResumeToken(ctx context.Context) error {
+	return c.resumeToken.FlushResumeToken(ctx)
 }
 
-func (c *changeStreamsExporterClientImpl) exportToFile(ctx context.Context, cs primitive.M) error {
-	return c.fileExporter.Export(ctx, cs)
+func (c *changeStreamsExporterClientImpl) destinationResumeToken(ctx context.Context, dst string) string {
+	if !c.resumed {
+		return ""
+	}
+	return strings.TrimRight(c.resumeToken.ReadDestinationResumeToken(ctx, dst), "\n")
 }
 
-func (c *changeStreamsExporterClientImpl) saveResumeToken(ctx context.Context, rt string) error {
-	return c.resumeToken.SaveResumeToken(ctx, rt)
+func (c *changeStreamsExporterClientImpl) saveDestinationResumeToken(ctx context.Context, dst string, rt string) error {
+	return c.resumeToken.SaveDestinationResumeToken(ctx, dst, rt)
+}
+
+// sendToDeadLetter returns cause as it is when no dead letter is configured, so that the export fails as before.
+func (c *changeStreamsExporterClientImpl) sendToDeadLetter(ctx context.Context, dst string, cs primitive.M, cause error) error {
+	if c.deadLetter == nil {
+		return cause
+	}
+	return c.deadLetter.Send(ctx, dst, cs, cause)
 }
 
 func (c *changeStreamsExporterClientImpl) err() error {
 	return c.cs.Err()
 }
 
+type changeStreamsEvent struct {
+	cs  primitive.M
+	err error
+}
+
+// receiveChangeStreams reads change streams until the cursor is exhausted or ctx is done,
+// so that the exporting side can flush batches on a timer while no event arrives.
+func (c *ChangeStreamsExporterImpl) receiveChangeStreams(ctx context.Context, events chan<- changeStreamsEvent) {
+	defer close(events)
+
+	for c.exporter.next(ctx) {
+		csMap, err := c.exporter.decode()
+		select {
+		case events <- changeStreamsEvent{cs: csMap, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// exportChangeStreams exports change streams until the cursor is exhausted or ctx is done.
+// When ctx is done, e.g. by a shutdown signal, it stops reading change streams but exports the ones already read
+// within SHUTDOWN_TIMEOUT_SEC, and returns nil.
 func (c *ChangeStreamsExporterImpl) exportChangeStreams(ctx context.Context) error {
-	defer c.exporter.close(ctx)
+	// ectx is not canceled together with ctx, so that in-flight exports and the final resume token save complete on shutdown.
+	ectx, ecancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer ecancel()
+	shutdownTimeout := config.FetchShutdownTimeout()
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(shutdownTimeout, ecancel)
+	})
+	defer stop()
+
+	defer c.close(ectx)
 
 	expDst, err := config.FetchExportDestination()
 	if err != nil {
@@ -285,64 +408,180 @@ func (c *ChangeStreamsExporterImpl) exportChangeStreams(ctx context.Context) err
 	}
 	expDstList := strings.Split(expDst, ",")
 
-	for c.exporter.next(ctx) {
+	dsts := make([]exportDestination, 0, len(expDstList))
+	for _, eDst := range expDstList {
+		dsts = append(dsts, c.newExportDestination(eDst))
+	}
 
-		csMap, err := c.exporter.decode()
-		if err != nil {
-			return err
-		}
+	bCfg := batchConfig.BatchConfig()
+	b := newChangeStreamsBatch(bCfg)
+
+	// With multiple destinations, each destination exports the batches and saves its resume token independently,
+	// so that a slow destination does not stall the others.
+	var sinks *destinationSinks
+	if len(dsts) > 1 {
+		sinks = c.startSinks(ectx, dsts, bCfg.MaxLagBatches)
+		defer sinks.wait()
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-		csDb := csMap["ns"].(primitive.M)["db"].(string)
-		csColl := csMap["ns"].(primitive.M)["coll"].(string)
-		csOpType := csMap["operationType"].(string)
-		csClusterTimeInt := time.Unix(int64(csMap["clusterTime"].(primitive.Timestamp).T), 0)
-
-		c.log.Infof("Success to get change-streams, database: %s, collection: %s, operationType: %s, updateTime: %s", csDb, csColl, csOpType, csClusterTimeInt)
-
-		var eg errgroup.Group
-		for i := 0; i < len(expDstList); i++ {
-			eDst := expDstList[i]
-			eg.Go(func() error {
-				switch agent(eDst) {
-				case BigQuery:
-					if err := c.exporter.exportToBigquery(ctx, csMap); err != nil {
-						return err
-					}
-				case CloudPubSub:
-					if err := c.exporter.exportToPubsub(ctx, csMap); err != nil {
-						return err
-					}
-				case KinesisStream:
-					if err := c.exporter.exportToKinesisStream(ctx, csMap); err != nil {
-						return err
-					}
-				case File:
-					if err := c.exporter.exportToFile(ctx, csMap); err != nil {
-						return err
-					}
-				default:
-					return errors.InternalServerError.Wrap("The export destination is wrong.", fmt.Errorf("you need to set the export destination in the environment variable correctly. you set %s", eDst))
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan changeStreamsEvent)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.receiveChangeStreams(rctx, events)
+	}()
+
+	defer b.reset()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				if err := c.flush(ectx, dsts, sinks, b); err != nil {
+					return err
+				}
+				if err := sinks.wait(); err != nil {
+					return err
+				}
+				if ctx.Err() != nil {
+					c.log.Info("Stopped exporting change streams by shutdown.")
+					return nil
 				}
+				if err := c.exporter.err(); err != nil {
+					return errors.InternalServerErrorChangeStreams.Wrap("Could not get the next event for change stream.", err)
+				}
+
+				c.log.Info("Acquisition of change streams was interrupted.")
+
 				return nil
-			})
-		}
+			}
+			if ev.err != nil {
+				return ev.err
+			}
 
-		if err := eg.Wait(); err != nil {
-			return err
+			csMap := ev.cs
+
+			// Events such as dropDatabase and invalidate have no collection or no namespace at all.
+			ns, _ := csMap["ns"].(primitive.M)
+			csDb, _ := ns["db"].(string)
+			csColl, _ := ns["coll"].(string)
+			csOpType, _ := csMap["operationType"].(string)
+			csClusterTime, _ := csMap["clusterTime"].(primitive.Timestamp)
+			csClusterTimeInt := time.Unix(int64(csClusterTime.T), 0)
+
+			c.log.Infof("Success to get change-streams, database: %s, collection: %s, operationType: %s, updateTime: %s", csDb, csColl, csOpType, csClusterTimeInt)
+
+			b.add(csMap)
+
+			if !b.full() {
+				continue
+			}
+			if err := c.flush(ectx, dsts, sinks, b); err != nil {
+				return err
+			}
+		case <-b.expired():
+			if err := c.flush(ectx, dsts, sinks, b); err != nil {
+				return err
+			}
+		case <-sinks.failed():
+			return sinks.wait()
 		}
+	}
+}
+
+// close saves the resume token skipped by the save interval, so that exported change streams are not exported again
+// after a restart, and then closes the change streams. The exporters are closed by WatchChangeStreams, which opens them.
+func (c *ChangeStreamsExporterImpl) close(ctx context.Context) {
+	if err := c.exporter.flushResumeToken(ctx); err != nil {
+		c.log.Error(err)
+	}
+	if err := c.exporter.close(ctx); err != nil {
+		c.log.Error(err)
+	}
+}
+
+type exportDestination struct {
+	name        string
+	retryPolicy retry.Policy
+}
+
+func (c *ChangeStreamsExporterImpl) newExportDestination(name string) exportDestination {
+	p := retry.NewPolicy(retryConfig.RetryConfig(name))
+	p.Notify = func(err error, attempt int, wait time.Duration) {
+		c.log.Warnf("Failed to export change streams to %s, retrying in %s (attempt %d/%d): %v", name, wait, attempt, p.MaxAttempts, err)
+	}
+	return exportDestination{
+		name:        name,
+		retryPolicy: p,
+	}
+}
 
-		csRt := csMap["_id"].(primitive.M)["_data"].(string)
+// flush exports the batched change streams to every destination and then saves the resume token
+// of the last event, so the token is committed once per batch. With sinks, the batch is queued for each destination instead.
+func (c *ChangeStreamsExporterImpl) flush(ctx context.Context, dsts []exportDestination, sinks *destinationSinks, b *changeStreamsBatch) error {
+	if b.empty() {
+		return nil
+	}
 
-		if err := c.exporter.saveResumeToken(ctx, csRt); err != nil {
+	if sinks != nil {
+		if err := sinks.push(b.css, b.lastResumeToken()); err != nil {
 			return err
 		}
+		b.reset()
+		return nil
 	}
 
-	if err := c.exporter.err(); err != nil {
-		return errors.InternalServerError.Wrap("Could not get the next event for change stream.", err)
+	if err := c.exportBatch(ctx, dsts, b.css); err != nil {
+		return err
 	}
 
-	c.log.Info("Acquisition of change streams was interrupted.")
+	if err := c.exporter.saveResumeToken(ctx, b.lastResumeToken()); err != nil {
+		return err
+	}
 
+	b.reset()
+
+	return nil
+}
+
+// exportBatch exports the change streams to every destination in parallel.
+func (c *ChangeStreamsExporterImpl) exportBatch(ctx context.Context, dsts []exportDestination, css []primitive.M) error {
+	var eg errgroup.Group
+	for i := 0; i < len(dsts); i++ {
+		dst := dsts[i]
+		eg.Go(func() error {
+			return c.exportToDestination(ctx, dst, css)
+		})
+	}
+	return eg.Wait()
+}
+
+// exportToDestination exports the change streams to dst with retries. When the export fails with an error that
+// retrying cannot fix, the failing change streams are sent to the dead letter so that the pipeline can continue.
+func (c *ChangeStreamsExporterImpl) exportToDestination(ctx context.Context, dst exportDestination, css []primitive.M) error {
+	err := dst.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		return c.exporter.export(ctx, dst.name, css)
+	})
+	if err == nil || errors.IsRetryable(err) || ctx.Err() != nil {
+		return err
+	}
+
+	if len(css) == 1 {
+		c.log.Errorf("Failed to export change streams to %s, sending it to dead letter: %v", dst.name, err)
+		return c.exporter.sendToDeadLetter(ctx, dst.name, css[0], err)
+	}
+
+	// Export the batch one by one to find the change streams the destination rejects.
+	for i := 0; i < len(css); i++ {
+		if err := c.exportToDestination(ctx, dst, css[i:i+1]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -4,18 +4,15 @@
 package application
 
 import (
-	"cloud.google.com/go/bigquery"
-	"cloud.google.com/go/pubsub"
 	"context"
-	"github.com/aws/aws-sdk-go-v2/service/kinesis"
-	interfaceForBigquery "github.com/cam-inc/mxtransporter/interfaces/bigquery"
-	iff "github.com/cam-inc/mxtransporter/interfaces/file"
-	interfaceForKinesisStream "github.com/cam-inc/mxtransporter/interfaces/kinesis-stream"
-	interfaceForPubsub "github.com/cam-inc/mxtransporter/interfaces/pubsub"
+	"fmt"
 	interfaceForResumeToken "github.com/cam-inc/mxtransporter/usecases/resume-token"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"sync"
 )
 
 type mockChangeStreamsWatcherClientImpl struct {
@@ -27,29 +24,53 @@ type mockChangeStreamsWatcherClientImpl struct {
 	pubsubPassCheck        string
 	kinesisStreamPassCheck string
 	filePassCheck          string
+	watchCount             int
+	startAtOperationTime   *primitive.Timestamp
+	// exportErrs are returned by exportChangeStreams in order, after which it returns nil.
+	exportErrs []error
+	// progressResumeToken is set as the last exported resume token by exportChangeStreams.
+	progressResumeToken string
+	startAfter          interface{}
+	oldestOplog         primitive.Timestamp
+	oldestOplogErr      error
+	snapshotTime        primitive.Timestamp
+	snapshotPoints      []bson.RawValue
+	// snapshotDocs are the documents of the collection in the order of _id, which are int32.
+	snapshotDocs []interface{}
+	// snapshotScanErr is returned by scanSnapshot once.
+	snapshotScanErr error
+	snapshotScans   int
+	exporter        *mockExporter
+	// builtinExporters creates the exporters by the registered factories instead of mockExporter.
+	builtinExporters bool
+	mu               sync.Mutex
 }
 
-func (m *mockChangeStreamsWatcherClientImpl) newFileClient(_ context.Context) (iff.Exporter, error) {
-	m.filePassCheck = "OK"
-	return nil, nil
-}
-
-func (m *mockChangeStreamsWatcherClientImpl) newBigqueryClient(_ context.Context, _ string) (*bigquery.Client, error) {
-	m.bqPassCheck = "OK"
-	return nil, nil
-}
-
-func (m *mockChangeStreamsWatcherClientImpl) newPubsubClient(_ context.Context, _ string) (*pubsub.Client, error) {
-	m.pubsubPassCheck = "OK"
-	return nil, nil
-}
-
-func (m *mockChangeStreamsWatcherClientImpl) newKinesisClient(_ context.Context) (*kinesis.Client, error) {
-	m.kinesisStreamPassCheck = "OK"
-	return nil, nil
+func (m *mockChangeStreamsWatcherClientImpl) newExporter(ctx context.Context, name string, log *zap.SugaredLogger) (Exporter, error) {
+	if m.builtinExporters {
+		return newExporter(ctx, name, log)
+	}
+	if _, err := lookupExporter(name); err != nil {
+		return nil, err
+	}
+	switch agent(name) {
+	case BigQuery:
+		m.bqPassCheck = "OK"
+	case CloudPubSub:
+		m.pubsubPassCheck = "OK"
+	case KinesisStream:
+		m.kinesisStreamPassCheck = "OK"
+	case File:
+		m.filePassCheck = "OK"
+	}
+	m.exporter = &mockExporter{}
+	return m.exporter, nil
 }
 
 func (m *mockChangeStreamsWatcherClientImpl) watch(_ context.Context, ops *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+	m.watchCount++
+	m.startAtOperationTime = ops.StartAtOperationTime
+	m.startAfter = ops.StartAfter
 	if ops.ResumeAfter != nil {
 		if ops.ResumeAfter.(map[string]string)["_data"] == m.resumeToken {
 			m.resumeAfterExistence = true
@@ -61,59 +82,187 @@ func (m *mockChangeStreamsWatcherClientImpl) watch(_ context.Context, ops *optio
 	return nil, nil
 }
 
-func (c *mockChangeStreamsWatcherClientImpl) setCsExporter(_ ChangeStreamsExporterImpl) {
+func (m *mockChangeStreamsWatcherClientImpl) oldestOplogTime(_ context.Context) (primitive.Timestamp, error) {
+	return m.oldestOplog, m.oldestOplogErr
+}
+
+func (m *mockChangeStreamsWatcherClientImpl) clusterTime(_ context.Context) (primitive.Timestamp, error) {
+	return m.snapshotTime, nil
+}
+
+func (m *mockChangeStreamsWatcherClientImpl) snapshotSplitPoints(_ context.Context, _ int) ([]bson.RawValue, error) {
+	return m.snapshotPoints, nil
+}
+
+func (m *mockChangeStreamsWatcherClientImpl) scanSnapshot(_ context.Context, r snapshotRange) (*mongo.Cursor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshotScans++
+	if err := m.snapshotScanErr; err != nil {
+		m.snapshotScanErr = nil
+		return nil, err
+	}
+	docs := make([]interface{}, 0, len(m.snapshotDocs))
+	for _, d := range m.snapshotDocs {
+		id := d.(primitive.M)["_id"].(int32)
+		if !r.Last.IsZero() && id <= r.Last.Int32() || r.Last.IsZero() && !r.Min.IsZero() && id < r.Min.Int32() || !r.Max.IsZero() && id >= r.Max.Int32() {
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return mongo.NewCursorFromDocuments(docs, nil, nil)
+}
+
+func (c *mockChangeStreamsWatcherClientImpl) setCsExporter(exporter ChangeStreamsExporterImpl) {
+	c.csExporter = exporter
 }
 
 func (c *mockChangeStreamsWatcherClientImpl) exportChangeStreams(_ context.Context) error {
+	if client, ok := c.csExporter.exporter.(*changeStreamsExporterClientImpl); ok {
+		client.lastResumeToken = c.progressResumeToken
+	}
+	if len(c.exportErrs) > 0 {
+		err := c.exportErrs[0]
+		c.exportErrs = c.exportErrs[1:]
+		return err
+	}
+	return nil
+}
+
+type mockExporter struct {
+	initCount   int
+	exportCount int
+	flushCount  int
+	closeCount  int
+	css         []primitive.M
+}
+
+func (m *mockExporter) Init(_ context.Context) error {
+	m.initCount++
+	return nil
+}
+
+func (m *mockExporter) Export(_ context.Context, css []primitive.M) error {
+	m.exportCount++
+	m.css = append(m.css, css...)
+	return nil
+}
+
+func (m *mockExporter) Flush(_ context.Context) error {
+	m.flushCount++
+	return nil
+}
+
+func (m *mockExporter) Close(_ context.Context) error {
+	m.closeCount++
 	return nil
 }
 
 type mockChangeStreamsExporterClientImpl struct {
 	cs                     primitive.M
-	bq                     interfaceForBigquery.BigqueryImpl
-	pubsub                 interfaceForPubsub.PubsubImpl
-	kinesisStream          interfaceForKinesisStream.KinesisStreamImpl
 	resumeToken            interfaceForResumeToken.ResumeToken
 	bqPassCheck            string
 	pubsubPassCheck        string
 	kinesisStreamPassCheck string
 	filePassCheck          string
 	csCursorFlag           bool
+	exportErrs             []error
+	exportCount            int
+	deadLetterEnabled      bool
+	deadLetters            []string
+	flushedResumeToken     bool
+	closed                 bool
+	savedResumeTokens      []string
+	// destinationResumeTokens are the saved resume tokens of each destination.
+	destinationResumeTokens map[string]string
+	// failDestination fails the exports to it, failDestinationTimes times or every time if it is zero.
+	failDestination      string
+	failDestinationTimes int
+	failedExports        int
+	// mu guards the fields written by export, which is called for every destination concurrently.
+	mu sync.Mutex
 }
 
+// next yields the change stream only once per csCursorFlag reset.
+// It is called from the receiving goroutine, so the flag is consumed here rather than in saveResumeToken.
 func (m *mockChangeStreamsExporterClientImpl) next(_ context.Context) bool {
-	return m.csCursorFlag
+	f := m.csCursorFlag
+	m.csCursorFlag = false
+	return f
 }
 
 func (m *mockChangeStreamsExporterClientImpl) decode() (primitive.M, error) {
 	return m.cs, nil
 }
 
-func (_ *mockChangeStreamsExporterClientImpl) close(_ context.Context) error {
+func (m *mockChangeStreamsExporterClientImpl) close(_ context.Context) error {
+	m.closed = true
 	return nil
 }
 
-func (m *mockChangeStreamsExporterClientImpl) exportToBigquery(_ context.Context, _ primitive.M) error {
-	m.bqPassCheck = "OK"
+func (m *mockChangeStreamsExporterClientImpl) export(_ context.Context, dst string, _ []primitive.M) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.exportCount++
+	if dst == m.failDestination && (m.failDestinationTimes == 0 || m.failedExports < m.failDestinationTimes) {
+		m.failedExports++
+		return fmt.Errorf("failed to export to %s", dst)
+	}
+	if len(m.exportErrs) > 0 {
+		err := m.exportErrs[0]
+		m.exportErrs = m.exportErrs[1:]
+		return err
+	}
+	switch agent(dst) {
+	case BigQuery:
+		m.bqPassCheck = "OK"
+	case CloudPubSub:
+		m.pubsubPassCheck = "OK"
+	case KinesisStream:
+		m.kinesisStreamPassCheck = "OK"
+	case File:
+		m.filePassCheck = "OK"
+	default:
+		return fmt.Errorf("unexpected export destination %s", dst)
+	}
 	return nil
 }
 
-func (m *mockChangeStreamsExporterClientImpl) exportToPubsub(_ context.Context, _ primitive.M) error {
-	m.pubsubPassCheck = "OK"
+func (m *mockChangeStreamsExporterClientImpl) saveResumeToken(_ context.Context, rt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.savedResumeTokens = append(m.savedResumeTokens, rt)
 	return nil
 }
 
-func (m *mockChangeStreamsExporterClientImpl) exportToKinesisStream(_ context.Context, _ primitive.M) error {
-	m.kinesisStreamPassCheck = "OK"
+func (m *mockChangeStreamsExporterClientImpl) destinationResumeToken(_ context.Context, dst string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.destinationResumeTokens[dst]
+}
+
+func (m *mockChangeStreamsExporterClientImpl) saveDestinationResumeToken(_ context.Context, dst string, rt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.destinationResumeTokens == nil {
+		m.destinationResumeTokens = map[string]string{}
+	}
+	m.destinationResumeTokens[dst] = rt
 	return nil
 }
-func (m *mockChangeStreamsExporterClientImpl) exportToFile(_ context.Context, cs primitive.M) error {
-	m.filePassCheck = "OK"
+
+func (m *mockChangeStreamsExporterClientImpl) flushResumeToken(_ context.Context) error {
+	m.flushedResumeToken = true
 	return nil
 }
 
-func (m *mockChangeStreamsExporterClientImpl) saveResumeToken(_ context.Context, _ string) error {
-	m.csCursorFlag = false
+func (m *mockChangeStreamsExporterClientImpl) sendToDeadLetter(_ context.Context, dst string, _ primitive.M, cause error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.deadLetterEnabled {
+		return cause
+	}
+	m.deadLetters = append(m.deadLetters, dst)
 	return nil
 }
 
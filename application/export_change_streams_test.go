@@ -5,17 +5,16 @@ package application
 
 import (
 	"context"
+	"fmt"
 	mocks "github.com/cam-inc/mxtransporter/application/mock"
 	"github.com/cam-inc/mxtransporter/config"
-	interfaceForBigquery "github.com/cam-inc/mxtransporter/interfaces/bigquery"
-	interfaceForKinesisStream "github.com/cam-inc/mxtransporter/interfaces/kinesis-stream"
-	interfaceForPubsub "github.com/cam-inc/mxtransporter/interfaces/pubsub"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
 	"github.com/cam-inc/mxtransporter/pkg/logger"
 	"github.com/golang/mock/gomock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -199,25 +198,33 @@ func Test_watchChangeStreams(t *testing.T) {
 					t.Fatalf("Failed to unset file PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS environment variables.")
 				}
 
-				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
-					mongoClient:            nil,
-					csExporter:             ChangeStreamsExporterImpl{},
-					resumeToken:            "",
-					resumeAfterExistence:   false,
-					bqPassCheck:            "",
-					pubsubPassCheck:        "",
-					kinesisStreamPassCheck: "",
-					filePassCheck:          "",
-				}
-				watcher := ChangeStreamsWatcherImpl{
-					Watcher: mockWatcherClient,
-					Log:     l,
+				if err := os.Setenv("MONGODB_COLLECTION", "test"); err != nil {
+					t.Fatalf("Failed to set file MONGODB_COLLECTION environment variables.")
 				}
-				if err := watcher.WatchChangeStreams(ctx); err == nil {
-					t.Fatalf("Not behaving as intended.")
+
+				for _, dst := range []string{"bigquery", "pubsub"} {
+					if err := os.Setenv("EXPORT_DESTINATION", dst); err != nil {
+						t.Fatalf("Failed to set file EXPORT_DESTINATION environment variables.")
+					}
+					mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+						mongoClient:      nil,
+						csExporter:       ChangeStreamsExporterImpl{},
+						builtinExporters: true,
+					}
+					watcher := ChangeStreamsWatcherImpl{
+						Watcher: mockWatcherClient,
+						Log:     l,
+					}
+					err := watcher.WatchChangeStreams(ctx)
+					if err == nil || !strings.Contains(err.Error(), "PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS") {
+						t.Fatalf("Not behaving as intended. destination: %s, error: %v", dst, err)
+					}
 				}
 
 				// Undo environment variables
+				if err := os.Setenv("EXPORT_DESTINATION", "bigquery"); err != nil {
+					t.Fatalf("Failed to set file EXPORT_DESTINATION environment variables.")
+				}
 				if err := os.Setenv("PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS", ""); err != nil {
 					t.Fatalf("Failed to set file GCP_PROJECT environment variables.")
 				}
@@ -509,9 +516,6 @@ func Test_exportChangeStreams(t *testing.T) {
 
 	mockExporterClient := &mockChangeStreamsExporterClientImpl{
 		cs:                     csMap,
-		bq:                     interfaceForBigquery.BigqueryImpl{},
-		pubsub:                 interfaceForPubsub.PubsubImpl{},
-		kinesisStream:          interfaceForKinesisStream.KinesisStreamImpl{},
 		resumeToken:            resumeTokenImpl,
 		bqPassCheck:            "",
 		pubsubPassCheck:        "",
@@ -630,6 +634,91 @@ func Test_exportChangeStreams(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Pass to export after retrying a transient failure.",
+			runner: func(t *testing.T) {
+				if err := os.Setenv("EXPORT_DESTINATION", "bigquery"); err != nil {
+					t.Fatalf("Failed to set file EXPORT_DESTINATION environment variables.")
+				}
+				if err := os.Setenv("EXPORT_RETRY_MAX_ATTEMPTS", "3"); err != nil {
+					t.Fatalf("Failed to set file EXPORT_RETRY_MAX_ATTEMPTS environment variables.")
+				}
+				if err := os.Setenv("EXPORT_RETRY_BASE_BACKOFF_MSEC", "1"); err != nil {
+					t.Fatalf("Failed to set file EXPORT_RETRY_BASE_BACKOFF_MSEC environment variables.")
+				}
+				transientErr := errors.InternalServerErrorBigqueryInsert.Wrap("Failed to insert record to Bigquery.", context.DeadlineExceeded)
+				mockExporterClient.exportErrs = []error{transientErr, transientErr}
+				mockExporterClient.exportCount = 0
+
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockExporterClient.exportCount != 3 {
+					t.Fatalf("Testing Error, ErrorMessage: expect 3 export attempts, got %d.", mockExporterClient.exportCount)
+				}
+				mockExporterClient.bqPassCheck = ""
+			},
+		},
+		{
+			name: "Failed to export by a fatal error without retrying.",
+			runner: func(t *testing.T) {
+				fatalErr := errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", fmt.Errorf("yyy"))
+				mockExporterClient.exportErrs = []error{fatalErr}
+				mockExporterClient.exportCount = 0
+
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if mockExporterClient.exportCount != 1 {
+					t.Fatalf("Testing Error, ErrorMessage: expect 1 export attempt, got %d.", mockExporterClient.exportCount)
+				}
+			},
+		},
+		{
+			name: "Pass to send change streams failed by a fatal error to dead letter.",
+			runner: func(t *testing.T) {
+				fatalErr := errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", fmt.Errorf("yyy"))
+				mockExporterClient.exportErrs = []error{fatalErr}
+				mockExporterClient.exportCount = 0
+				mockExporterClient.deadLetterEnabled = true
+				defer func() {
+					mockExporterClient.deadLetterEnabled = false
+					mockExporterClient.deadLetters = nil
+				}()
+
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(mockExporterClient.deadLetters) != 1 || mockExporterClient.deadLetters[0] != "bigquery" {
+					t.Fatalf("Testing Error, ErrorMessage: expect 1 dead letter for bigquery, got %v.", mockExporterClient.deadLetters)
+				}
+
+				// Undo environment variables
+				os.Unsetenv("EXPORT_RETRY_MAX_ATTEMPTS")
+				os.Unsetenv("EXPORT_RETRY_BASE_BACKOFF_MSEC")
+			},
+		},
+		{
+			name: "Pass to drain change streams and save the resume token on shutdown.",
+			runner: func(t *testing.T) {
+				mockExporterClient.flushedResumeToken = false
+				mockExporterClient.closed = false
+
+				sctx, cancel := context.WithCancel(ctx)
+				cancel()
+
+				exporter := ChangeStreamsExporterImpl{mockExporterClient, l}
+				if err := exporter.exportChangeStreams(sctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if !mockExporterClient.flushedResumeToken || !mockExporterClient.closed {
+					t.Fatalf("Testing Error, ErrorMessage: resume token is not flushed or exporters are not closed on shutdown.")
+				}
+			},
+		},
 		{
 			name: "Export destination is wrong.",
 			runner: func(t *testing.T) {
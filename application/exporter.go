@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type (
+	// Exporter exports change streams to one export destination.
+	// An Exporter is resolved by the name set in EXPORT_DESTINATION, see RegisterExporter.
+	Exporter interface {
+		// Init is called once before the first export, e.g. to check the destination exists.
+		Init(ctx context.Context) error
+		// Export sends a batch of change streams to the destination.
+		Export(ctx context.Context, css []primitive.M) error
+		// Flush blocks until every change stream passed to Export has been accepted by the destination.
+		// The resume token is saved only after Flush returns without error.
+		Flush(ctx context.Context) error
+		// Close releases the clients held by the exporter.
+		Close(ctx context.Context) error
+	}
+
+	// ExporterFactory creates the Exporter for an export destination.
+	ExporterFactory func(ctx context.Context, log *zap.SugaredLogger) (Exporter, error)
+)
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]ExporterFactory{}
+)
+
+// RegisterExporter makes an Exporter available by the provided name in EXPORT_DESTINATION.
+// It is meant to be called from an init function, and panics if the name is already registered or the factory is nil.
+func RegisterExporter(name string, factory ExporterFactory) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if factory == nil {
+		panic("application: RegisterExporter factory is nil")
+	}
+	if _, dup := exporters[name]; dup {
+		panic("application: RegisterExporter called twice for exporter " + name)
+	}
+	exporters[name] = factory
+}
+
+// Exporters returns a sorted list of the names of the registered exporters.
+func Exporters() []string {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	list := make([]string, 0, len(exporters))
+	for name := range exporters {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func lookupExporter(name string) (ExporterFactory, error) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	factory, ok := exporters[name]
+	if !ok {
+		return nil, errors.InternalServerError.Wrap("The export destination is wrong.", fmt.Errorf("you need to set the export destination in the environment variable correctly. you set %s", name))
+	}
+	return factory, nil
+}
+
+func newExporter(ctx context.Context, name string, log *zap.SugaredLogger) (Exporter, error) {
+	factory, err := lookupExporter(name)
+	if err != nil {
+		return nil, err
+	}
+	return factory(ctx, log)
+}
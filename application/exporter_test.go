@@ -0,0 +1,97 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func Test_RegisterExporter(t *testing.T) {
+	ctx := context.Background()
+
+	var l *zap.SugaredLogger
+
+	logCfg := config.LogConfig()
+	l = logger.New(logCfg)
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Built-in exporters are registered.",
+			runner: func(t *testing.T) {
+				for _, name := range []agent{BigQuery, CloudPubSub, KinesisStream, File} {
+					if _, err := lookupExporter(string(name)); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to resolve a registered exporter.",
+			runner: func(t *testing.T) {
+				want := &mockExporter{}
+				RegisterExporter("inHouseSink", func(_ context.Context, _ *zap.SugaredLogger) (Exporter, error) {
+					return want, nil
+				})
+				got, err := newExporter(ctx, "inHouseSink", l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got != want {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if e, a := []string{"bigquery", "file", "inHouseSink", "kinesisStream", "pubsub"}, Exporters(); !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Export destination is wrong.",
+			runner: func(t *testing.T) {
+				if _, err := newExporter(ctx, "xxx", l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Panic to register the same name twice.",
+			runner: func(t *testing.T) {
+				defer func() {
+					if recover() == nil {
+						t.Fatalf("Not behaving as intended.")
+					}
+				}()
+				RegisterExporter(string(File), newFileExporter)
+			},
+		},
+		{
+			name: "Failed to fetch gcp project id.",
+			runner: func(t *testing.T) {
+				// Unset environment variables to reproduce the condition.
+				if err := os.Unsetenv("PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS"); err != nil {
+					t.Fatalf("Failed to unset file PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS environment variables.")
+				}
+				if _, err := newExporter(ctx, string(BigQuery), l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if _, err := newExporter(ctx, string(CloudPubSub), l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
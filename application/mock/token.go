@@ -51,6 +51,20 @@ func (mr *MockResumeTokenMockRecorder) Env() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Env", reflect.TypeOf((*MockResumeToken)(nil).Env))
 }
 
+// FlushResumeToken mocks base method.
+func (m *MockResumeToken) FlushResumeToken(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FlushResumeToken", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FlushResumeToken indicates an expected call of FlushResumeToken.
+func (mr *MockResumeTokenMockRecorder) FlushResumeToken(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushResumeToken", reflect.TypeOf((*MockResumeToken)(nil).FlushResumeToken), ctx)
+}
+
 // ReadResumeToken mocks base method.
 func (m *MockResumeToken) ReadResumeToken(ctx context.Context) string {
 	m.ctrl.T.Helper()
@@ -65,6 +79,48 @@ func (mr *MockResumeTokenMockRecorder) ReadResumeToken(ctx interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadResumeToken", reflect.TypeOf((*MockResumeToken)(nil).ReadResumeToken), ctx)
 }
 
+// ReadDestinationResumeToken mocks base method.
+func (m *MockResumeToken) ReadDestinationResumeToken(ctx context.Context, dst string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadDestinationResumeToken", ctx, dst)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ReadDestinationResumeToken indicates an expected call of ReadDestinationResumeToken.
+func (mr *MockResumeTokenMockRecorder) ReadDestinationResumeToken(ctx, dst interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadDestinationResumeToken", reflect.TypeOf((*MockResumeToken)(nil).ReadDestinationResumeToken), ctx, dst)
+}
+
+// ReadSnapshotCheckpoint mocks base method.
+func (m *MockResumeToken) ReadSnapshotCheckpoint(ctx context.Context) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadSnapshotCheckpoint", ctx)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ReadSnapshotCheckpoint indicates an expected call of ReadSnapshotCheckpoint.
+func (mr *MockResumeTokenMockRecorder) ReadSnapshotCheckpoint(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadSnapshotCheckpoint", reflect.TypeOf((*MockResumeToken)(nil).ReadSnapshotCheckpoint), ctx)
+}
+
+// SaveDestinationResumeToken mocks base method.
+func (m *MockResumeToken) SaveDestinationResumeToken(ctx context.Context, dst, rt string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDestinationResumeToken", ctx, dst, rt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDestinationResumeToken indicates an expected call of SaveDestinationResumeToken.
+func (mr *MockResumeTokenMockRecorder) SaveDestinationResumeToken(ctx, dst, rt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDestinationResumeToken", reflect.TypeOf((*MockResumeToken)(nil).SaveDestinationResumeToken), ctx, dst, rt)
+}
+
 // SaveResumeToken mocks base method.
 func (m *MockResumeToken) SaveResumeToken(ctx context.Context, rt string) error {
 	m.ctrl.T.Helper()
@@ -78,3 +134,17 @@ func (mr *MockResumeTokenMockRecorder) SaveResumeToken(ctx, rt interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveResumeToken", reflect.TypeOf((*MockResumeToken)(nil).SaveResumeToken), ctx, rt)
 }
+
+// SaveSnapshotCheckpoint mocks base method.
+func (m *MockResumeToken) SaveSnapshotCheckpoint(ctx context.Context, cp string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSnapshotCheckpoint", ctx, cp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSnapshotCheckpoint indicates an expected call of SaveSnapshotCheckpoint.
+func (mr *MockResumeTokenMockRecorder) SaveSnapshotCheckpoint(ctx, cp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSnapshotCheckpoint", reflect.TypeOf((*MockResumeToken)(nil).SaveSnapshotCheckpoint), ctx, cp)
+}
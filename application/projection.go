@@ -0,0 +1,267 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	projectionConfig "github.com/cam-inc/mxtransporter/config/projection"
+	mongoConnection "github.com/cam-inc/mxtransporter/interfaces/mongo"
+	"github.com/cam-inc/mxtransporter/pkg/common"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/fieldpath"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type (
+	// projection reshapes change streams for an export destination by dotted paths, e.g. fullDocument.name.
+	// Fields in arrays are reached through every element, as in MongoDB.
+	projection struct {
+		include *pathTree
+		exclude *pathTree
+		renames []fieldRename
+	}
+
+	pathTree struct {
+		leaf     bool
+		children map[string]*pathTree
+	}
+
+	fieldRename struct {
+		from []string
+		to   []string
+	}
+
+	// projectingExporter projects change streams before exporting them, without changing the ones shared with other destinations.
+	projectingExporter struct {
+		Exporter
+		projection *projection
+	}
+)
+
+// withProjection wraps exporter with the projection of EXPORT_FIELDS_* for dst, or returns it as it is if none is set.
+func withProjection(exporter Exporter, dst string) (Exporter, error) {
+	p, err := newProjection(projectionConfig.ProjectionConfig(dst))
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return exporter, nil
+	}
+	return &projectingExporter{Exporter: exporter, projection: p}, nil
+}
+
+func (e *projectingExporter) Export(ctx context.Context, css []primitive.M) error {
+	projected := make([]primitive.M, len(css))
+	for i, cs := range css {
+		projected[i] = e.projection.apply(cs)
+	}
+	return e.Exporter.Export(ctx, projected)
+}
+
+func newProjection(cfg projectionConfig.Projection) (*projection, error) {
+	if cfg.Include == "" && cfg.Exclude == "" && cfg.Rename == "" {
+		return nil, nil
+	}
+	p := &projection{}
+
+	if cfg.Include != "" {
+		paths, err := splitPaths(cfg.Include)
+		if err != nil {
+			return nil, err
+		}
+		// The required fields are kept as _id is in $project, so that the exporters can read them.
+		for _, f := range mongoConnection.RequiredFields {
+			paths = append(paths, []string{f})
+		}
+		p.include = newPathTree(paths)
+	}
+	if cfg.Exclude != "" {
+		paths, err := splitPaths(cfg.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if err := checkNotRequired("EXPORT_FIELDS_EXCLUDE", path); err != nil {
+				return nil, err
+			}
+		}
+		p.exclude = newPathTree(paths)
+	}
+	if cfg.Rename != "" {
+		for _, r := range strings.Split(cfg.Rename, ",") {
+			from, to, ok := strings.Cut(strings.TrimSpace(r), ":")
+			if !ok {
+				return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("EXPORT_FIELDS_RENAME must be a list of from:to. you set %s", r))
+			}
+			f, err := fieldpath.Parse(strings.TrimSpace(from))
+			if err != nil {
+				return nil, err
+			}
+			t, err := fieldpath.Parse(strings.TrimSpace(to))
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range [][]string{f, t} {
+				if err := checkNotRequired("EXPORT_FIELDS_RENAME", path); err != nil {
+					return nil, err
+				}
+			}
+			p.renames = append(p.renames, fieldRename{from: f, to: t})
+		}
+	}
+	return p, nil
+}
+
+// checkNotRequired fails if path is, or is in, one of the required fields, which the projection must not remove or overwrite.
+func checkNotRequired(env string, path []string) error {
+	if common.Contains(mongoConnection.RequiredFields, path[0]) {
+		return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("%s must not change %s of change streams.", env, strings.Join(path, ".")))
+	}
+	return nil
+}
+
+func splitPaths(list string) ([][]string, error) {
+	var paths [][]string
+	for _, s := range strings.Split(list, ",") {
+		path, err := fieldpath.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func newPathTree(paths [][]string) *pathTree {
+	root := &pathTree{}
+	for _, path := range paths {
+		n := root
+		for _, seg := range path {
+			if n.children == nil {
+				n.children = map[string]*pathTree{}
+			}
+			c, ok := n.children[seg]
+			if !ok {
+				c = &pathTree{}
+				n.children[seg] = c
+			}
+			n = c
+		}
+		n.leaf = true
+	}
+	return root
+}
+
+// apply returns the projected copy of cs. The included fields are kept first, then the excluded ones are removed and the fields are renamed.
+func (p *projection) apply(cs primitive.M) primitive.M {
+	var v interface{} = cs
+	if p.include != nil {
+		v = includeFields(v, p.include)
+	}
+	if p.exclude != nil {
+		v = excludeFields(v, p.exclude)
+	}
+	doc, _ := v.(primitive.M)
+	for _, r := range p.renames {
+		var val interface{}
+		var ok bool
+		if doc, val, ok = removeField(doc, r.from); ok {
+			doc = setField(doc, r.to, val)
+		}
+	}
+	return doc
+}
+
+func includeFields(v interface{}, n *pathTree) interface{} {
+	if n.leaf {
+		return v
+	}
+	switch t := v.(type) {
+	case primitive.M:
+		out := primitive.M{}
+		for k, c := range n.children {
+			if e, ok := t[k]; ok {
+				if e = includeFields(e, c); e != nil {
+					out[k] = e
+				}
+			}
+		}
+		return out
+	case primitive.A:
+		out := make(primitive.A, 0, len(t))
+		for _, e := range t {
+			if e = includeFields(e, n); e != nil {
+				out = append(out, e)
+			}
+		}
+		return out
+	}
+	// A field which is not a document has none of the included fields under it.
+	return nil
+}
+
+func excludeFields(v interface{}, n *pathTree) interface{} {
+	switch t := v.(type) {
+	case primitive.M:
+		out := make(primitive.M, len(t))
+		for k, e := range t {
+			c, ok := n.children[k]
+			switch {
+			case !ok:
+				out[k] = e
+			case !c.leaf:
+				out[k] = excludeFields(e, c)
+			}
+		}
+		return out
+	case primitive.A:
+		out := make(primitive.A, len(t))
+		for i, e := range t {
+			out[i] = excludeFields(e, n)
+		}
+		return out
+	}
+	return v
+}
+
+// removeField returns a copy of doc without the field at path, and its value.
+func removeField(doc primitive.M, path []string) (primitive.M, interface{}, bool) {
+	v, ok := doc[path[0]]
+	if !ok {
+		return doc, nil, false
+	}
+	out := make(primitive.M, len(doc))
+	for k, e := range doc {
+		out[k] = e
+	}
+	if len(path) == 1 {
+		delete(out, path[0])
+		return out, v, true
+	}
+	sub, isDoc := v.(primitive.M)
+	if !isDoc {
+		return doc, nil, false
+	}
+	sub, removed, ok := removeField(sub, path[1:])
+	if !ok {
+		return doc, nil, false
+	}
+	out[path[0]] = sub
+	return out, removed, true
+}
+
+// setField returns a copy of doc with v at path, making the documents on the way if missing.
+func setField(doc primitive.M, path []string, v interface{}) primitive.M {
+	out := make(primitive.M, len(doc)+1)
+	for k, e := range doc {
+		out[k] = e
+	}
+	if len(path) == 1 {
+		out[path[0]] = v
+		return out
+	}
+	sub, _ := doc[path[0]].(primitive.M)
+	out[path[0]] = setField(sub, path[1:], v)
+	return out
+}
@@ -0,0 +1,140 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config/constant"
+	projectionConfig "github.com/cam-inc/mxtransporter/config/projection"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_projection(t *testing.T) {
+	ctx := context.Background()
+
+	event := func() primitive.M {
+		return primitive.M{
+			"_id":           primitive.M{"_data": "00001"},
+			"operationType": "insert",
+			"fullDocument": primitive.M{
+				"name":     "Alice",
+				"password": "p@ss",
+				"address":  primitive.M{"city": "Tokyo", "zip": "1500001"},
+				"contacts": primitive.A{primitive.M{"name": "Bob", "phone": "0311112222"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to keep the included fields and the required fields.",
+			runner: func(t *testing.T) {
+				p, err := newProjection(projectionConfig.Projection{Include: "fullDocument.address.city,fullDocument.contacts.name"})
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				cs := event()
+				cs["clusterTime"] = primitive.Timestamp{T: 1}
+				want := primitive.M{
+					"_id":           primitive.M{"_data": "00001"},
+					"operationType": "insert",
+					"clusterTime":   primitive.Timestamp{T: 1},
+					"fullDocument": primitive.M{
+						"address":  primitive.M{"city": "Tokyo"},
+						"contacts": primitive.A{primitive.M{"name": "Bob"}},
+					},
+				}
+				if got := p.apply(cs); !reflect.DeepEqual(want, got) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, got)
+				}
+			},
+		},
+		{
+			name: "Pass to remove the excluded fields and rename the fields.",
+			runner: func(t *testing.T) {
+				p, err := newProjection(projectionConfig.Projection{
+					Exclude: "fullDocument.password,fullDocument.contacts.phone",
+					Rename:  "fullDocument.name:fullDocument.userName, fullDocument.address.zip:postalCode",
+				})
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := primitive.M{
+					"_id":           primitive.M{"_data": "00001"},
+					"operationType": "insert",
+					"fullDocument": primitive.M{
+						"userName": "Alice",
+						"address":  primitive.M{"city": "Tokyo"},
+						"contacts": primitive.A{primitive.M{"name": "Bob"}},
+					},
+					"postalCode": "1500001",
+				}
+				if got := p.apply(event()); !reflect.DeepEqual(want, got) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, got)
+				}
+			},
+		},
+		{
+			name: "Pass to project for a destination without changing the change streams of the others.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FIELDS_INCLUDE+"_PUBSUB", "fullDocument.name")
+				t.Setenv(constant.EXPORT_FIELDS_EXCLUDE+"_PUBSUB", "fullDocument.password")
+				inner := &mockExporter{}
+				exporter, err := withProjection(inner, "pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				cs := event()
+				if err := exporter.Export(ctx, []primitive.M{cs}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := primitive.M{
+					"_id":           primitive.M{"_data": "00001"},
+					"operationType": "insert",
+					"fullDocument":  primitive.M{"name": "Alice"},
+				}
+				if !reflect.DeepEqual(inner.css[0], want) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, inner.css[0])
+				}
+				if !reflect.DeepEqual(cs, event()) {
+					t.Fatalf("Testing Error, ErrorMessage: the change stream is changed, got %v.", cs)
+				}
+
+				if exporter, err := withProjection(inner, "bigquery"); err != nil || exporter != Exporter(inner) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by a projection which is wrong.",
+			runner: func(t *testing.T) {
+				for _, cfg := range []projectionConfig.Projection{
+					{Include: "fullDocument..name"},
+					{Exclude: ","},
+					{Rename: "fullDocument.name"},
+					{Rename: "fullDocument.name:"},
+					{Exclude: "_id"},
+					{Exclude: "fullDocument.password,clusterTime"},
+					{Exclude: "_id._data"},
+					{Rename: "operationType:op"},
+					{Rename: "fullDocument.name:_id"},
+				} {
+					if _, err := newProjection(cfg); err == nil {
+						t.Fatalf("Not behaving as intended. projection: %v", cfg)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,141 @@
+package application
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	mongoConnection "github.com/cam-inc/mxtransporter/interfaces/mongo"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/retry"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type historyLostPolicy string
+
+const (
+	// historyLostFail stops MxTransporter, so that an operator decides how to recover.
+	historyLostFail historyLostPolicy = "fail"
+	// historyLostNow restarts the change streams from the current time, skipping the lost change streams.
+	historyLostNow historyLostPolicy = "now"
+	// historyLostTimestamp restarts the change streams from CHANGE_STREAM_HISTORY_LOST_RESTART_AT.
+	historyLostTimestamp historyLostPolicy = "timestamp"
+)
+
+const (
+	defaultReconnectBaseBackoff = time.Second
+	defaultReconnectMaxBackoff  = time.Minute
+)
+
+// changeStreamsPosition is where the change streams are opened from. The zero value opens them from the current time.
+type changeStreamsPosition struct {
+	resumeToken string
+	startAt     *primitive.Timestamp
+	// startAfter is a resume token given by CHANGE_STREAM_START_AFTER, which can be after an invalidate event unlike resumeToken.
+	startAfter string
+}
+
+// reconnector decides whether and from where the change streams are reopened after they stop with an error.
+type reconnector struct {
+	log                *zap.SugaredLogger
+	policy             retry.Policy
+	historyLost        historyLostPolicy
+	restartAt          *primitive.Timestamp
+	attempt            int
+	historyLostHandled bool
+}
+
+func newReconnector(cfg mongodb.ChangeStream, log *zap.SugaredLogger) (*reconnector, error) {
+	r := &reconnector{
+		log: log,
+		policy: retry.Policy{
+			MaxAttempts: cfg.ReconnectMaxAttempts,
+			BaseBackoff: time.Duration(cfg.ReconnectBaseBackoffMSec) * time.Millisecond,
+			MaxBackoff:  time.Duration(cfg.ReconnectMaxBackoffMSec) * time.Millisecond,
+			Jitter:      true,
+		},
+		historyLost: historyLostPolicy(cfg.HistoryLostPolicy),
+	}
+	if r.policy.BaseBackoff <= 0 {
+		r.policy.BaseBackoff = defaultReconnectBaseBackoff
+	}
+	if r.policy.MaxBackoff <= 0 {
+		r.policy.MaxBackoff = defaultReconnectMaxBackoff
+	}
+
+	switch r.historyLost {
+	case "":
+		r.historyLost = historyLostFail
+	case historyLostFail, historyLostNow:
+	case historyLostTimestamp:
+		ts, err := parseOperationTime("CHANGE_STREAM_HISTORY_LOST_RESTART_AT", cfg.HistoryLostRestartAt)
+		if err != nil {
+			return nil, err
+		}
+		r.restartAt = ts
+	default:
+		return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("CHANGE_STREAM_HISTORY_LOST_POLICY must be fail, now or timestamp. you set %s", cfg.HistoryLostPolicy))
+	}
+	return r, nil
+}
+
+// parseOperationTime accepts a RFC 3339 time, unix seconds or a BSON timestamp written as {seconds}:{increment}.
+func parseOperationTime(env, v string) (*primitive.Timestamp, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &primitive.Timestamp{T: uint32(t.Unix())}, nil
+	}
+	if sec, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return &primitive.Timestamp{T: uint32(sec)}, nil
+	}
+	if sec, inc, ok := strings.Cut(v, ":"); ok {
+		t, terr := strconv.ParseUint(sec, 10, 32)
+		i, ierr := strconv.ParseUint(inc, 10, 32)
+		if terr == nil && ierr == nil {
+			return &primitive.Timestamp{T: uint32(t), I: uint32(i)}, nil
+		}
+	}
+	return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("%s must be a RFC 3339 time, unix seconds or {seconds}:{increment}. you set %s", env, v))
+}
+
+// progressed resets the attempts once change streams have been exported after reconnecting.
+func (r *reconnector) progressed() {
+	r.attempt = 0
+	r.historyLostHandled = false
+}
+
+// reconnect returns the position to reopen the change streams from and the wait before it,
+// or err itself when the change streams must not be reopened.
+func (r *reconnector) reconnect(err error, pos changeStreamsPosition) (changeStreamsPosition, time.Duration, error) {
+	// Only the errors of the change streams themselves are fixed by reopening them.
+	// The errors of exporters are left to the retries and the dead letter of each destination.
+	if !errors.HasType(err, errors.InternalServerErrorChangeStreams) {
+		return pos, 0, err
+	}
+	if mongoConnection.IsChangeStreamHistoryLost(err) {
+		// The restart position can itself be lost, so the policy is applied only once until change streams are exported.
+		if r.historyLost == historyLostFail || r.historyLostHandled {
+			return pos, 0, err
+		}
+		r.historyLostHandled = true
+		r.log.Errorf("The resume token is no longer in the oplog, restarting change streams by the %s policy: %v", r.historyLost, err)
+		if r.historyLost == historyLostNow {
+			return changeStreamsPosition{}, 0, nil
+		}
+		return changeStreamsPosition{startAt: r.restartAt}, 0, nil
+	}
+
+	if !mongoConnection.IsResumableError(err) {
+		return pos, 0, err
+	}
+	r.attempt++
+	// MaxAttempts is the number of reconnections in a row, and 0 means without limit.
+	if r.policy.MaxAttempts > 0 && r.attempt > r.policy.MaxAttempts {
+		return pos, 0, err
+	}
+	wait := r.policy.Backoff(r.attempt)
+	r.log.Warnf("Change streams stopped by a resumable error, reconnecting in %s (attempt %d): %v", wait, r.attempt, err)
+	return pos, wait, nil
+}
@@ -0,0 +1,219 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_reconnectChangeStreams(t *testing.T) {
+	ctx := context.Background()
+	l := logger.New(config.LogConfig())
+
+	// The errors of change streams are returned as the change streams iterating them return, see exportChangeStreams.
+	stepDownErr := errors.InternalServerErrorChangeStreams.Wrap("Could not get the next event for change stream.",
+		mongo.CommandError{Code: 189, Name: "PrimarySteppedDown", Labels: []string{"ResumableChangeStreamError"}})
+	historyLostErr := errors.InternalServerErrorChangeStreams.Wrap("Could not get the next event for change stream.",
+		mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"})
+
+	setEnvs := func(t *testing.T, envs map[string]string) {
+		for k, v := range envs {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+			k := k
+			t.Cleanup(func() { os.Unsetenv(k) })
+		}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to reconnect from the last exported resume token after a resumable error.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                        "bigquery",
+					constant.MONGODB_COLLECTION:                        "test",
+					constant.CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC: "1",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					resumeToken:         "00001",
+					exportErrs:          []error{stepDownErr},
+					progressResumeToken: "00001",
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockWatcherClient.watchCount != 2 {
+					t.Fatalf("Testing Error, ErrorMessage: expect 2 watches, got %d.", mockWatcherClient.watchCount)
+				}
+				if !mockWatcherClient.resumeAfterExistence {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not reopened from the last exported resume token.")
+				}
+			},
+		},
+		{
+			name: "Failed to reconnect more than the max attempts.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                        "bigquery",
+					constant.MONGODB_COLLECTION:                        "test",
+					constant.CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC: "1",
+					constant.CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS:      "2",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					exportErrs: []error{stepDownErr, stepDownErr, stepDownErr},
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if mockWatcherClient.watchCount != 3 {
+					t.Fatalf("Testing Error, ErrorMessage: expect 3 watches, got %d.", mockWatcherClient.watchCount)
+				}
+			},
+		},
+		{
+			name: "Failed by a history lost error with the default policy.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION: "bigquery",
+					constant.MONGODB_COLLECTION: "test",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					exportErrs: []error{historyLostErr},
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to restart from now after a history lost error.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                "bigquery",
+					constant.MONGODB_COLLECTION:                "test",
+					constant.CHANGE_STREAM_HISTORY_LOST_POLICY: "now",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					exportErrs: []error{historyLostErr},
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockWatcherClient.watchCount != 2 || mockWatcherClient.startAtOperationTime != nil {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not restarted from now.")
+				}
+			},
+		},
+		{
+			name: "Pass to restart from the timestamp after a history lost error.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                    "bigquery",
+					constant.MONGODB_COLLECTION:                    "test",
+					constant.CHANGE_STREAM_HISTORY_LOST_POLICY:     "timestamp",
+					constant.CHANGE_STREAM_HISTORY_LOST_RESTART_AT: "2022-04-01T00:00:00Z",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					exportErrs: []error{historyLostErr},
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if ts := mockWatcherClient.startAtOperationTime; ts == nil || ts.T != 1648771200 {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not restarted from the timestamp, got %v.", ts)
+				}
+			},
+		},
+		{
+			name: "Failed by a history lost error again before exporting.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                "bigquery",
+					constant.MONGODB_COLLECTION:                "test",
+					constant.CHANGE_STREAM_HISTORY_LOST_POLICY: "now",
+				})
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					exportErrs: []error{historyLostErr, historyLostErr},
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by a history lost policy which is wrong.",
+			runner: func(t *testing.T) {
+				if _, err := newReconnector(mongodb.ChangeStream{HistoryLostPolicy: "xxx"}, l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if _, err := newReconnector(mongodb.ChangeStream{HistoryLostPolicy: "timestamp", HistoryLostRestartAt: "yesterday"}, l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by an error of the exporters without reconnecting.",
+			runner: func(t *testing.T) {
+				setEnvs(t, map[string]string{
+					constant.EXPORT_DESTINATION:                        "bigquery",
+					constant.MONGODB_COLLECTION:                        "test",
+					constant.CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC: "1",
+					constant.CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS:      "0",
+				})
+				for _, exportErr := range []error{
+					errors.InternalServerErrorBigqueryInsert.Wrap("Failed to insert record to Bigquery.", &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}),
+					errors.InternalServerErrorPubSubPublish.Wrap("Failed to publish message.", context.DeadlineExceeded),
+				} {
+					mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+						exportErrs: []error{exportErr},
+					}
+					watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+					if err := watcher.WatchChangeStreams(ctx); err == nil {
+						t.Fatalf("Not behaving as intended. error: %v", exportErr)
+					}
+					if mockWatcherClient.watchCount != 1 {
+						t.Fatalf("Testing Error, ErrorMessage: expect 1 watch, got %d.", mockWatcherClient.watchCount)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed by an error which is not resumable without reconnecting.",
+			runner: func(t *testing.T) {
+				rc, err := newReconnector(mongodb.ChangeStream{}, l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if _, _, err := rc.reconnect(fmt.Errorf("decode error"), changeStreamsPosition{}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	routingConfig "github.com/cam-inc/mxtransporter/config/routing"
+	"github.com/cam-inc/mxtransporter/pkg/common"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/fieldpath"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type (
+	// router chooses the export destinations of change streams by the first route they match.
+	// Change streams matching no route are exported to every destination.
+	router struct {
+		routes []route
+	}
+
+	// route matches change streams which satisfy all of its predicates, e.g. ns.coll is orders and operationType is insert.
+	route struct {
+		predicates   []predicate
+		destinations map[string]struct{}
+	}
+
+	// predicate compares the field at path with values by op, which is one of $eq, $ne, $in, $nin and $exists.
+	predicate struct {
+		path   []string
+		op     string
+		values []interface{}
+	}
+
+	routeSpec struct {
+		Match        bson.D   `bson:"match"`
+		Destinations []string `bson:"destinations"`
+	}
+
+	// routingExporter exports only the change streams routed to dst.
+	routingExporter struct {
+		Exporter
+		router *router
+		dst    string
+	}
+)
+
+// newRouter returns the router of EXPORT_ROUTES or EXPORT_ROUTES_FILE, or nil if none is set.
+// The destinations of the routes must be in dsts, so that a typo does not drop change streams silently.
+func newRouter(cfg routingConfig.Routing, dsts []string) (*router, error) {
+	if cfg.Routes != "" && cfg.RoutesFile != "" {
+		return nil, errors.InternalServerErrorEnvGet.New("Only one of EXPORT_ROUTES and EXPORT_ROUTES_FILE can be set.")
+	}
+	routes := cfg.Routes
+	if cfg.RoutesFile != "" {
+		b, err := os.ReadFile(cfg.RoutesFile)
+		if err != nil {
+			return nil, errors.InternalServerError.Wrap("Failed to read the routes file.", err)
+		}
+		routes = string(b)
+	}
+	if strings.TrimSpace(routes) == "" {
+		return nil, nil
+	}
+
+	// Extended JSON can only be unmarshaled into a document, so the array is wrapped with one.
+	var doc struct {
+		Routes []routeSpec `bson:"routes"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(fmt.Sprintf(`{"routes": %s}`, routes)), false, &doc); err != nil {
+		return nil, errors.InternalServerErrorEnvGet.Wrap("The routes are not a JSON array of {match, destinations}.", err)
+	}
+
+	r := &router{}
+	for _, spec := range doc.Routes {
+		rt := route{destinations: make(map[string]struct{}, len(spec.Destinations))}
+		for _, d := range spec.Destinations {
+			if !common.Contains(dsts, d) {
+				return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The route destination %s is not in EXPORT_DESTINATION.", d))
+			}
+			rt.destinations[d] = struct{}{}
+		}
+		for _, e := range spec.Match {
+			ps, err := newPredicates(e.Key, e.Value)
+			if err != nil {
+				return nil, err
+			}
+			rt.predicates = append(rt.predicates, ps...)
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r, nil
+}
+
+// newPredicates returns the predicates of a field of match, which is a value to equal or a document of operators.
+func newPredicates(field string, v interface{}) ([]predicate, error) {
+	path, err := fieldpath.Parse(field)
+	if err != nil {
+		return nil, err
+	}
+	ops, ok := v.(primitive.D)
+	if !ok || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+		return []predicate{{path: path, op: "$eq", values: []interface{}{v}}}, nil
+	}
+
+	ps := make([]predicate, 0, len(ops))
+	for _, o := range ops {
+		p := predicate{path: path, op: o.Key}
+		switch o.Key {
+		case "$eq", "$ne":
+			p.values = []interface{}{o.Value}
+		case "$in", "$nin":
+			values, ok := o.Value.(primitive.A)
+			if !ok {
+				return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("%s of the route field %s must be an array. got %v", o.Key, field, o.Value))
+			}
+			p.values = values
+		case "$exists":
+			exists, ok := o.Value.(bool)
+			if !ok {
+				return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("$exists of the route field %s must be a boolean. got %v", field, o.Value))
+			}
+			p.values = []interface{}{exists}
+		default:
+			return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The operator %s of the route field %s is not supported.", o.Key, field))
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// withRouting wraps exporter to export only the change streams routed to dst, or returns it as it is without routes.
+func withRouting(exporter Exporter, dst string, r *router) Exporter {
+	if r == nil {
+		return exporter
+	}
+	return &routingExporter{Exporter: exporter, router: r, dst: dst}
+}
+
+func (e *routingExporter) Export(ctx context.Context, css []primitive.M) error {
+	routed := make([]primitive.M, 0, len(css))
+	for _, cs := range css {
+		if e.router.routed(cs, e.dst) {
+			routed = append(routed, cs)
+		}
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+	return e.Exporter.Export(ctx, routed)
+}
+
+// routed reports whether cs is exported to dst.
+func (r *router) routed(cs primitive.M, dst string) bool {
+	for _, rt := range r.routes {
+		if rt.match(cs) {
+			_, ok := rt.destinations[dst]
+			return ok
+		}
+	}
+	return true
+}
+
+func (rt route) match(cs primitive.M) bool {
+	for _, p := range rt.predicates {
+		if !p.match(cs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p predicate) match(cs primitive.M) bool {
+	v, ok := fieldpath.Lookup(cs, p.path)
+	switch p.op {
+	case "$exists":
+		return ok == p.values[0].(bool)
+	case "$eq", "$in":
+		return ok && containsValue(p.values, v)
+	case "$ne", "$nin":
+		return !ok || !containsValue(p.values, v)
+	}
+	return false
+}
+
+// containsValue reports whether v equals one of values. Numbers are compared by value regardless of their BSON types.
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if a, ok := toFloat(e); ok {
+			if b, ok := toFloat(v); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
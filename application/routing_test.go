@@ -0,0 +1,146 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	routingConfig "github.com/cam-inc/mxtransporter/config/routing"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_router(t *testing.T) {
+	ctx := context.Background()
+	dsts := []string{"bigquery", "kinesisStream", "file", "pubsub"}
+
+	event := func(coll, opType string, fullDocument primitive.M) primitive.M {
+		return primitive.M{
+			"_id":           primitive.M{"_data": "00001"},
+			"operationType": opType,
+			"ns":            primitive.M{"db": "shop", "coll": coll},
+			"fullDocument":  fullDocument,
+		}
+	}
+
+	routes := `[
+		{"match": {"operationType": "delete"}, "destinations": ["file"]},
+		{"match": {"ns.coll": "orders", "operationType": "insert"}, "destinations": ["kinesisStream", "bigquery"]},
+		{"match": {"fullDocument.status": {"$in": ["paid", "shipped"]}, "fullDocument.amount": {"$ne": 0}}, "destinations": ["pubsub"]},
+		{"match": {"fullDocument.internal": {"$exists": true}}, "destinations": []}
+	]`
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to route change streams by the first route they match.",
+			runner: func(t *testing.T) {
+				r, err := newRouter(routingConfig.Routing{Routes: routes}, dsts)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				for _, c := range []struct {
+					cs   primitive.M
+					want []string
+				}{
+					{event("orders", "delete", nil), []string{"file"}},
+					{event("orders", "insert", nil), []string{"bigquery", "kinesisStream"}},
+					{event("users", "update", primitive.M{"status": "paid", "amount": int64(100)}), []string{"pubsub"}},
+					{event("users", "update", primitive.M{"status": "paid", "amount": int32(0)}), dsts},
+					{event("users", "update", primitive.M{"internal": true}), nil},
+					{event("users", "insert", primitive.M{"status": "new"}), dsts},
+				} {
+					var got []string
+					for _, dst := range dsts {
+						if r.routed(c.cs, dst) {
+							got = append(got, dst)
+						}
+					}
+					if len(got) != len(c.want) {
+						t.Fatalf("Testing Error, ErrorMessage: cs: %v, want: %v, got: %v", c.cs, c.want, got)
+					}
+					for i := range got {
+						if got[i] != c.want[i] {
+							t.Fatalf("Testing Error, ErrorMessage: cs: %v, want: %v, got: %v", c.cs, c.want, got)
+						}
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to export only the change streams routed to the destination.",
+			runner: func(t *testing.T) {
+				r, err := newRouter(routingConfig.Routing{Routes: routes}, dsts)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				inner := &mockExporter{}
+				exporter := withRouting(inner, "file", r)
+				if err := exporter.Export(ctx, []primitive.M{event("orders", "insert", nil), event("orders", "delete", nil)}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(inner.css) != 1 || inner.css[0]["operationType"] != "delete" {
+					t.Fatalf("Testing Error, ErrorMessage: got: %v", inner.css)
+				}
+				if err := exporter.Export(ctx, []primitive.M{event("orders", "insert", nil)}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if inner.exportCount != 1 {
+					t.Fatalf("Testing Error, ErrorMessage: exported a batch with no change streams routed.")
+				}
+
+				if withRouting(inner, "file", nil) != Exporter(inner) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to read the routes from the file.",
+			runner: func(t *testing.T) {
+				f := filepath.Join(t.TempDir(), "routes.json")
+				if err := os.WriteFile(f, []byte(routes), 0o600); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				r, err := newRouter(routingConfig.Routing{RoutesFile: f}, dsts)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(r.routes) != 4 {
+					t.Fatalf("Not behaving as intended.")
+				}
+
+				if r, err := newRouter(routingConfig.Routing{}, dsts); err != nil || r != nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by routes which are wrong.",
+			runner: func(t *testing.T) {
+				for _, cfg := range []routingConfig.Routing{
+					{Routes: routes, RoutesFile: "routes.json"},
+					{RoutesFile: filepath.Join(t.TempDir(), "missing.json")},
+					{Routes: `{"match": {}}`},
+					{Routes: `[{"match": {"operationType": "delete"}, "destinations": ["s3"]}]`},
+					{Routes: `[{"match": {"ns..coll": "orders"}, "destinations": ["file"]}]`},
+					{Routes: `[{"match": {"operationType": {"$regex": "^d"}}, "destinations": ["file"]}]`},
+					{Routes: `[{"match": {"operationType": {"$in": "delete"}}, "destinations": ["file"]}]`},
+					{Routes: `[{"match": {"fullDocument": {"$exists": 1}}, "destinations": ["file"]}]`},
+				} {
+					if _, err := newRouter(cfg, dsts); err == nil {
+						t.Fatalf("Not behaving as intended. routes: %v", cfg)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
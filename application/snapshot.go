@@ -0,0 +1,321 @@
+package application
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	batchConfig "github.com/cam-inc/mxtransporter/config/batch"
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	snapshotConfig "github.com/cam-inc/mxtransporter/config/snapshot"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	idl "github.com/cam-inc/mxtransporter/usecases/dead-letter"
+	irt "github.com/cam-inc/mxtransporter/usecases/resume-token"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/errgroup"
+)
+
+type snapshotMode string
+
+const (
+	snapshotOff snapshotMode = "off"
+	// snapshotInitial exports the documents in the collection before the change streams, when no resume token is stored.
+	snapshotInitial snapshotMode = "initial"
+)
+
+const (
+	defaultSnapshotParallelism = 4
+	// defaultSnapshotCheckpointInterval keeps a scan of small batches from writing the storage per document.
+	defaultSnapshotCheckpointInterval = 10 * time.Second
+	snapshotOperationType             = "snapshot"
+)
+
+// snapshotCheckpoint is the progress of the snapshot. It is saved as Extended JSON, so that _id of any type is kept.
+type snapshotCheckpoint struct {
+	// ClusterTime is recorded before the scan, and the change streams are opened at it after the snapshot.
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+	Ranges      []snapshotRange     `bson:"ranges"`
+	Completed   bool                `bson:"completed"`
+}
+
+// snapshotRange is the range of _id, Min <= _id < Max, scanned by a worker. A zero bound means the range is unbounded.
+type snapshotRange struct {
+	Min bson.RawValue `bson:"min,omitempty"`
+	Max bson.RawValue `bson:"max,omitempty"`
+	// Last is _id of the last exported document in the range.
+	Last bson.RawValue `bson:"last,omitempty"`
+	Done bool          `bson:"done"`
+}
+
+// snapshotBatch is a batch of snapshot events of a range, passed from a scanning worker to the exporting loop.
+type snapshotBatch struct {
+	rangeIndex int
+	css        []primitive.M
+	last       bson.RawValue
+	done       bool
+}
+
+// snapshotProgress saves the checkpoint through the resume token storage at most once per interval, like the resume token.
+type snapshotProgress struct {
+	mu          sync.Mutex
+	cp          snapshotCheckpoint
+	resumeToken irt.ResumeToken
+	interval    time.Duration
+	savedAt     time.Time
+	pending     bool
+}
+
+// update changes the checkpoint and saves it if the interval has passed since the last save.
+func (p *snapshotProgress) update(ctx context.Context, f func(cp *snapshotCheckpoint)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f(&p.cp)
+	p.pending = true
+	if time.Since(p.savedAt) < p.interval {
+		return nil
+	}
+	return p.save(ctx)
+}
+
+// flush saves the checkpoint updated since the last save, if any.
+func (p *snapshotProgress) flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.pending {
+		return nil
+	}
+	return p.save(ctx)
+}
+
+func (p *snapshotProgress) save(ctx context.Context) error {
+	b, err := bson.MarshalExtJSON(p.cp, true, false)
+	if err != nil {
+		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal the snapshot checkpoint.", err)
+	}
+	if err := p.resumeToken.SaveSnapshotCheckpoint(ctx, string(b)); err != nil {
+		return err
+	}
+	p.savedAt = time.Now()
+	p.pending = false
+	return nil
+}
+
+func parseSnapshotMode(mode string) (snapshotMode, error) {
+	switch m := snapshotMode(mode); m {
+	case "":
+		return snapshotOff, nil
+	case snapshotOff, snapshotInitial:
+		return m, nil
+	default:
+		return "", errors.InternalServerErrorEnvGet.New(fmt.Sprintf("SNAPSHOT_MODE must be off or initial. you set %s", mode))
+	}
+}
+
+// snapshot exports the documents in the watched collection as snapshot events and returns the cluster time recorded before the scan.
+// The collection is scanned in parallel ranges of _id, and the progress is checkpointed so that an interrupted snapshot continues after a restart.
+func (c *ChangeStreamsWatcherImpl) snapshot(ctx context.Context, cfg snapshotConfig.Snapshot, exporters map[string]Exporter, deadLetter idl.DeadLetter) (*primitive.Timestamp, error) {
+	interval := time.Duration(cfg.CheckpointIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = defaultSnapshotCheckpointInterval
+	}
+	p := &snapshotProgress{resumeToken: c.resumeTokenManager, interval: interval}
+	if s := c.resumeTokenManager.ReadSnapshotCheckpoint(ctx); s != "" {
+		if err := bson.UnmarshalExtJSON([]byte(s), true, &p.cp); err != nil {
+			return nil, errors.InternalServerError.Wrap("Failed to read the snapshot checkpoint.", err)
+		}
+		if p.cp.Completed {
+			c.Log.Infof("The snapshot is already completed, starting change streams at %v.", p.cp.ClusterTime)
+			return &p.cp.ClusterTime, nil
+		}
+		c.Log.Info("Continuing the interrupted snapshot.")
+	} else {
+		cp, err := c.newSnapshotCheckpoint(ctx, cfg.Parallelism)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.update(ctx, func(saved *snapshotCheckpoint) { *saved = cp }); err != nil {
+			return nil, err
+		}
+		if err := p.flush(ctx); err != nil {
+			return nil, err
+		}
+		c.Log.Infof("Started the snapshot at %v in %d ranges.", cp.ClusterTime, len(cp.Ranges))
+	}
+
+	exporter := ChangeStreamsExporterImpl{
+		exporter: &changeStreamsExporterClientImpl{exporters: exporters, deadLetter: deadLetter},
+		log:      c.Log,
+	}
+	dsts := make([]exportDestination, 0, len(exporters))
+	for name := range exporters {
+		dsts = append(dsts, exporter.newExportDestination(name))
+	}
+
+	mongoCfg := mongodb.MongoConfig()
+	ns := primitive.M{"db": mongoCfg.MongoDbDatabase, "coll": mongoCfg.MongoDbCollection}
+
+	// The ranges are scanned in parallel, while the batches are exported one by one as the change streams are.
+	clusterTime := p.cp.ClusterTime
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eg, sctx := errgroup.WithContext(sctx)
+	batches := make(chan snapshotBatch)
+	for i, r := range p.cp.Ranges {
+		if r.Done {
+			continue
+		}
+		i, r := i, r
+		eg.Go(func() error {
+			return c.scanSnapshotRange(sctx, i, r, ns, clusterTime, batches)
+		})
+	}
+	go func() {
+		_ = eg.Wait()
+		close(batches)
+	}()
+
+	err := c.exportSnapshotBatches(ctx, exporter, dsts, p, batches)
+	if err != nil {
+		cancel()
+		// Unblock the workers, which stop by the cancel.
+		for range batches {
+		}
+	} else {
+		err = eg.Wait()
+	}
+	if err != nil {
+		// The progress exported so far is saved, so that the snapshot continues from there after a restart.
+		if ferr := p.flush(context.WithoutCancel(ctx)); ferr != nil {
+			c.Log.Errorf("Failed to save the snapshot checkpoint on stop, err: %v", ferr)
+		}
+		return nil, err
+	}
+
+	if err := p.update(ctx, func(cp *snapshotCheckpoint) { cp.Completed = true }); err != nil {
+		return nil, err
+	}
+	if err := p.flush(ctx); err != nil {
+		return nil, err
+	}
+	c.Log.Infof("Completed the snapshot, starting change streams at %v.", clusterTime)
+	return &clusterTime, nil
+}
+
+// exportSnapshotBatches exports the batches and checkpoints the progress of each range after it is exported.
+// The checkpoint is saved by the interval, and always when a range is done.
+func (c *ChangeStreamsWatcherImpl) exportSnapshotBatches(ctx context.Context, exporter ChangeStreamsExporterImpl, dsts []exportDestination, p *snapshotProgress, batches <-chan snapshotBatch) error {
+	for b := range batches {
+		if len(b.css) > 0 {
+			if err := exporter.exportBatch(ctx, dsts, b.css); err != nil {
+				return err
+			}
+		}
+		if err := p.update(ctx, func(cp *snapshotCheckpoint) {
+			if !b.last.IsZero() {
+				cp.Ranges[b.rangeIndex].Last = b.last
+			}
+			cp.Ranges[b.rangeIndex].Done = b.done
+		}); err != nil {
+			return err
+		}
+		if !b.done {
+			continue
+		}
+		if err := p.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSnapshotCheckpoint records the cluster time and then splits the collection, so that the documents written during the scan are in the change streams.
+func (c *ChangeStreamsWatcherImpl) newSnapshotCheckpoint(ctx context.Context, parallelism int) (snapshotCheckpoint, error) {
+	if parallelism <= 0 {
+		parallelism = defaultSnapshotParallelism
+	}
+	ts, err := c.Watcher.clusterTime(ctx)
+	if err != nil {
+		return snapshotCheckpoint{}, err
+	}
+	points, err := c.Watcher.snapshotSplitPoints(ctx, parallelism)
+	if err != nil {
+		return snapshotCheckpoint{}, err
+	}
+
+	ranges := make([]snapshotRange, 0, len(points)+1)
+	var min bson.RawValue
+	for _, p := range points {
+		ranges = append(ranges, snapshotRange{Min: min, Max: p})
+		min = p
+	}
+	ranges = append(ranges, snapshotRange{Min: min})
+	return snapshotCheckpoint{ClusterTime: ts, Ranges: ranges}, nil
+}
+
+func (c *ChangeStreamsWatcherImpl) scanSnapshotRange(ctx context.Context, i int, r snapshotRange, ns primitive.M, clusterTime primitive.Timestamp, batches chan<- snapshotBatch) error {
+	cur, err := c.Watcher.scanSnapshot(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.WithoutCancel(ctx))
+
+	send := func(b snapshotBatch) error {
+		select {
+		case batches <- b:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	b := newChangeStreamsBatch(batchConfig.BatchConfig())
+	var last bson.RawValue
+	for cur.Next(ctx) {
+		var doc primitive.M
+		if err := cur.Decode(&doc); err != nil {
+			return errors.InternalServerError.Wrap("Failed to decode the document of the snapshot.", err)
+		}
+		ev := snapshotEvent(doc, ns, clusterTime)
+		if c.redactor != nil {
+			c.redactor.Redact(ev)
+		}
+		b.add(ev)
+		// The cursor buffer is reused, so _id is copied.
+		id := cur.Current.Lookup("_id")
+		last = bson.RawValue{Type: id.Type, Value: append([]byte(nil), id.Value...)}
+		if !b.full() {
+			continue
+		}
+		css := b.css
+		b.reset()
+		if err := send(snapshotBatch{rangeIndex: i, css: css, last: last}); err != nil {
+			return err
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return errors.InternalServerErrorMongoDbOperate.Wrap("Failed to scan the collection for the snapshot.", err)
+	}
+	return send(snapshotBatch{rangeIndex: i, css: b.css, last: last, done: true})
+}
+
+// snapshotEvent makes a change stream of the document. Its _data is derived from _id, since it has no resume token.
+func snapshotEvent(doc primitive.M, ns primitive.M, clusterTime primitive.Timestamp) primitive.M {
+	return primitive.M{
+		"_id":           primitive.M{"_data": snapshotEventID(doc["_id"])},
+		"operationType": snapshotOperationType,
+		"clusterTime":   clusterTime,
+		"ns":            ns,
+		"documentKey":   primitive.M{"_id": doc["_id"]},
+		"fullDocument":  doc,
+	}
+}
+
+func snapshotEventID(id interface{}) string {
+	b, _ := bson.Marshal(primitive.M{"_id": id})
+	sum := sha1.Sum(b)
+	return snapshotOperationType + "-" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,272 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mocks "github.com/cam-inc/mxtransporter/application/mock"
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_snapshot(t *testing.T) {
+	ctx := context.Background()
+	l := logger.New(config.LogConfig())
+
+	snapshotTime := primitive.Timestamp{T: 1648771200, I: 1}
+	docs := make([]interface{}, 0, 5)
+	for i := int32(1); i <= 5; i++ {
+		docs = append(docs, primitive.M{"_id": i, "count": i})
+	}
+	rawInt32 := func(v int32) bson.RawValue {
+		typ, data, _ := bson.MarshalValue(v)
+		return bson.RawValue{Type: typ, Value: data}
+	}
+
+	setEnvs := func(t *testing.T) string {
+		dir := t.TempDir()
+		t.Setenv(constant.EXPORT_DESTINATION, "bigquery")
+		t.Setenv(constant.MONGODB_COLLECTION, "test")
+		t.Setenv(constant.RESUME_TOKEN_VOLUME_TYPE, "file")
+		t.Setenv(constant.RESUME_TOKEN_VOLUME_DIR, dir)
+		t.Setenv(constant.SNAPSHOT_MODE, "initial")
+		return dir
+	}
+	readCheckpoint := func(t *testing.T, dir string) snapshotCheckpoint {
+		b, err := os.ReadFile(filepath.Join(dir, "test.dat.snapshot"))
+		if err != nil {
+			t.Fatalf("Testing Error, ErrorMessage: %v", err)
+		}
+		var cp snapshotCheckpoint
+		if err := bson.UnmarshalExtJSON(b, true, &cp); err != nil {
+			t.Fatalf("Testing Error, ErrorMessage: %v", err)
+		}
+		return cp
+	}
+	exportedIDs := func(m *mockChangeStreamsWatcherClientImpl) map[int32]int {
+		ids := map[int32]int{}
+		for _, cs := range m.exporter.css {
+			if cs["operationType"] != snapshotOperationType {
+				continue
+			}
+			ids[cs["documentKey"].(primitive.M)["_id"].(int32)]++
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to export the documents in parallel ranges and then start change streams at the cluster time.",
+			runner: func(t *testing.T) {
+				dir := setEnvs(t)
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					snapshotTime:   snapshotTime,
+					snapshotPoints: []bson.RawValue{rawInt32(3)},
+					snapshotDocs:   docs,
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if ids := exportedIDs(mockWatcherClient); len(ids) != 5 || mockWatcherClient.snapshotScans != 2 {
+					t.Fatalf("Testing Error, ErrorMessage: the documents are not exported once each in 2 ranges, got %v in %d scans.", ids, mockWatcherClient.snapshotScans)
+				}
+				if ts := mockWatcherClient.startAtOperationTime; ts == nil || *ts != snapshotTime {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not started at the cluster time, got %v.", ts)
+				}
+				if cp := readCheckpoint(t, dir); !cp.Completed || len(cp.Ranges) != 2 {
+					t.Fatalf("Testing Error, ErrorMessage: the checkpoint is not completed, got %v.", cp)
+				}
+			},
+		},
+		{
+			name: "Pass to continue the interrupted snapshot from the checkpoint.",
+			runner: func(t *testing.T) {
+				dir := setEnvs(t)
+				b, err := bson.MarshalExtJSON(snapshotCheckpoint{
+					ClusterTime: snapshotTime,
+					Ranges:      []snapshotRange{{Max: rawInt32(3), Done: true}, {Min: rawInt32(3), Last: rawInt32(4)}},
+				}, true, false)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "test.dat.snapshot"), b, 0664); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{snapshotDocs: docs}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if ids := exportedIDs(mockWatcherClient); len(ids) != 1 || ids[5] != 1 {
+					t.Fatalf("Testing Error, ErrorMessage: the snapshot is not continued from the checkpoint, got %v.", ids)
+				}
+				if ts := mockWatcherClient.startAtOperationTime; ts == nil || *ts != snapshotTime {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not started at the cluster time, got %v.", ts)
+				}
+			},
+		},
+		{
+			name: "Pass to continue the snapshot after a failure.",
+			runner: func(t *testing.T) {
+				dir := setEnvs(t)
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{
+					snapshotTime:    snapshotTime,
+					snapshotDocs:    docs,
+					snapshotScanErr: fmt.Errorf("scan error"),
+				}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if cp := readCheckpoint(t, dir); cp.Completed {
+					t.Fatalf("Testing Error, ErrorMessage: the failed snapshot is completed.")
+				}
+
+				watcher = ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if ids := exportedIDs(mockWatcherClient); len(ids) != 5 {
+					t.Fatalf("Testing Error, ErrorMessage: the documents are not exported, got %v.", ids)
+				}
+			},
+		},
+		{
+			name: "Pass to skip the snapshot when a resume token is stored.",
+			runner: func(t *testing.T) {
+				dir := setEnvs(t)
+				if err := os.WriteFile(filepath.Join(dir, "test.dat"), []byte("00000"), 0664); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{resumeToken: "00000", snapshotDocs: docs}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockWatcherClient.snapshotScans != 0 || !mockWatcherClient.resumeAfterExistence {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to start at the cluster time of the completed snapshot.",
+			runner: func(t *testing.T) {
+				dir := setEnvs(t)
+				b, _ := bson.MarshalExtJSON(snapshotCheckpoint{ClusterTime: snapshotTime, Ranges: []snapshotRange{{Done: true}}, Completed: true}, true, false)
+				if err := os.WriteFile(filepath.Join(dir, "test.dat.snapshot"), b, 0664); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{snapshotDocs: docs}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if ts := mockWatcherClient.startAtOperationTime; mockWatcherClient.snapshotScans != 0 || ts == nil || *ts != snapshotTime {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to redact the documents of the snapshot.",
+			runner: func(t *testing.T) {
+				setEnvs(t)
+				t.Setenv(constant.REDACTION_RULES, `[{"field": "count", "action": "drop"}]`)
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{snapshotTime: snapshotTime, snapshotDocs: docs}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				for _, cs := range mockWatcherClient.exporter.css {
+					if _, ok := cs["fullDocument"].(primitive.M)["count"]; ok {
+						t.Fatalf("Testing Error, ErrorMessage: the document is not redacted, got %v.", cs)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed by a snapshot mode which is wrong.",
+			runner: func(t *testing.T) {
+				if _, err := parseSnapshotMode("always"); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
+func Test_snapshotProgress(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to save the checkpoint once per interval and the rest by flush.",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				resumeTokenImpl := mocks.NewMockResumeToken(ctrl)
+				resumeTokenImpl.EXPECT().SaveSnapshotCheckpoint(ctx, gomock.Any()).Return(nil).Times(2)
+
+				p := &snapshotProgress{resumeToken: resumeTokenImpl, interval: time.Hour}
+				for i := 0; i < 5; i++ {
+					if err := p.update(ctx, func(cp *snapshotCheckpoint) { cp.Ranges = append(cp.Ranges, snapshotRange{}) }); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+				}
+				if err := p.flush(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				// Nothing is updated since the last save.
+				if err := p.flush(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Failed to save the checkpoint, which is kept to be flushed.",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				resumeTokenImpl := mocks.NewMockResumeToken(ctrl)
+				gomock.InOrder(
+					resumeTokenImpl.EXPECT().SaveSnapshotCheckpoint(ctx, gomock.Any()).Return(fmt.Errorf("storage error")),
+					resumeTokenImpl.EXPECT().SaveSnapshotCheckpoint(ctx, gomock.Any()).Return(nil),
+				)
+
+				p := &snapshotProgress{resumeToken: resumeTokenImpl, interval: time.Hour}
+				if err := p.update(ctx, func(cp *snapshotCheckpoint) { cp.Completed = true }); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if err := p.flush(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	mongoConnection "github.com/cam-inc/mxtransporter/interfaces/mongo"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// startPosition returns the position to open the change streams from at start.
+// The stored resume token is preferred to CHANGE_STREAM_START_AT_OPERATION_TIME and CHANGE_STREAM_START_AFTER
+// unless CHANGE_STREAM_IGNORE_RESUME_TOKEN is set, so that a restart does not export the same change streams again.
+func startPosition(cfg mongodb.ChangeStream, storedToken string, log *zap.SugaredLogger) (changeStreamsPosition, error) {
+	if cfg.StartAtOperationTime != "" && cfg.StartAfter != "" {
+		return changeStreamsPosition{}, errors.InternalServerErrorEnvGet.New("Only one of CHANGE_STREAM_START_AT_OPERATION_TIME and CHANGE_STREAM_START_AFTER can be set.")
+	}
+
+	pos := changeStreamsPosition{startAfter: cfg.StartAfter}
+	if cfg.StartAtOperationTime != "" {
+		ts, err := parseOperationTime("CHANGE_STREAM_START_AT_OPERATION_TIME", cfg.StartAtOperationTime)
+		if err != nil {
+			return changeStreamsPosition{}, err
+		}
+		pos.startAt = ts
+	}
+
+	if storedToken == "" {
+		return pos, nil
+	}
+	if cfg.IgnoreResumeToken {
+		log.Warnf("The stored resume token %s is ignored by CHANGE_STREAM_IGNORE_RESUME_TOKEN.", storedToken)
+		return pos, nil
+	}
+	if pos.startAt != nil || pos.startAfter != "" {
+		log.Warn("The start position is ignored since a resume token is stored. Set CHANGE_STREAM_IGNORE_RESUME_TOKEN to start from it.")
+	}
+	return changeStreamsPosition{resumeToken: storedToken}, nil
+}
+
+// validateOplogWindow fails when the change streams would be opened before the oldest oplog entry, from where they cannot be exported.
+// The validation is skipped when the oplog cannot be read, e.g. through mongos.
+func (c *ChangeStreamsWatcherImpl) validateOplogWindow(ctx context.Context, pos changeStreamsPosition) error {
+	var from primitive.Timestamp
+	switch {
+	case pos.startAt != nil:
+		from = *pos.startAt
+	case pos.startAfter != "":
+		ts, err := mongoConnection.ResumeTokenTime(pos.startAfter)
+		if err != nil {
+			c.Log.Warnf("Skipped validating the start position against the oplog: %v", err)
+			return nil
+		}
+		from = ts
+	default:
+		return nil
+	}
+
+	oldest, err := c.Watcher.oldestOplogTime(ctx)
+	if err != nil {
+		c.Log.Warnf("Skipped validating the start position against the oplog: %v", err)
+		return nil
+	}
+	if from.Before(oldest) {
+		return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The start position %s is older than the oldest oplog entry %s.", formatOperationTime(from), formatOperationTime(oldest)))
+	}
+	return nil
+}
+
+func formatOperationTime(ts primitive.Timestamp) string {
+	return fmt.Sprintf("%s (%d:%d)", time.Unix(int64(ts.T), 0).UTC().Format(time.RFC3339), ts.T, ts.I)
+}
@@ -0,0 +1,173 @@
+//go:build test
+// +build test
+
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_startPosition(t *testing.T) {
+	l := logger.New(config.LogConfig())
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to start from the stored resume token rather than the start options.",
+			runner: func(t *testing.T) {
+				pos, err := startPosition(mongodb.ChangeStream{StartAtOperationTime: "1648771200"}, "00000", l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if pos != (changeStreamsPosition{resumeToken: "00000"}) {
+					t.Fatalf("Not behaving as intended. got: %v", pos)
+				}
+			},
+		},
+		{
+			name: "Pass to start from the operation time ignoring the stored resume token.",
+			runner: func(t *testing.T) {
+				pos, err := startPosition(mongodb.ChangeStream{StartAtOperationTime: "1648771200:3", IgnoreResumeToken: true}, "00000", l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if pos.resumeToken != "" || pos.startAt == nil || *pos.startAt != (primitive.Timestamp{T: 1648771200, I: 3}) {
+					t.Fatalf("Not behaving as intended. got: %v", pos)
+				}
+			},
+		},
+		{
+			name: "Pass to start after the resume token without a stored one.",
+			runner: func(t *testing.T) {
+				pos, err := startPosition(mongodb.ChangeStream{StartAfter: "00001"}, "", l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if pos != (changeStreamsPosition{startAfter: "00001"}) {
+					t.Fatalf("Not behaving as intended. got: %v", pos)
+				}
+			},
+		},
+		{
+			name: "Pass to start from now ignoring the stored resume token.",
+			runner: func(t *testing.T) {
+				pos, err := startPosition(mongodb.ChangeStream{IgnoreResumeToken: true}, "00000", l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if pos != (changeStreamsPosition{}) {
+					t.Fatalf("Not behaving as intended. got: %v", pos)
+				}
+			},
+		},
+		{
+			name: "Failed by start options which are wrong.",
+			runner: func(t *testing.T) {
+				if _, err := startPosition(mongodb.ChangeStream{StartAtOperationTime: "1648771200", StartAfter: "00001"}, "", l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if _, err := startPosition(mongodb.ChangeStream{StartAtOperationTime: "yesterday"}, "", l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
+func Test_validateOplogWindow(t *testing.T) {
+	ctx := context.Background()
+	l := logger.New(config.LogConfig())
+	oldest := primitive.Timestamp{T: 1648771200, I: 1}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to validate the operation time in the oplog window.",
+			runner: func(t *testing.T) {
+				watcher := ChangeStreamsWatcherImpl{Watcher: &mockChangeStreamsWatcherClientImpl{oldestOplog: oldest}, Log: l}
+				if err := watcher.validateOplogWindow(ctx, changeStreamsPosition{startAt: &primitive.Timestamp{T: 1648771200, I: 1}}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Failed by the operation time older than the oplog window.",
+			runner: func(t *testing.T) {
+				watcher := ChangeStreamsWatcherImpl{Watcher: &mockChangeStreamsWatcherClientImpl{oldestOplog: oldest}, Log: l}
+				if err := watcher.validateOplogWindow(ctx, changeStreamsPosition{startAt: &primitive.Timestamp{T: 1648771199}}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by the resume token older than the oplog window.",
+			runner: func(t *testing.T) {
+				watcher := ChangeStreamsWatcherImpl{Watcher: &mockChangeStreamsWatcherClientImpl{oldestOplog: oldest}, Log: l}
+				if err := watcher.validateOplogWindow(ctx, changeStreamsPosition{startAfter: "826246407F000000012B022C0100296E5A1004"}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to skip the validation when the oplog cannot be read.",
+			runner: func(t *testing.T) {
+				watcher := ChangeStreamsWatcherImpl{Watcher: &mockChangeStreamsWatcherClientImpl{oldestOplogErr: fmt.Errorf("unauthorized")}, Log: l}
+				if err := watcher.validateOplogWindow(ctx, changeStreamsPosition{startAt: &primitive.Timestamp{T: 1}}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Failed to start before the oplog window with the environment variables.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_DESTINATION, "bigquery")
+				t.Setenv(constant.MONGODB_COLLECTION, "test")
+				t.Setenv(constant.CHANGE_STREAM_START_AT_OPERATION_TIME, "2022-03-01T00:00:00Z")
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{oldestOplog: oldest}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if mockWatcherClient.watchCount != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are opened before the validation.")
+				}
+			},
+		},
+		{
+			name: "Pass to start after the resume token with the environment variables.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_DESTINATION, "bigquery")
+				t.Setenv(constant.MONGODB_COLLECTION, "test")
+				t.Setenv(constant.CHANGE_STREAM_START_AFTER, "8262470A80000000032B022C0100296E5A1004")
+				mockWatcherClient := &mockChangeStreamsWatcherClientImpl{oldestOplog: oldest}
+				watcher := ChangeStreamsWatcherImpl{Watcher: mockWatcherClient, Log: l}
+				if err := watcher.WatchChangeStreams(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if mockWatcherClient.startAfter == nil {
+					t.Fatalf("Testing Error, ErrorMessage: change streams are not opened after the resume token.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
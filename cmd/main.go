@@ -2,28 +2,87 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/cam-inc/mxtransporter/application"
 	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
 	"github.com/cam-inc/mxtransporter/pkg/client"
 	"github.com/cam-inc/mxtransporter/pkg/logger"
 	"go.uber.org/zap"
 )
 
+const (
+	exitOK    = 0
+	exitError = 1
+
+	disconnectTimeout = 10 * time.Second
+)
+
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	os.Exit(run())
+}
+
+// parseFlags sets the flags to the environment variables of the same meaning, so that they take precedence over them.
+func parseFlags() error {
+	flags := map[string]string{
+		"start-at-operation-time": constant.CHANGE_STREAM_START_AT_OPERATION_TIME,
+		"start-after":             constant.CHANGE_STREAM_START_AFTER,
+		"ignore-resume-token":     constant.CHANGE_STREAM_IGNORE_RESUME_TOKEN,
+	}
+	flag.String("start-at-operation-time", "", "Start change streams from this time, a RFC 3339 time, unix seconds or {seconds}:{increment}.")
+	flag.String("start-after", "", "Start change streams after this resume token.")
+	flag.Bool("ignore-resume-token", false, "Ignore the stored resume token and start from the start options or now.")
+	flag.Parse()
+
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if serr := os.Setenv(flags[f.Name], f.Value.String()); serr != nil && err == nil {
+			err = fmt.Errorf("failed to set %s: %w", flags[f.Name], serr)
+		}
+	})
+	return err
+}
+
+func run() int {
+	if err := parseFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+
+	// ctx is canceled by SIGTERM or SIGINT. The change streams already read are still exported before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
 	var l *zap.SugaredLogger
 
 	logCfg := config.LogConfig()
 	l = logger.New(logCfg)
+	defer l.Sync()
+
+	// A second signal terminates the process immediately.
+	context.AfterFunc(ctx, func() {
+		l.Info("Received a shutdown signal.")
+		stop()
+	})
 
 	mClient, err := client.NewMongoClient(ctx)
 	if err != nil {
 		l.Error(err)
-		cancel()
+		return exitError
 	}
-	defer mClient.Disconnect(ctx)
+	defer func() {
+		dctx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+		defer cancel()
+		if err := mClient.Disconnect(dctx); err != nil {
+			l.Error(err)
+		}
+	}()
 
 	watcherClient := &application.ChangeStreamsWatcherClientImpl{
 		MongoClient: mClient,
@@ -36,6 +95,8 @@ func main() {
 
 	if err := watcher.WatchChangeStreams(ctx); err != nil {
 		l.Error(err)
-		cancel()
+		return exitError
 	}
+
+	return exitOK
 }
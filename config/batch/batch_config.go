@@ -0,0 +1,24 @@
+package batch
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"strconv"
+)
+
+type Batch struct {
+	MaxEvents         int
+	MaxBytes          int
+	FlushIntervalMSec int
+	// MaxLagBatches is how many batches a destination can be behind the others when there are multiple destinations.
+	MaxLagBatches int
+}
+
+func BatchConfig() Batch {
+	var bCfg Batch
+	bCfg.MaxEvents, _ = strconv.Atoi(os.Getenv(constant.EXPORT_BATCH_MAX_EVENTS))
+	bCfg.MaxBytes, _ = strconv.Atoi(os.Getenv(constant.EXPORT_BATCH_MAX_BYTES))
+	bCfg.FlushIntervalMSec, _ = strconv.Atoi(os.Getenv(constant.EXPORT_BATCH_FLUSH_INTERVAL_MSEC))
+	bCfg.MaxLagBatches, _ = strconv.Atoi(os.Getenv(constant.EXPORT_MAX_LAG_BATCHES))
+	return bCfg
+}
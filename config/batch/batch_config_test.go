@@ -0,0 +1,48 @@
+//go:build test
+// +build test
+
+package batch
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_BatchConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		if err := os.Setenv(constant.EXPORT_BATCH_MAX_EVENTS, "500"); err != nil {
+			t.Fatalf("Failed to set file EXPORT_BATCH_MAX_EVENTS environment variables.")
+		}
+		if err := os.Setenv(constant.EXPORT_BATCH_MAX_BYTES, "1048576"); err != nil {
+			t.Fatalf("Failed to set file EXPORT_BATCH_MAX_BYTES environment variables.")
+		}
+		if err := os.Setenv(constant.EXPORT_BATCH_FLUSH_INTERVAL_MSEC, "200"); err != nil {
+			t.Fatalf("Failed to set file EXPORT_BATCH_FLUSH_INTERVAL_MSEC environment variables.")
+		}
+		if err := os.Setenv(constant.EXPORT_MAX_LAG_BATCHES, "20"); err != nil {
+			t.Fatalf("Failed to set file EXPORT_MAX_LAG_BATCHES environment variables.")
+		}
+		bCfg := BatchConfig()
+		want := Batch{
+			MaxEvents:         500,
+			MaxBytes:          1048576,
+			FlushIntervalMSec: 200,
+			MaxLagBatches:     20,
+		}
+		if !reflect.DeepEqual(want, bCfg) {
+			t.Fatalf("Environment variable EXPORT_BATCH_* or EXPORT_MAX_LAG_BATCHES is not acquired correctly. want: %v, got: %v", want, bCfg)
+		}
+	})
+
+	t.Run("Unset environment variables are zero values.", func(t *testing.T) {
+		os.Unsetenv(constant.EXPORT_BATCH_MAX_EVENTS)
+		os.Unsetenv(constant.EXPORT_BATCH_MAX_BYTES)
+		os.Unsetenv(constant.EXPORT_BATCH_FLUSH_INTERVAL_MSEC)
+		os.Unsetenv(constant.EXPORT_MAX_LAG_BATCHES)
+		if bCfg := BatchConfig(); !reflect.DeepEqual(Batch{}, bCfg) {
+			t.Fatalf("Expected zero values, got: %v", bCfg)
+		}
+	})
+}
@@ -7,13 +7,45 @@ const (
 	KINESIS_STREAM_NAME   = "KINESIS_STREAM_NAME"
 	KINESIS_STREAM_REGION = "KINESIS_STREAM_REGION"
 
-	PUBSUB_TOPIC_NAME  = "PUBSUB_TOPIC_NAME"
-	PUBSUB_ORDERING_BY = "PUBSUB_ORDERING_BY"
+	KINESIS_PARTITION_KEY                 = "KINESIS_PARTITION_KEY"
+	KINESIS_SEQUENCE_NUMBER_FOR_ORDERING  = "KINESIS_SEQUENCE_NUMBER_FOR_ORDERING"
+	KINESIS_PUT_RECORDS_MAX_ATTEMPTS      = "KINESIS_PUT_RECORDS_MAX_ATTEMPTS"
+	KINESIS_PUT_RECORDS_BASE_BACKOFF_MSEC = "KINESIS_PUT_RECORDS_BASE_BACKOFF_MSEC"
+	KINESIS_PUT_RECORDS_MAX_BACKOFF_MSEC  = "KINESIS_PUT_RECORDS_MAX_BACKOFF_MSEC"
+
+	PUBSUB_TOPIC_NAME                   = "PUBSUB_TOPIC_NAME"
+	PUBSUB_ORDERING_BY                  = "PUBSUB_ORDERING_BY"
+	PUBSUB_ATTRIBUTES                   = "PUBSUB_ATTRIBUTES"
+	PUBSUB_PUBLISH_DELAY_THRESHOLD_MSEC = "PUBSUB_PUBLISH_DELAY_THRESHOLD_MSEC"
+	PUBSUB_PUBLISH_COUNT_THRESHOLD      = "PUBSUB_PUBLISH_COUNT_THRESHOLD"
+	PUBSUB_PUBLISH_BYTE_THRESHOLD       = "PUBSUB_PUBLISH_BYTE_THRESHOLD"
+	PUBSUB_PUBLISH_NUM_GOROUTINES       = "PUBSUB_PUBLISH_NUM_GOROUTINES"
+	PUBSUB_PUBLISH_TIMEOUT_MSEC         = "PUBSUB_PUBLISH_TIMEOUT_MSEC"
+	PUBSUB_PUBLISH_BUFFERED_BYTE_LIMIT  = "PUBSUB_PUBLISH_BUFFERED_BYTE_LIMIT"
+	PUBSUB_MAX_OUTSTANDING_MESSAGES     = "PUBSUB_MAX_OUTSTANDING_MESSAGES"
+	PUBSUB_MAX_OUTSTANDING_BYTES        = "PUBSUB_MAX_OUTSTANDING_BYTES"
 
 	MONGODB_HOST       = "MONGODB_HOST"
 	MONGODB_DATABASE   = "MONGODB_DATABASE"
 	MONGODB_COLLECTION = "MONGODB_COLLECTION"
 
+	MONGODB_WATCH_SCOPE       = "MONGODB_WATCH_SCOPE"
+	MONGODB_NAMESPACE_INCLUDE = "MONGODB_NAMESPACE_INCLUDE"
+	MONGODB_NAMESPACE_EXCLUDE = "MONGODB_NAMESPACE_EXCLUDE"
+	MONGODB_PIPELINE          = "MONGODB_PIPELINE"
+	MONGODB_PIPELINE_FILE     = "MONGODB_PIPELINE_FILE"
+
+	CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS      = "CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS"
+	CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC = "CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC"
+	CHANGE_STREAM_RECONNECT_MAX_BACKOFF_MSEC  = "CHANGE_STREAM_RECONNECT_MAX_BACKOFF_MSEC"
+	CHANGE_STREAM_HISTORY_LOST_POLICY         = "CHANGE_STREAM_HISTORY_LOST_POLICY"
+	CHANGE_STREAM_HISTORY_LOST_RESTART_AT     = "CHANGE_STREAM_HISTORY_LOST_RESTART_AT"
+	CHANGE_STREAM_FULL_DOCUMENT               = "CHANGE_STREAM_FULL_DOCUMENT"
+	CHANGE_STREAM_FULL_DOCUMENT_BEFORE_CHANGE = "CHANGE_STREAM_FULL_DOCUMENT_BEFORE_CHANGE"
+	CHANGE_STREAM_START_AT_OPERATION_TIME     = "CHANGE_STREAM_START_AT_OPERATION_TIME"
+	CHANGE_STREAM_START_AFTER                 = "CHANGE_STREAM_START_AFTER"
+	CHANGE_STREAM_IGNORE_RESUME_TOKEN         = "CHANGE_STREAM_IGNORE_RESUME_TOKEN"
+
 	RESUME_TOKEN_VOLUME_DIR         = "RESUME_TOKEN_VOLUME_DIR"
 	RESUME_TOKEN_VOLUME_TYPE        = "RESUME_TOKEN_VOLUME_TYPE"
 	RESUME_TOKEN_VOLUME_BUCKET_NAME = "RESUME_TOKEN_VOLUME_BUCKET_NAME"
@@ -24,6 +56,48 @@ const (
 	EXPORT_DESTINATION                    = "EXPORT_DESTINATION"
 	PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS = "PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS"
 
+	EXPORT_BATCH_MAX_EVENTS          = "EXPORT_BATCH_MAX_EVENTS"
+	EXPORT_BATCH_MAX_BYTES           = "EXPORT_BATCH_MAX_BYTES"
+	EXPORT_BATCH_FLUSH_INTERVAL_MSEC = "EXPORT_BATCH_FLUSH_INTERVAL_MSEC"
+	EXPORT_MAX_LAG_BATCHES           = "EXPORT_MAX_LAG_BATCHES"
+
+	EXPORT_FORMAT                  = "EXPORT_FORMAT"
+	EXPORT_EXTJSON_MODE            = "EXPORT_EXTJSON_MODE"
+	EXPORT_AVRO_SCHEMA             = "EXPORT_AVRO_SCHEMA"
+	EXPORT_DEBEZIUM_NAME           = "EXPORT_DEBEZIUM_NAME"
+	EXPORT_DEBEZIUM_REPLICA_SET    = "EXPORT_DEBEZIUM_REPLICA_SET"
+	EXPORT_CLOUDEVENTS_MODE        = "EXPORT_CLOUDEVENTS_MODE"
+	EXPORT_CLOUDEVENTS_TYPE_PREFIX = "EXPORT_CLOUDEVENTS_TYPE_PREFIX"
+
+	EXPORT_FIELDS_INCLUDE = "EXPORT_FIELDS_INCLUDE"
+	EXPORT_FIELDS_EXCLUDE = "EXPORT_FIELDS_EXCLUDE"
+	EXPORT_FIELDS_RENAME  = "EXPORT_FIELDS_RENAME"
+
+	EXPORT_ROUTES      = "EXPORT_ROUTES"
+	EXPORT_ROUTES_FILE = "EXPORT_ROUTES_FILE"
+
+	EXPORT_RETRY_MAX_ATTEMPTS      = "EXPORT_RETRY_MAX_ATTEMPTS"
+	EXPORT_RETRY_BASE_BACKOFF_MSEC = "EXPORT_RETRY_BASE_BACKOFF_MSEC"
+	EXPORT_RETRY_MAX_BACKOFF_MSEC  = "EXPORT_RETRY_MAX_BACKOFF_MSEC"
+	EXPORT_RETRY_JITTER            = "EXPORT_RETRY_JITTER"
+	EXPORT_RETRY_DEADLINE_SEC      = "EXPORT_RETRY_DEADLINE_SEC"
+
+	DEAD_LETTER_TYPE              = "DEAD_LETTER_TYPE"
+	DEAD_LETTER_VOLUME_DIR        = "DEAD_LETTER_VOLUME_DIR"
+	DEAD_LETTER_BUCKET_NAME       = "DEAD_LETTER_BUCKET_NAME"
+	DEAD_LETTER_BUCKET_REGION     = "DEAD_LETTER_BUCKET_REGION"
+	DEAD_LETTER_PUBSUB_TOPIC_NAME = "DEAD_LETTER_PUBSUB_TOPIC_NAME"
+
+	REDACTION_RULES      = "REDACTION_RULES"
+	REDACTION_RULES_FILE = "REDACTION_RULES_FILE"
+	REDACTION_HMAC_KEY   = "REDACTION_HMAC_KEY"
+
+	SNAPSHOT_MODE                    = "SNAPSHOT_MODE"
+	SNAPSHOT_PARALLELISM             = "SNAPSHOT_PARALLELISM"
+	SNAPSHOT_CHECKPOINT_INTERVAL_SEC = "SNAPSHOT_CHECKPOINT_INTERVAL_SEC"
+
+	SHUTDOWN_TIMEOUT_SEC = "SHUTDOWN_TIMEOUT_SEC"
+
 	TIME_ZONE = "TIME_ZONE"
 
 	LOG_LEVEL            = "LOG_LEVEL"
@@ -0,0 +1,26 @@
+package dead_letter
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+)
+
+type (
+	DeadLetter struct {
+		Type            string
+		Path            string
+		BucketName      string
+		Region          string
+		PubSubTopicName string
+	}
+)
+
+func DeadLetterConfig() DeadLetter {
+	var config DeadLetter
+	config.Type = os.Getenv(constant.DEAD_LETTER_TYPE)
+	config.Path = os.Getenv(constant.DEAD_LETTER_VOLUME_DIR)
+	config.BucketName = os.Getenv(constant.DEAD_LETTER_BUCKET_NAME)
+	config.Region = os.Getenv(constant.DEAD_LETTER_BUCKET_REGION)
+	config.PubSubTopicName = os.Getenv(constant.DEAD_LETTER_PUBSUB_TOPIC_NAME)
+	return config
+}
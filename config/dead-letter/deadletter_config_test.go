@@ -0,0 +1,39 @@
+//go:build test
+// +build test
+
+package dead_letter
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_DeadLetterConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		envs := map[string]string{
+			constant.DEAD_LETTER_TYPE:              "s3",
+			constant.DEAD_LETTER_VOLUME_DIR:        "dead-letter/",
+			constant.DEAD_LETTER_BUCKET_NAME:       "my-bucket",
+			constant.DEAD_LETTER_BUCKET_REGION:     "ap-northeast-1",
+			constant.DEAD_LETTER_PUBSUB_TOPIC_NAME: "dead-letter-topic",
+		}
+		for k, v := range envs {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+		}
+
+		want := DeadLetter{
+			Type:            "s3",
+			Path:            "dead-letter/",
+			BucketName:      "my-bucket",
+			Region:          "ap-northeast-1",
+			PubSubTopicName: "dead-letter-topic",
+		}
+		if cfg := DeadLetterConfig(); !reflect.DeepEqual(want, cfg) {
+			t.Fatalf("Environment variable DEAD_LETTER_* is not acquired correctly. want: %v, got: %v", want, cfg)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+package destination
+
+import (
+	"os"
+	"strings"
+)
+
+// Getenv returns the environment variable key for the export destination dst.
+// {key}_{DESTINATION} (e.g. EXPORT_FORMAT_PUBSUB) overrides key for that destination.
+func Getenv(key, dst string) string {
+	if v, ok := os.LookupEnv(key + "_" + strings.ToUpper(dst)); ok {
+		return v
+	}
+	return os.Getenv(key)
+}
@@ -0,0 +1,49 @@
+//go:build test
+// +build test
+
+package destination
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"testing"
+)
+
+func Test_Getenv(t *testing.T) {
+	t.Setenv(constant.EXPORT_FORMAT, "json")
+	t.Setenv(constant.EXPORT_FORMAT+"_KINESISSTREAM", "avro")
+	t.Setenv(constant.EXPORT_EXTJSON_MODE+"_PUBSUB", "")
+
+	tests := []struct {
+		name string
+		key  string
+		dst  string
+		want string
+	}{
+		{
+			name: "Check to call the set environment variable.",
+			key:  constant.EXPORT_FORMAT,
+			dst:  "pubsub",
+			want: "json",
+		},
+		{
+			name: "Destination specific environment variable overrides the common one.",
+			key:  constant.EXPORT_FORMAT,
+			dst:  "kinesisStream",
+			want: "avro",
+		},
+		{
+			name: "Destination specific environment variable set empty overrides the common one.",
+			key:  constant.EXPORT_EXTJSON_MODE,
+			dst:  "pubsub",
+			want: "",
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := Getenv(v.key, v.dst); got != v.want {
+				t.Fatalf("Environment variable %s is not acquired correctly. want: %s, got: %s", v.key, v.want, got)
+			}
+		})
+	}
+}
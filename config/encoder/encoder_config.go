@@ -0,0 +1,34 @@
+package encoder
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/destination"
+)
+
+type Encoder struct {
+	Format      string
+	ExtJSONMode string
+	AvroSchema  string
+	// DebeziumName is the logical name of the connector in source.name of the Debezium format.
+	DebeziumName string
+	// DebeziumReplicaSet is source.rs of the Debezium format.
+	DebeziumReplicaSet string
+	// CloudEventsMode is structured or binary content mode of the CloudEvents format.
+	CloudEventsMode string
+	// CloudEventsTypePrefix is prepended to operationType for type of the CloudEvents format.
+	CloudEventsTypePrefix string
+}
+
+// EncoderConfig returns the format of the messages to the export destination.
+// EXPORT_*_{DESTINATION} (e.g. EXPORT_FORMAT_PUBSUB) overrides EXPORT_* for that destination.
+func EncoderConfig(dst string) Encoder {
+	var eCfg Encoder
+	eCfg.Format = destination.Getenv(constant.EXPORT_FORMAT, dst)
+	eCfg.ExtJSONMode = destination.Getenv(constant.EXPORT_EXTJSON_MODE, dst)
+	eCfg.AvroSchema = destination.Getenv(constant.EXPORT_AVRO_SCHEMA, dst)
+	eCfg.DebeziumName = destination.Getenv(constant.EXPORT_DEBEZIUM_NAME, dst)
+	eCfg.DebeziumReplicaSet = destination.Getenv(constant.EXPORT_DEBEZIUM_REPLICA_SET, dst)
+	eCfg.CloudEventsMode = destination.Getenv(constant.EXPORT_CLOUDEVENTS_MODE, dst)
+	eCfg.CloudEventsTypePrefix = destination.Getenv(constant.EXPORT_CLOUDEVENTS_TYPE_PREFIX, dst)
+	return eCfg
+}
@@ -0,0 +1,54 @@
+//go:build test
+// +build test
+
+package encoder
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_EncoderConfig(t *testing.T) {
+	envs := map[string]string{
+		constant.EXPORT_FORMAT:                         "json",
+		constant.EXPORT_EXTJSON_MODE:                   "canonical",
+		constant.EXPORT_FORMAT + "_KINESISSTREAM":      "avro",
+		constant.EXPORT_AVRO_SCHEMA + "_KINESISSTREAM": "referenced",
+		constant.EXPORT_DEBEZIUM_NAME:                  "mxt",
+		constant.EXPORT_DEBEZIUM_REPLICA_SET:           "rs0",
+		constant.EXPORT_CLOUDEVENTS_MODE + "_PUBSUB":   "binary",
+		constant.EXPORT_CLOUDEVENTS_TYPE_PREFIX:        "com.example.",
+	}
+	for k, v := range envs {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("Failed to set file %s environment variables.", k)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dst  string
+		want Encoder
+	}{
+		{
+			name: "Check to call the set environment variable.",
+			dst:  "pubsub",
+			want: Encoder{Format: "json", ExtJSONMode: "canonical", DebeziumName: "mxt", DebeziumReplicaSet: "rs0", CloudEventsMode: "binary", CloudEventsTypePrefix: "com.example."},
+		},
+		{
+			name: "Destination specific environment variable overrides the common one.",
+			dst:  "kinesisStream",
+			want: Encoder{Format: "avro", ExtJSONMode: "canonical", AvroSchema: "referenced", DebeziumName: "mxt", DebeziumReplicaSet: "rs0", CloudEventsTypePrefix: "com.example."},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := EncoderConfig(v.dst); !reflect.DeepEqual(v.want, got) {
+				t.Fatalf("Environment variable EXPORT_FORMAT, EXPORT_EXTJSON_MODE, EXPORT_AVRO_SCHEMA, EXPORT_DEBEZIUM_* or EXPORT_CLOUDEVENTS_* is not acquired correctly. want: %v, got: %v", v.want, got)
+			}
+		})
+	}
+}
@@ -3,14 +3,18 @@ package config
 import (
 	"fmt"
 	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/mongodb"
 	iff "github.com/cam-inc/mxtransporter/interfaces/file"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
 	"github.com/cam-inc/mxtransporter/pkg/logger"
 	"github.com/joho/godotenv"
 	"os"
 	"strconv"
+	"time"
 )
 
+const defaultShutdownTimeout = 30 * time.Second
+
 func init() {
 	// for runing locally
 	godotenv.Load()
@@ -18,15 +22,27 @@ func init() {
 
 func FetchResumeTokenFileName() (string, error) {
 	rtFileName, exists := os.LookupEnv(constant.RESUME_TOKEN_FILE_NAME)
-	if !exists {
-		// default value -> use watching collection name.
-		colName, exists := os.LookupEnv(constant.MONGODB_COLLECTION)
+	if exists {
+		return rtFileName, nil
+	}
+
+	// default value -> use the name of the watching scope.
+	switch mongodb.MongoConfig().WatchScope {
+	case mongodb.WatchScopeCluster:
+		return "cluster.dat", nil
+	case mongodb.WatchScopeDatabase:
+		dbName, exists := os.LookupEnv(constant.MONGODB_DATABASE)
 		if !exists {
-			return "", errors.InternalServerErrorEnvGet.New("MONGODB_COLLECTION is not existed in environment variables")
+			return "", errors.InternalServerErrorEnvGet.New("MONGODB_DATABASE is not existed in environment variables")
 		}
-		rtFileName = fmt.Sprintf("%s.dat", colName)
+		return fmt.Sprintf("%s.dat", dbName), nil
+	}
+
+	colName, exists := os.LookupEnv(constant.MONGODB_COLLECTION)
+	if !exists {
+		return "", errors.InternalServerErrorEnvGet.New("MONGODB_COLLECTION is not existed in environment variables")
 	}
-	return rtFileName, nil
+	return fmt.Sprintf("%s.dat", colName), nil
 }
 
 func FetchExportDestination() (string, error) {
@@ -54,6 +70,15 @@ func FetchTimeZone() (string, error) {
 	return tz, nil
 }
 
+// FetchShutdownTimeout returns how long the change streams already read are exported after a shutdown signal.
+func FetchShutdownTimeout() time.Duration {
+	sec, err := strconv.Atoi(os.Getenv(constant.SHUTDOWN_TIMEOUT_SEC))
+	if err != nil || sec <= 0 {
+		return defaultShutdownTimeout
+	}
+	return time.Duration(sec) * time.Second
+}
+
 func LogConfig() logger.Log {
 	var l logger.Log
 	l.Level = os.Getenv(constant.LOG_LEVEL)
@@ -8,6 +8,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_FetchExportDestination(t *testing.T) {
@@ -124,6 +125,38 @@ func Test_FetchTimeZone(t *testing.T) {
 	}
 }
 
+func Test_FetchShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Default value is used when the environment variable SHUTDOWN_TIMEOUT_SEC is not set.",
+			runner: func(t *testing.T) {
+				if e, a := defaultShutdownTimeout, FetchShutdownTimeout(); e != a {
+					t.Fatalf("Default shutdown timeout is wrong. want: %v, got: %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Check to call the set environment variable SHUTDOWN_TIMEOUT_SEC.",
+			runner: func(t *testing.T) {
+				if err := os.Setenv(constant.SHUTDOWN_TIMEOUT_SEC, "5"); err != nil {
+					t.Fatalf("Failed to set file SHUTDOWN_TIMEOUT_SEC environment variables.")
+				}
+				defer os.Unsetenv(constant.SHUTDOWN_TIMEOUT_SEC)
+				if e, a := 5*time.Second, FetchShutdownTimeout(); e != a {
+					t.Fatalf("Environment variable SHUTDOWN_TIMEOUT_SEC is not acquired correctly. want: %v, got: %v", e, a)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
 func Test_LogConfig(t *testing.T) {
 	t.Run("Check to call the set environment variable.", func(t *testing.T) {
 		level := "1"
@@ -189,6 +222,31 @@ func Test_FetchResumeTokenFileName(t *testing.T) {
 			t.Fatal("FetchResumeTokenFileName no error.")
 		}
 	})
+
+	t.Run("Default file name reflects the watching scope.", func(t *testing.T) {
+		defer os.Unsetenv(constant.MONGODB_WATCH_SCOPE)
+		defer os.Unsetenv(constant.MONGODB_DATABASE)
+
+		if err := os.Setenv(constant.MONGODB_WATCH_SCOPE, "database"); err != nil {
+			t.Fatalf("Failed to set file MONGODB_WATCH_SCOPE environment variables.")
+		}
+		if _, err := FetchResumeTokenFileName(); err == nil {
+			t.Fatal("FetchResumeTokenFileName no error without MONGODB_DATABASE.")
+		}
+		if err := os.Setenv(constant.MONGODB_DATABASE, "shop"); err != nil {
+			t.Fatalf("Failed to set file MONGODB_DATABASE environment variables.")
+		}
+		if name, err := FetchResumeTokenFileName(); err != nil || name != "shop.dat" {
+			t.Fatalf("Resume token file name of the database scope is wrong. got: %s, err: %v", name, err)
+		}
+
+		if err := os.Setenv(constant.MONGODB_WATCH_SCOPE, "cluster"); err != nil {
+			t.Fatalf("Failed to set file MONGODB_WATCH_SCOPE environment variables.")
+		}
+		if name, err := FetchResumeTokenFileName(); err != nil || name != "cluster.dat" {
+			t.Fatalf("Resume token file name of the cluster scope is wrong. got: %s, err: %v", name, err)
+		}
+	})
 }
 
 func Test_FileExportConfig(t *testing.T) {
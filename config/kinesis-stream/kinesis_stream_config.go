@@ -3,16 +3,29 @@ package kinesis_stream
 import (
 	"github.com/cam-inc/mxtransporter/config/constant"
 	"os"
+	"strconv"
 )
 
 type KinesisStream struct {
 	StreamName          string
 	KinesisStreamRegion string
+	PartitionKey        string
+	// SequenceNumberForOrdering puts the records one by one, chaining the sequence numbers per partition key.
+	SequenceNumberForOrdering bool
+	// PutRecords* are the retries of the records which PutRecords fails to put partially.
+	PutRecordsMaxAttempts     int
+	PutRecordsBaseBackoffMSec int
+	PutRecordsMaxBackoffMSec  int
 }
 
 func KinesisStreamConfig() KinesisStream {
 	var ksCfg KinesisStream
 	ksCfg.StreamName = os.Getenv(constant.KINESIS_STREAM_NAME)
 	ksCfg.KinesisStreamRegion = os.Getenv(constant.KINESIS_STREAM_REGION)
+	ksCfg.PartitionKey = os.Getenv(constant.KINESIS_PARTITION_KEY)
+	ksCfg.SequenceNumberForOrdering, _ = strconv.ParseBool(os.Getenv(constant.KINESIS_SEQUENCE_NUMBER_FOR_ORDERING))
+	ksCfg.PutRecordsMaxAttempts, _ = strconv.Atoi(os.Getenv(constant.KINESIS_PUT_RECORDS_MAX_ATTEMPTS))
+	ksCfg.PutRecordsBaseBackoffMSec, _ = strconv.Atoi(os.Getenv(constant.KINESIS_PUT_RECORDS_BASE_BACKOFF_MSEC))
+	ksCfg.PutRecordsMaxBackoffMSec, _ = strconv.Atoi(os.Getenv(constant.KINESIS_PUT_RECORDS_MAX_BACKOFF_MSEC))
 	return ksCfg
 }
@@ -4,6 +4,7 @@
 package kinesis_stream
 
 import (
+	"github.com/cam-inc/mxtransporter/config/constant"
 	"os"
 	"reflect"
 	"testing"
@@ -28,4 +29,25 @@ func Test_KinesisStreamConfig(t *testing.T) {
 			t.Fatal("Environment variable KINESIS_STREAM_REGION is not acquired correctly.")
 		}
 	})
+
+	t.Run("Check to call the set environment variables of the partition key.", func(t *testing.T) {
+		t.Setenv(constant.KINESIS_PARTITION_KEY, "fullDocument.tenantId")
+		t.Setenv(constant.KINESIS_SEQUENCE_NUMBER_FOR_ORDERING, "true")
+
+		ksCfg := KinesisStreamConfig()
+		if ksCfg.PartitionKey != "fullDocument.tenantId" || !ksCfg.SequenceNumberForOrdering {
+			t.Fatalf("Environment variable KINESIS_PARTITION_KEY or KINESIS_SEQUENCE_NUMBER_FOR_ORDERING is not acquired correctly. got: %v", ksCfg)
+		}
+	})
+
+	t.Run("Check to call the set environment variables of the retries of PutRecords.", func(t *testing.T) {
+		t.Setenv(constant.KINESIS_PUT_RECORDS_MAX_ATTEMPTS, "3")
+		t.Setenv(constant.KINESIS_PUT_RECORDS_BASE_BACKOFF_MSEC, "50")
+		t.Setenv(constant.KINESIS_PUT_RECORDS_MAX_BACKOFF_MSEC, "1000")
+
+		ksCfg := KinesisStreamConfig()
+		if ksCfg.PutRecordsMaxAttempts != 3 || ksCfg.PutRecordsBaseBackoffMSec != 50 || ksCfg.PutRecordsMaxBackoffMSec != 1000 {
+			t.Fatalf("Environment variable KINESIS_PUT_RECORDS_* is not acquired correctly. got: %v", ksCfg)
+		}
+	})
 }
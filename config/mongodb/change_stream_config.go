@@ -0,0 +1,35 @@
+package mongodb
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"strconv"
+)
+
+type ChangeStream struct {
+	ReconnectMaxAttempts     int
+	ReconnectBaseBackoffMSec int
+	ReconnectMaxBackoffMSec  int
+	HistoryLostPolicy        string
+	HistoryLostRestartAt     string
+	FullDocument             string
+	FullDocumentBeforeChange string
+	StartAtOperationTime     string
+	StartAfter               string
+	IgnoreResumeToken        bool
+}
+
+func ChangeStreamConfig() ChangeStream {
+	var csCfg ChangeStream
+	csCfg.ReconnectMaxAttempts, _ = strconv.Atoi(os.Getenv(constant.CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS))
+	csCfg.ReconnectBaseBackoffMSec, _ = strconv.Atoi(os.Getenv(constant.CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC))
+	csCfg.ReconnectMaxBackoffMSec, _ = strconv.Atoi(os.Getenv(constant.CHANGE_STREAM_RECONNECT_MAX_BACKOFF_MSEC))
+	csCfg.HistoryLostPolicy = os.Getenv(constant.CHANGE_STREAM_HISTORY_LOST_POLICY)
+	csCfg.HistoryLostRestartAt = os.Getenv(constant.CHANGE_STREAM_HISTORY_LOST_RESTART_AT)
+	csCfg.FullDocument = os.Getenv(constant.CHANGE_STREAM_FULL_DOCUMENT)
+	csCfg.FullDocumentBeforeChange = os.Getenv(constant.CHANGE_STREAM_FULL_DOCUMENT_BEFORE_CHANGE)
+	csCfg.StartAtOperationTime = os.Getenv(constant.CHANGE_STREAM_START_AT_OPERATION_TIME)
+	csCfg.StartAfter = os.Getenv(constant.CHANGE_STREAM_START_AFTER)
+	csCfg.IgnoreResumeToken, _ = strconv.ParseBool(os.Getenv(constant.CHANGE_STREAM_IGNORE_RESUME_TOKEN))
+	return csCfg
+}
@@ -0,0 +1,50 @@
+//go:build test
+// +build test
+
+package mongodb
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_ChangeStreamConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		envs := map[string]string{
+			constant.CHANGE_STREAM_RECONNECT_MAX_ATTEMPTS:      "5",
+			constant.CHANGE_STREAM_RECONNECT_BASE_BACKOFF_MSEC: "500",
+			constant.CHANGE_STREAM_RECONNECT_MAX_BACKOFF_MSEC:  "30000",
+			constant.CHANGE_STREAM_HISTORY_LOST_POLICY:         "timestamp",
+			constant.CHANGE_STREAM_HISTORY_LOST_RESTART_AT:     "2022-04-01T00:00:00Z",
+			constant.CHANGE_STREAM_FULL_DOCUMENT:               "whenAvailable",
+			constant.CHANGE_STREAM_FULL_DOCUMENT_BEFORE_CHANGE: "required",
+			constant.CHANGE_STREAM_START_AT_OPERATION_TIME:     "1648771200:1",
+			constant.CHANGE_STREAM_START_AFTER:                 "00000",
+			constant.CHANGE_STREAM_IGNORE_RESUME_TOKEN:         "true",
+		}
+		for k, v := range envs {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+			defer os.Unsetenv(k)
+		}
+
+		want := ChangeStream{
+			ReconnectMaxAttempts:     5,
+			ReconnectBaseBackoffMSec: 500,
+			ReconnectMaxBackoffMSec:  30000,
+			HistoryLostPolicy:        "timestamp",
+			HistoryLostRestartAt:     "2022-04-01T00:00:00Z",
+			FullDocument:             "whenAvailable",
+			FullDocumentBeforeChange: "required",
+			StartAtOperationTime:     "1648771200:1",
+			StartAfter:               "00000",
+			IgnoreResumeToken:        true,
+		}
+		if csCfg := ChangeStreamConfig(); !reflect.DeepEqual(want, csCfg) {
+			t.Fatalf("Environment variable CHANGE_STREAM_* is not acquired correctly. want: %v, got: %v", want, csCfg)
+		}
+	})
+}
@@ -5,10 +5,22 @@ import (
 	"os"
 )
 
+// The scopes of change streams set in MONGODB_WATCH_SCOPE.
+const (
+	WatchScopeCollection = "collection"
+	WatchScopeDatabase   = "database"
+	WatchScopeCluster    = "cluster"
+)
+
 type Mongo struct {
 	MongoDbConnectionUrl string
 	MongoDbDatabase      string
 	MongoDbCollection    string
+	WatchScope           string
+	NamespaceInclude     string
+	NamespaceExclude     string
+	Pipeline             string
+	PipelineFile         string
 }
 
 func MongoConfig() Mongo {
@@ -16,5 +28,13 @@ func MongoConfig() Mongo {
 	mCfg.MongoDbConnectionUrl = os.Getenv(constant.MONGODB_HOST)
 	mCfg.MongoDbDatabase = os.Getenv(constant.MONGODB_DATABASE)
 	mCfg.MongoDbCollection = os.Getenv(constant.MONGODB_COLLECTION)
+	mCfg.WatchScope = os.Getenv(constant.MONGODB_WATCH_SCOPE)
+	if mCfg.WatchScope == "" {
+		mCfg.WatchScope = WatchScopeCollection
+	}
+	mCfg.NamespaceInclude = os.Getenv(constant.MONGODB_NAMESPACE_INCLUDE)
+	mCfg.NamespaceExclude = os.Getenv(constant.MONGODB_NAMESPACE_EXCLUDE)
+	mCfg.Pipeline = os.Getenv(constant.MONGODB_PIPELINE)
+	mCfg.PipelineFile = os.Getenv(constant.MONGODB_PIPELINE_FILE)
 	return mCfg
 }
@@ -34,5 +34,41 @@ func Test_MongoConfig(t *testing.T) {
 		if e, a := mCfg.MongoDbCollection, mCollection; !reflect.DeepEqual(e, a) {
 			t.Fatal("Environment variable MONGODB_COLLECTION is not acquired correctly.")
 		}
+		if e, a := mCfg.WatchScope, WatchScopeCollection; !reflect.DeepEqual(e, a) {
+			t.Fatal("Default MONGODB_WATCH_SCOPE is not collection.")
+		}
+	})
+
+	t.Run("Check to call the set environment variable of the watching scope.", func(t *testing.T) {
+		envs := map[string]string{
+			"MONGODB_WATCH_SCOPE":       "cluster",
+			"MONGODB_NAMESPACE_INCLUDE": "shop,audit.logs",
+			"MONGODB_NAMESPACE_EXCLUDE": "/^shop\\.tmp_/",
+			"MONGODB_PIPELINE":          `[{"$match": {"operationType": "insert"}}]`,
+			"MONGODB_PIPELINE_FILE":     "/etc/mxt/pipeline.json",
+		}
+		for k, v := range envs {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+			defer os.Unsetenv(k)
+		}
+
+		mCfg := MongoConfig()
+		if e, a := mCfg.WatchScope, WatchScopeCluster; !reflect.DeepEqual(e, a) {
+			t.Fatal("Environment variable MONGODB_WATCH_SCOPE is not acquired correctly.")
+		}
+		if e, a := mCfg.NamespaceInclude, envs["MONGODB_NAMESPACE_INCLUDE"]; !reflect.DeepEqual(e, a) {
+			t.Fatal("Environment variable MONGODB_NAMESPACE_INCLUDE is not acquired correctly.")
+		}
+		if e, a := mCfg.NamespaceExclude, envs["MONGODB_NAMESPACE_EXCLUDE"]; !reflect.DeepEqual(e, a) {
+			t.Fatal("Environment variable MONGODB_NAMESPACE_EXCLUDE is not acquired correctly.")
+		}
+		if e, a := mCfg.Pipeline, envs["MONGODB_PIPELINE"]; !reflect.DeepEqual(e, a) {
+			t.Fatal("Environment variable MONGODB_PIPELINE is not acquired correctly.")
+		}
+		if e, a := mCfg.PipelineFile, envs["MONGODB_PIPELINE_FILE"]; !reflect.DeepEqual(e, a) {
+			t.Fatal("Environment variable MONGODB_PIPELINE_FILE is not acquired correctly.")
+		}
 	})
 }
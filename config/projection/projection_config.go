@@ -0,0 +1,22 @@
+package projection
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/destination"
+)
+
+type Projection struct {
+	Include string
+	Exclude string
+	Rename  string
+}
+
+// ProjectionConfig returns the projection of change streams for the export destination.
+// EXPORT_FIELDS_*_{DESTINATION} (e.g. EXPORT_FIELDS_INCLUDE_PUBSUB) overrides EXPORT_FIELDS_* for that destination.
+func ProjectionConfig(dst string) Projection {
+	var pCfg Projection
+	pCfg.Include = destination.Getenv(constant.EXPORT_FIELDS_INCLUDE, dst)
+	pCfg.Exclude = destination.Getenv(constant.EXPORT_FIELDS_EXCLUDE, dst)
+	pCfg.Rename = destination.Getenv(constant.EXPORT_FIELDS_RENAME, dst)
+	return pCfg
+}
@@ -0,0 +1,49 @@
+//go:build test
+// +build test
+
+package projection
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_ProjectionConfig(t *testing.T) {
+	envs := map[string]string{
+		constant.EXPORT_FIELDS_EXCLUDE:             "fullDocument.password",
+		constant.EXPORT_FIELDS_INCLUDE + "_PUBSUB": "operationType,fullDocument.name",
+		constant.EXPORT_FIELDS_RENAME + "_PUBSUB":  "fullDocument.name:fullDocument.userName",
+	}
+	for k, v := range envs {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("Failed to set file %s environment variables.", k)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dst  string
+		want Projection
+	}{
+		{
+			name: "Check to call the set environment variable.",
+			dst:  "bigquery",
+			want: Projection{Exclude: "fullDocument.password"},
+		},
+		{
+			name: "Destination specific environment variable overrides the common one.",
+			dst:  "pubsub",
+			want: Projection{Include: "operationType,fullDocument.name", Exclude: "fullDocument.password", Rename: "fullDocument.name:fullDocument.userName"},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := ProjectionConfig(v.dst); !reflect.DeepEqual(v.want, got) {
+				t.Fatalf("Environment variable EXPORT_FIELDS_* is not acquired correctly. want: %v, got: %v", v.want, got)
+			}
+		})
+	}
+}
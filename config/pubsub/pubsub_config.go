@@ -2,6 +2,7 @@ package pubsub
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/cam-inc/mxtransporter/config/constant"
 )
@@ -9,11 +10,32 @@ import (
 type PubSub struct {
 	TopicName  string
 	OrderingBy string
+	// Attributes is the comma separated attributes of messages by name=path, e.g. collection=ns.coll.
+	Attributes string
+	// Publish* are the PublishSettings of the topic.
+	PublishDelayThresholdMSec int
+	PublishCountThreshold     int
+	PublishByteThreshold      int
+	PublishNumGoroutines      int
+	PublishTimeoutMSec        int
+	PublishBufferedByteLimit  int
+	// MaxOutstanding* limit the messages published but not yet confirmed.
+	MaxOutstandingMessages int
+	MaxOutstandingBytes    int
 }
 
 func PubSubConfig() PubSub {
 	var psCfg PubSub
 	psCfg.TopicName = os.Getenv(constant.PUBSUB_TOPIC_NAME)
 	psCfg.OrderingBy = os.Getenv(constant.PUBSUB_ORDERING_BY)
+	psCfg.Attributes = os.Getenv(constant.PUBSUB_ATTRIBUTES)
+	psCfg.PublishDelayThresholdMSec, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_DELAY_THRESHOLD_MSEC))
+	psCfg.PublishCountThreshold, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_COUNT_THRESHOLD))
+	psCfg.PublishByteThreshold, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_BYTE_THRESHOLD))
+	psCfg.PublishNumGoroutines, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_NUM_GOROUTINES))
+	psCfg.PublishTimeoutMSec, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_TIMEOUT_MSEC))
+	psCfg.PublishBufferedByteLimit, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_PUBLISH_BUFFERED_BYTE_LIMIT))
+	psCfg.MaxOutstandingMessages, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_MAX_OUTSTANDING_MESSAGES))
+	psCfg.MaxOutstandingBytes, _ = strconv.Atoi(os.Getenv(constant.PUBSUB_MAX_OUTSTANDING_BYTES))
 	return psCfg
 }
@@ -17,10 +17,36 @@ func Test_PubSubConfig(t *testing.T) {
 		if err := os.Setenv("PUBSUB_ORDERING_BY", "yyy"); err != nil {
 			t.Fatalf("Failed to set file PUBSUB_ORDERING_BY environment variables.")
 		}
+		if err := os.Setenv("PUBSUB_ATTRIBUTES", "collection=ns.coll"); err != nil {
+			t.Fatalf("Failed to set file PUBSUB_ATTRIBUTES environment variables.")
+		}
+		for k, v := range map[string]string{
+			"PUBSUB_PUBLISH_DELAY_THRESHOLD_MSEC": "20",
+			"PUBSUB_PUBLISH_COUNT_THRESHOLD":      "200",
+			"PUBSUB_PUBLISH_BYTE_THRESHOLD":       "2000000",
+			"PUBSUB_PUBLISH_NUM_GOROUTINES":       "4",
+			"PUBSUB_PUBLISH_TIMEOUT_MSEC":         "30000",
+			"PUBSUB_PUBLISH_BUFFERED_BYTE_LIMIT":  "50000000",
+			"PUBSUB_MAX_OUTSTANDING_MESSAGES":     "1000",
+			"PUBSUB_MAX_OUTSTANDING_BYTES":        "10000000",
+		} {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+		}
 		psCfg := PubSubConfig()
 		want := PubSub{
-			TopicName:  "xxx",
-			OrderingBy: "yyy",
+			TopicName:                 "xxx",
+			OrderingBy:                "yyy",
+			Attributes:                "collection=ns.coll",
+			PublishDelayThresholdMSec: 20,
+			PublishCountThreshold:     200,
+			PublishByteThreshold:      2000000,
+			PublishNumGoroutines:      4,
+			PublishTimeoutMSec:        30000,
+			PublishBufferedByteLimit:  50000000,
+			MaxOutstandingMessages:    1000,
+			MaxOutstandingBytes:       10000000,
 		}
 		if !reflect.DeepEqual(want, psCfg) {
 			t.Fatalf("Environment variable PUBSUB_* is not acquired correctly. want: %v, got: %v", want, psCfg)
@@ -0,0 +1,20 @@
+package redaction
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+)
+
+type Redaction struct {
+	Rules     string
+	RulesFile string
+	HMACKey   string
+}
+
+func RedactionConfig() Redaction {
+	var rCfg Redaction
+	rCfg.Rules = os.Getenv(constant.REDACTION_RULES)
+	rCfg.RulesFile = os.Getenv(constant.REDACTION_RULES_FILE)
+	rCfg.HMACKey = os.Getenv(constant.REDACTION_HMAC_KEY)
+	return rCfg
+}
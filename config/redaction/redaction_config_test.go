@@ -0,0 +1,35 @@
+//go:build test
+// +build test
+
+package redaction
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_RedactionConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		envs := map[string]string{
+			constant.REDACTION_RULES:      `[{"field": "email", "action": "drop"}]`,
+			constant.REDACTION_RULES_FILE: "/etc/mxt/redaction.json",
+			constant.REDACTION_HMAC_KEY:   "secret",
+		}
+		for k, v := range envs {
+			if err := os.Setenv(k, v); err != nil {
+				t.Fatalf("Failed to set file %s environment variables.", k)
+			}
+		}
+
+		want := Redaction{
+			Rules:     `[{"field": "email", "action": "drop"}]`,
+			RulesFile: "/etc/mxt/redaction.json",
+			HMACKey:   "secret",
+		}
+		if cfg := RedactionConfig(); !reflect.DeepEqual(want, cfg) {
+			t.Fatalf("Environment variable REDACTION_* is not acquired correctly. want: %v, got: %v", want, cfg)
+		}
+	})
+}
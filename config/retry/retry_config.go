@@ -0,0 +1,27 @@
+package retry
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/config/destination"
+	"strconv"
+)
+
+type Retry struct {
+	MaxAttempts     int
+	BaseBackoffMSec int
+	MaxBackoffMSec  int
+	Jitter          bool
+	DeadlineSec     int
+}
+
+// RetryConfig returns the retry policy of the export destination.
+// EXPORT_RETRY_*_{DESTINATION} (e.g. EXPORT_RETRY_MAX_ATTEMPTS_BIGQUERY) overrides EXPORT_RETRY_* for that destination.
+func RetryConfig(dst string) Retry {
+	var rCfg Retry
+	rCfg.MaxAttempts, _ = strconv.Atoi(destination.Getenv(constant.EXPORT_RETRY_MAX_ATTEMPTS, dst))
+	rCfg.BaseBackoffMSec, _ = strconv.Atoi(destination.Getenv(constant.EXPORT_RETRY_BASE_BACKOFF_MSEC, dst))
+	rCfg.MaxBackoffMSec, _ = strconv.Atoi(destination.Getenv(constant.EXPORT_RETRY_MAX_BACKOFF_MSEC, dst))
+	rCfg.Jitter, _ = strconv.ParseBool(destination.Getenv(constant.EXPORT_RETRY_JITTER, dst))
+	rCfg.DeadlineSec, _ = strconv.Atoi(destination.Getenv(constant.EXPORT_RETRY_DEADLINE_SEC, dst))
+	return rCfg
+}
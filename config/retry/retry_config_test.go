@@ -0,0 +1,59 @@
+//go:build test
+// +build test
+
+package retry
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_RetryConfig(t *testing.T) {
+	envs := map[string]string{
+		constant.EXPORT_RETRY_MAX_ATTEMPTS:               "3",
+		constant.EXPORT_RETRY_BASE_BACKOFF_MSEC:          "100",
+		constant.EXPORT_RETRY_MAX_BACKOFF_MSEC:           "5000",
+		constant.EXPORT_RETRY_JITTER:                     "true",
+		constant.EXPORT_RETRY_DEADLINE_SEC:               "60",
+		constant.EXPORT_RETRY_MAX_ATTEMPTS + "_BIGQUERY": "10",
+		constant.EXPORT_RETRY_JITTER + "_KINESISSTREAM":  "false",
+		constant.EXPORT_RETRY_DEADLINE_SEC + "_BIGQUERY": "120",
+	}
+	for k, v := range envs {
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("Failed to set file %s environment variables.", k)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dst  string
+		want Retry
+	}{
+		{
+			name: "Check to call the set environment variable.",
+			dst:  "pubsub",
+			want: Retry{MaxAttempts: 3, BaseBackoffMSec: 100, MaxBackoffMSec: 5000, Jitter: true, DeadlineSec: 60},
+		},
+		{
+			name: "Destination specific environment variable overrides the common one.",
+			dst:  "bigquery",
+			want: Retry{MaxAttempts: 10, BaseBackoffMSec: 100, MaxBackoffMSec: 5000, Jitter: true, DeadlineSec: 120},
+		},
+		{
+			name: "Destination name is upper-cased.",
+			dst:  "kinesisStream",
+			want: Retry{MaxAttempts: 3, BaseBackoffMSec: 100, MaxBackoffMSec: 5000, Jitter: false, DeadlineSec: 60},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := RetryConfig(v.dst); !reflect.DeepEqual(v.want, got) {
+				t.Fatalf("Environment variable EXPORT_RETRY_* is not acquired correctly. want: %v, got: %v", v.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package routing
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+)
+
+type Routing struct {
+	Routes     string
+	RoutesFile string
+}
+
+// RoutingConfig returns the routes of change streams to the export destinations, given as JSON in EXPORT_ROUTES or in the file of EXPORT_ROUTES_FILE.
+func RoutingConfig() Routing {
+	var rCfg Routing
+	rCfg.Routes = os.Getenv(constant.EXPORT_ROUTES)
+	rCfg.RoutesFile = os.Getenv(constant.EXPORT_ROUTES_FILE)
+	return rCfg
+}
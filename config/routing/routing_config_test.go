@@ -0,0 +1,23 @@
+//go:build test
+// +build test
+
+package routing
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"reflect"
+	"testing"
+)
+
+func Test_RoutingConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		routes := `[{"match": {"operationType": "delete"}, "destinations": ["file"]}]`
+		t.Setenv(constant.EXPORT_ROUTES, routes)
+		t.Setenv(constant.EXPORT_ROUTES_FILE, "routes.json")
+
+		want := Routing{Routes: routes, RoutesFile: "routes.json"}
+		if got := RoutingConfig(); !reflect.DeepEqual(want, got) {
+			t.Fatalf("Environment variable EXPORT_ROUTES is not acquired correctly. want: %v, got: %v", want, got)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package snapshot
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"strconv"
+)
+
+type Snapshot struct {
+	Mode        string
+	Parallelism int
+	// CheckpointIntervalSec is the minimum interval of saving the progress of the snapshot.
+	CheckpointIntervalSec int
+}
+
+func SnapshotConfig() Snapshot {
+	var sCfg Snapshot
+	sCfg.Mode = os.Getenv(constant.SNAPSHOT_MODE)
+	sCfg.Parallelism, _ = strconv.Atoi(os.Getenv(constant.SNAPSHOT_PARALLELISM))
+	sCfg.CheckpointIntervalSec, _ = strconv.Atoi(os.Getenv(constant.SNAPSHOT_CHECKPOINT_INTERVAL_SEC))
+	return sCfg
+}
@@ -0,0 +1,37 @@
+//go:build test
+// +build test
+
+package snapshot
+
+import (
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_SnapshotConfig(t *testing.T) {
+	t.Run("Check to call the set environment variable.", func(t *testing.T) {
+		if err := os.Setenv(constant.SNAPSHOT_MODE, "initial"); err != nil {
+			t.Fatalf("Failed to set file SNAPSHOT_MODE environment variables.")
+		}
+		defer os.Unsetenv(constant.SNAPSHOT_MODE)
+		if err := os.Setenv(constant.SNAPSHOT_PARALLELISM, "8"); err != nil {
+			t.Fatalf("Failed to set file SNAPSHOT_PARALLELISM environment variables.")
+		}
+		defer os.Unsetenv(constant.SNAPSHOT_PARALLELISM)
+		if err := os.Setenv(constant.SNAPSHOT_CHECKPOINT_INTERVAL_SEC, "30"); err != nil {
+			t.Fatalf("Failed to set file SNAPSHOT_CHECKPOINT_INTERVAL_SEC environment variables.")
+		}
+		defer os.Unsetenv(constant.SNAPSHOT_CHECKPOINT_INTERVAL_SEC)
+
+		want := Snapshot{
+			Mode:                  "initial",
+			Parallelism:           8,
+			CheckpointIntervalSec: 30,
+		}
+		if sCfg := SnapshotConfig(); !reflect.DeepEqual(want, sCfg) {
+			t.Fatalf("Environment variable SNAPSHOT_* is not acquired correctly. want: %v, got: %v", want, sCfg)
+		}
+	})
+}
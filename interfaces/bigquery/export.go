@@ -3,10 +3,13 @@ package bigquery
 import (
 	"cloud.google.com/go/bigquery"
 	"context"
-	"encoding/json"
+	"fmt"
 	bigqueryConfig "github.com/cam-inc/mxtransporter/config/bigquery"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sync"
 	"time"
 )
 
@@ -18,6 +21,25 @@ type ChangeStreamTableSchema struct {
 	Ns                string
 	DocumentKey       string
 	UpdateDescription string
+	// FullDocumentBeforeChange is valid only when change streams are opened with pre-images.
+	FullDocumentBeforeChange bigquery.NullString
+}
+
+// Save implements bigquery.ValueSaver, so that FullDocumentBeforeChange is not inserted to tables without the column.
+func (s ChangeStreamTableSchema) Save() (map[string]bigquery.Value, string, error) {
+	row := map[string]bigquery.Value{
+		"ID":                s.ID,
+		"OperationType":     s.OperationType,
+		"ClusterTime":       s.ClusterTime,
+		"FullDocument":      s.FullDocument,
+		"Ns":                s.Ns,
+		"DocumentKey":       s.DocumentKey,
+		"UpdateDescription": s.UpdateDescription,
+	}
+	if s.FullDocumentBeforeChange.Valid {
+		row["FullDocumentBeforeChange"] = s.FullDocumentBeforeChange.StringVal
+	}
+	return row, "", nil
 }
 
 type (
@@ -27,58 +49,151 @@ type (
 
 	BigqueryImpl struct {
 		Bq bigqueryClient
+		// CanonicalExtJSON writes the documents in canonical Extended JSON instead of relaxed.
+		CanonicalExtJSON bool
+		// Dataset and Table are the names of the table per change stream, which are BIGQUERY_DATASET and BIGQUERY_TABLE if nil.
+		Dataset *naming.Template
+		Table   *naming.Template
 	}
 
 	BigqueryClientImpl struct {
 		BqClient *bigquery.Client
+
+		mu        sync.Mutex
+		inserters map[tableID]*bigquery.Inserter
+	}
+
+	tableID struct {
+		dataset string
+		table   string
+	}
+
+	// tableRecords are the records inserted to a table, in the order of change streams.
+	tableRecords struct {
+		tableID
+		csItems []ChangeStreamTableSchema
 	}
 )
 
+// putRecord inserts the records by the inserter of the table, which is created once per table and kept.
 func (b *BigqueryClientImpl) putRecord(ctx context.Context, dataset string, table string, csItems []ChangeStreamTableSchema) error {
-	return b.BqClient.Dataset(dataset).Table(table).Inserter().Put(ctx, csItems)
+	id := tableID{dataset, table}
+	b.mu.Lock()
+	inserter, ok := b.inserters[id]
+	if !ok {
+		inserter = b.BqClient.Dataset(dataset).Table(table).Inserter()
+		if b.inserters == nil {
+			b.inserters = make(map[tableID]*bigquery.Inserter)
+		}
+		b.inserters[id] = inserter
+	}
+	b.mu.Unlock()
+	return inserter.Put(ctx, csItems)
+}
+
+// ExportToBigquery inserts the change streams to their tables, one table after another.
+func (b *BigqueryImpl) ExportToBigquery(ctx context.Context, css []primitive.M) error {
+	dataset, table, err := b.templates()
+	if err != nil {
+		return err
+	}
+
+	var tables []*tableRecords
+	byID := make(map[tableID]*tableRecords)
+	for _, cs := range css {
+		csItem, err := toTableSchema(cs, b.CanonicalExtJSON)
+		if err != nil {
+			return err
+		}
+		var id tableID
+		if id.dataset, err = dataset.Execute(cs); err != nil {
+			return err
+		}
+		if id.table, err = table.Execute(cs); err != nil {
+			return err
+		}
+		tr, ok := byID[id]
+		if !ok {
+			tr = &tableRecords{tableID: id}
+			byID[id] = tr
+			tables = append(tables, tr)
+		}
+		tr.csItems = append(tr.csItems, csItem)
+	}
+
+	for _, tr := range tables {
+		if err := b.Bq.putRecord(ctx, tr.dataset, tr.table, tr.csItems); err != nil {
+			return errors.InternalServerErrorBigqueryInsert.Wrap(fmt.Sprintf("Failed to insert record to Bigquery %s.%s.", tr.dataset, tr.table), err)
+		}
+	}
+
+	return nil
 }
 
-func (b *BigqueryImpl) ExportToBigquery(ctx context.Context, cs primitive.M) error {
+func (b *BigqueryImpl) templates() (*naming.Template, *naming.Template, error) {
+	dataset, table := b.Dataset, b.Table
 	bqCfg := bigqueryConfig.BigqueryConfig()
+	var err error
+	if dataset == nil {
+		if dataset, err = naming.Parse(bqCfg.DataSet); err != nil {
+			return nil, nil, err
+		}
+	}
+	if table == nil {
+		if table, err = naming.Parse(bqCfg.Table); err != nil {
+			return nil, nil, err
+		}
+	}
+	return dataset, table, nil
+}
 
-	id, err := json.Marshal(cs["_id"])
+func toTableSchema(cs primitive.M, canonical bool) (ChangeStreamTableSchema, error) {
+	id, err := encoder.MarshalExtJSON(cs["_id"], canonical)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", err)
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", err)
 	}
-	opType := cs["operationType"].(string)
-	clusterTime := cs["clusterTime"].(primitive.Timestamp).T
-	fullDoc, err := json.Marshal(cs["fullDocument"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocument parameter.", err)
+	opType, ok := cs["operationType"].(string)
+	if !ok {
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.New(fmt.Sprintf("The operationType of change streams is not a string. got %v", cs["operationType"]))
+	}
+	clusterTime, ok := cs["clusterTime"].(primitive.Timestamp)
+	if !ok {
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.New(fmt.Sprintf("The clusterTime of change streams is not a timestamp. got %v", cs["clusterTime"]))
 	}
-	ns, err := json.Marshal(cs["ns"])
+	fullDoc, err := encoder.MarshalExtJSON(cs["fullDocument"], canonical)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json ns parameter.", err)
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocument parameter.", err)
 	}
-	docKey, err := json.Marshal(cs["documentKey"])
+	ns, err := encoder.MarshalExtJSON(cs["ns"], canonical)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json documentKey parameter.", err)
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json ns parameter.", err)
 	}
-	updDesc, err := json.Marshal(cs["updateDescription"])
+	docKey, err := encoder.MarshalExtJSON(cs["documentKey"], canonical)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json updateDescription parameter.", err)
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json documentKey parameter.", err)
 	}
-
-	csItems := []ChangeStreamTableSchema{
-		{
-			ID:                string(id),
-			OperationType:     opType,
-			ClusterTime:       time.Unix(int64(clusterTime), 0),
-			FullDocument:      string(fullDoc),
-			Ns:                string(ns),
-			DocumentKey:       string(docKey),
-			UpdateDescription: string(updDesc),
-		},
+	updDesc, err := encoder.MarshalExtJSON(cs["updateDescription"], canonical)
+	if err != nil {
+		return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json updateDescription parameter.", err)
 	}
 
-	if err := b.Bq.putRecord(ctx, bqCfg.DataSet, bqCfg.Table, csItems); err != nil {
-		return errors.InternalServerErrorBigqueryInsert.Wrap("Failed to insert record to Bigquery.", err)
+	var fullDocBefore bigquery.NullString
+	if v, ok := cs["fullDocumentBeforeChange"]; ok {
+		b, err := encoder.MarshalExtJSON(v, canonical)
+		if err != nil {
+			return ChangeStreamTableSchema{}, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocumentBeforeChange parameter.", err)
+		}
+		fullDocBefore = bigquery.NullString{StringVal: string(b), Valid: true}
 	}
 
-	return nil
+	return ChangeStreamTableSchema{
+		ID:                       string(id),
+		OperationType:            opType,
+		ClusterTime:              time.Unix(int64(clusterTime.T), 0),
+		FullDocument:             string(fullDoc),
+		Ns:                       string(ns),
+		DocumentKey:              string(docKey),
+		UpdateDescription:        string(updDesc),
+		FullDocumentBeforeChange: fullDocBefore,
+	}, nil
 }
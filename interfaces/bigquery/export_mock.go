@@ -15,6 +15,12 @@ type mockBigqueryClientImpl struct {
 	csItems  []ChangeStreamTableSchema
 }
 
+// mockBigqueryClientImplRecorder records the IDs of the records inserted to each table.
+type mockBigqueryClientImplRecorder struct {
+	tables map[string][]string
+	order  []string
+}
+
 type mockBigqueryClientImplError struct {
 	bqClient *bigquery.Client
 	csItems  []ChangeStreamTableSchema
@@ -34,3 +40,17 @@ func (m *mockBigqueryClientImpl) putRecord(_ context.Context, _ string, _ string
 func (m *mockBigqueryClientImplError) putRecord(_ context.Context, _ string, _ string, _ []ChangeStreamTableSchema) error {
 	return fmt.Errorf("Expected errors for error handling.")
 }
+
+func (m *mockBigqueryClientImplRecorder) putRecord(_ context.Context, dataset string, table string, csItems []ChangeStreamTableSchema) error {
+	if m.tables == nil {
+		m.tables = make(map[string][]string)
+	}
+	name := dataset + "." + table
+	if _, ok := m.tables[name]; !ok {
+		m.order = append(m.order, name)
+	}
+	for _, item := range csItems {
+		m.tables[name] = append(m.tables[name], item.ID)
+	}
+	return nil
+}
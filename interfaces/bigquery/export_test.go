@@ -4,9 +4,12 @@
 package bigquery
 
 import (
+	"cloud.google.com/go/bigquery"
 	"context"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -44,18 +47,120 @@ func Test_ExportToBigquery(t *testing.T) {
 			name: "Pass to put a record to bigquery.",
 			runner: func(t *testing.T) {
 				bqClientImpl := &mockBigqueryClientImpl{nil, testCsItems}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err != nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err != nil {
 					t.Fatalf("Testing Error, ErrorMessage: %v", err)
 				}
 			},
 		},
+		{
+			name: "Pass to put multiple records to bigquery at once.",
+			runner: func(t *testing.T) {
+				bqClientImpl := &mockBigqueryClientImpl{nil, append(testCsItems, testCsItems...)}
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap, csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to put a record with the pre-image to bigquery.",
+			runner: func(t *testing.T) {
+				csWithPreImage := primitive.M{"fullDocumentBeforeChange": primitive.M{"wwwww": "test before change"}}
+				for k, v := range csMap {
+					csWithPreImage[k] = v
+				}
+				items := []ChangeStreamTableSchema{testCsItems[0]}
+				items[0].FullDocumentBeforeChange = bigquery.NullString{StringVal: `{"wwwww":"test before change"}`, Valid: true}
+
+				bqClientImpl := &mockBigqueryClientImpl{nil, items}
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csWithPreImage}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to insert records to the table of the template per change stream.",
+			runner: func(t *testing.T) {
+				dataset, err := naming.Parse("{{.Database}}")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				table, err := naming.Parse("{{.Collection}}")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				event := func(data, db, coll string) primitive.M {
+					cs := primitive.M{}
+					for k, v := range csMap {
+						cs[k] = v
+					}
+					cs["_id"] = data
+					cs["ns"] = primitive.M{"db": db, "coll": coll}
+					return cs
+				}
+				bqClientImpl := &mockBigqueryClientImplRecorder{}
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, dataset, table}
+				css := []primitive.M{event("1", "shop", "orders"), event("2", "crm", "users"), event("3", "shop", "orders")}
+				if err := mockBqImpl.ExportToBigquery(ctx, css); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if e, a := []string{"shop.orders", "crm.users"}, bqClientImpl.order; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+				if e, a := []string{`"1"`, `"3"`}, bqClientImpl.tables["shop.orders"]; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Pass to keep the BSON types of the documents in canonical Extended JSON.",
+			runner: func(t *testing.T) {
+				d, _ := primitive.ParseDecimal128("12.30")
+				csTyped := primitive.M{}
+				for k, v := range csMap {
+					csTyped[k] = v
+				}
+				csTyped["fullDocument"] = primitive.M{"price": d, "count": int64(7)}
+				items := []ChangeStreamTableSchema{testCsItems[0]}
+				items[0].FullDocument = `{"count":{"$numberLong":"7"},"price":{"$numberDecimal":"12.30"}}`
+
+				bqClientImpl := &mockBigqueryClientImpl{nil, items}
+				mockBqImpl := BigqueryImpl{bqClientImpl, true, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csTyped}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to save FullDocumentBeforeChange only when it is valid.",
+			runner: func(t *testing.T) {
+				row, _, err := testCsItems[0].Save()
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if _, ok := row["FullDocumentBeforeChange"]; ok {
+					t.Fatalf("Not behaving as intended.")
+				}
+
+				item := testCsItems[0]
+				item.FullDocumentBeforeChange = bigquery.NullString{StringVal: "null", Valid: true}
+				row, _, err = item.Save()
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if row["FullDocumentBeforeChange"] != "null" || len(row) != 8 {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
 		{
 			name: "Failed to put a record to bigquery.",
 			runner: func(t *testing.T) {
 				bqClientImpl := &mockBigqueryClientImplError{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -75,8 +180,8 @@ func Test_ExportToBigquery(t *testing.T) {
 				}
 
 				bqClientImpl := &mockBigqueryClientImpl{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -96,8 +201,8 @@ func Test_ExportToBigquery(t *testing.T) {
 				}
 
 				bqClientImpl := &mockBigqueryClientImpl{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -117,8 +222,8 @@ func Test_ExportToBigquery(t *testing.T) {
 				}
 
 				bqClientImpl := &mockBigqueryClientImpl{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -138,8 +243,8 @@ func Test_ExportToBigquery(t *testing.T) {
 				}
 
 				bqClientImpl := &mockBigqueryClientImpl{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -159,12 +264,31 @@ func Test_ExportToBigquery(t *testing.T) {
 				}
 
 				bqClientImpl := &mockBigqueryClientImpl{nil, nil}
-				mockBqImpl := BigqueryImpl{bqClientImpl}
-				if err := mockBqImpl.ExportToBigquery(ctx, csMap); err == nil {
+				mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+				if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
 		},
+		{
+			name: "Failed by csMap without operationType or clusterTime.",
+			runner: func(t *testing.T) {
+				for _, field := range []string{"operationType", "clusterTime"} {
+					csMap := primitive.M{
+						"_id":           primitive.M{"_data": "00000"},
+						"operationType": "insert",
+						"clusterTime":   primitive.Timestamp{00000, 0},
+					}
+					delete(csMap, field)
+
+					bqClientImpl := &mockBigqueryClientImpl{nil, nil}
+					mockBqImpl := BigqueryImpl{bqClientImpl, false, nil, nil}
+					if err := mockBqImpl.ExportToBigquery(ctx, []primitive.M{csMap}); err == nil {
+						t.Fatalf("Not behaving as intended. field: %s", field)
+					}
+				}
+			},
+		},
 	}
 
 	for _, v := range tests {
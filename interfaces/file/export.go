@@ -3,6 +3,9 @@ package file
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -13,7 +16,8 @@ import (
 
 type (
 	Exporter interface {
-		Export(ctx context.Context, cs primitive.M) error
+		Export(ctx context.Context, css []primitive.M) error
+		Close() error
 	}
 
 	WriterType   string
@@ -30,24 +34,33 @@ type (
 		ChangeStreamKey string
 		TimeKey         string
 		NameKey         string
+		// CanonicalExtJSON writes the documents in canonical Extended JSON instead of relaxed.
+		CanonicalExtJSON bool
+		// Envelope, if set, writes change streams in its event envelope instead of the format of the file exporter.
+		Envelope encoder.Encoder
 	}
 
 	fileExporter struct {
 		config *ExporterConfig
 		log    *zap.Logger
+		// rotator is nil when writing to stdout.
+		rotator *lumberjack.Logger
 	}
 	timestamp struct {
 		time.Time
 		primitive.Timestamp
 	}
+	// csDoc has the documents in Extended JSON, so that BSON types are kept.
 	csDoc struct {
-		ID                primitive.M `json:"_id"`
-		OperationType     string      `json:"operationType"`
-		ClusterTime       timestamp   `json:"clusterTime"`
-		Ns                primitive.M `json:"ns"`
-		FullDocument      primitive.M `json:"fullDocument"`
-		DocumentKey       primitive.M `json:"documentKey"`
-		UpdateDescription primitive.M `json:"updateDescription"`
+		ID                json.RawMessage `json:"_id"`
+		OperationType     string          `json:"operationType"`
+		ClusterTime       timestamp       `json:"clusterTime"`
+		Ns                json.RawMessage `json:"ns"`
+		FullDocument      json.RawMessage `json:"fullDocument"`
+		DocumentKey       json.RawMessage `json:"documentKey"`
+		UpdateDescription json.RawMessage `json:"updateDescription"`
+		// FullDocumentBeforeChange is output only when change streams are opened with pre-images.
+		FullDocumentBeforeChange json.RawMessage `json:"fullDocumentBeforeChange,omitempty"`
 	}
 )
 
@@ -81,18 +94,69 @@ func (t *timestamp) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (f *fileExporter) Export(_ context.Context, cs primitive.M) error {
-	doc := &csDoc{}
-	byteArray, err := json.Marshal(cs)
-	if err != nil {
-		return err
+func (f *fileExporter) Export(_ context.Context, css []primitive.M) error {
+	for _, cs := range css {
+		if f.config.Envelope != nil {
+			b, err := f.config.Envelope.Encode(cs)
+			if err != nil {
+				return err
+			}
+			if b != nil {
+				f.log.Info("", zap.String("logType", f.config.LogType), zap.Reflect(f.config.ChangeStreamKey, json.RawMessage(b)))
+			}
+			continue
+		}
+
+		doc, err := f.toDoc(cs)
+		if err != nil {
+			return err
+		}
+
+		f.log.Info("", zap.String("logType", f.config.LogType), zap.Any(f.config.ChangeStreamKey, doc))
 	}
-	if err := json.Unmarshal(byteArray, doc); err != nil {
-		return err
+
+	return nil
+}
+
+func (f *fileExporter) toDoc(cs primitive.M) (*csDoc, error) {
+	doc := &csDoc{}
+	doc.OperationType, _ = cs["operationType"].(string)
+	if ts, ok := cs["clusterTime"].(primitive.Timestamp); ok {
+		doc.ClusterTime = timestamp{Time: time.Unix(int64(ts.T), 0), Timestamp: ts}
 	}
 
-	f.log.Info("", zap.String("logType", f.config.LogType), zap.Any(f.config.ChangeStreamKey, doc))
+	fields := map[string]*json.RawMessage{
+		"_id":               &doc.ID,
+		"ns":                &doc.Ns,
+		"fullDocument":      &doc.FullDocument,
+		"documentKey":       &doc.DocumentKey,
+		"updateDescription": &doc.UpdateDescription,
+	}
+	if _, ok := cs["fullDocumentBeforeChange"]; ok {
+		fields["fullDocumentBeforeChange"] = &doc.FullDocumentBeforeChange
+	}
+	for k, field := range fields {
+		b, err := encoder.MarshalExtJSON(cs[k], f.config.CanonicalExtJSON)
+		if err != nil {
+			return nil, errors.InternalServerErrorJsonMarshal.Wrap(fmt.Sprintf("Failed to marshal change streams json %s parameter.", k), err)
+		}
+		*field = b
+	}
+	return doc, nil
+}
 
+// Close closes the output file. Syncing stdout is not checked because it fails on some terminals.
+func (f *fileExporter) Close() error {
+	if f.rotator == nil {
+		_ = f.log.Sync()
+		return nil
+	}
+	if err := f.log.Sync(); err != nil {
+		return errors.InternalServerErrorFilePut.Wrap("Failed to sync file.", err)
+	}
+	if err := f.rotator.Close(); err != nil {
+		return errors.InternalServerErrorFilePut.Wrap("Failed to close file.", err)
+	}
 	return nil
 }
 
@@ -114,13 +178,15 @@ func New(cfg *ExporterConfig) Exporter {
 	}
 
 	writer := zapcore.WriteSyncer(os.Stdout)
+	var rotator *lumberjack.Logger
 	if convWriterType(cfg.Writer) != StdOut {
-		writer = zapcore.AddSync(&lumberjack.Logger{
+		rotator = &lumberjack.Logger{
 			Filename:   cfg.Writer,
 			MaxSize:    cfg.MaxMegaBytes,   //megabytes
 			MaxAge:     cfg.MaxDays,        //days
 			MaxBackups: cfg.MaxFileBackups, //files
-		})
+		}
+		writer = zapcore.AddSync(rotator)
 	}
 
 	encoder := zapcore.NewJSONEncoder(zconfig)
@@ -128,7 +194,8 @@ func New(cfg *ExporterConfig) Exporter {
 	log := zap.New(core)
 
 	return &fileExporter{
-		log:    log,
-		config: cfg,
+		log:     log,
+		config:  cfg,
+		rotator: rotator,
 	}
 }
@@ -4,7 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -17,11 +22,113 @@ func Test_FileExporter(t *testing.T) {
 			ChangeStreamKey: "changeStreamKey",
 		})
 
-		e.Export(context.Background(), primitive.M{
-			"_id": "xxxxxxxxxxxx",
+		e.Export(context.Background(), []primitive.M{
+			{
+				"_id": "xxxxxxxxxxxx",
+			},
 		})
 	})
 
+	t.Run("Close file writer", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cs.log")
+		e := New(&ExporterConfig{
+			WriterConfig: WriterConfig{Writer: path},
+		})
+		if err := e.Export(context.Background(), []primitive.M{{"_id": primitive.M{"_data": "00000"}}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(path); err != nil || len(b) == 0 {
+			t.Fatalf("change streams are not written to the file, err: %v", err)
+		}
+	})
+
+	t.Run("Output the pre-image only when it is in change streams", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cs.log")
+		e := New(&ExporterConfig{
+			ChangeStreamKey: "cs",
+			WriterConfig:    WriterConfig{Writer: path},
+		})
+		if err := e.Export(context.Background(), []primitive.M{
+			{"_id": primitive.M{"_data": "00000"}},
+			{"_id": primitive.M{"_data": "00001"}, "fullDocumentBeforeChange": nil},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != 2 || strings.Contains(lines[0], "fullDocumentBeforeChange") || !strings.Contains(lines[1], `"fullDocumentBeforeChange":null`) {
+			t.Fatalf("the pre-image is not output as intended, got: %s", b)
+		}
+	})
+
+	t.Run("Keep the BSON types of change streams in Extended JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cs.log")
+		e := New(&ExporterConfig{
+			ChangeStreamKey:  "cs",
+			WriterConfig:     WriterConfig{Writer: path},
+			CanonicalExtJSON: true,
+		})
+		oid, _ := primitive.ObjectIDFromHex("62a3f1a5e2a8b1c2d3e4f5a6")
+		if err := e.Export(context.Background(), []primitive.M{{
+			"_id":           primitive.M{"_data": "00000"},
+			"operationType": "insert",
+			"clusterTime":   primitive.Timestamp{T: 1650366482, I: 2},
+			"fullDocument":  primitive.M{"_id": oid, "count": int64(3)},
+		}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `"fullDocument":{"_id":{"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"},"count":{"$numberLong":"3"}}`; !strings.Contains(string(b), want) {
+			t.Fatalf("the documents are not output in Extended JSON, want: %s, got: %s", want, b)
+		}
+	})
+
+	t.Run("Output change streams in the envelope", func(t *testing.T) {
+		t.Setenv(constant.EXPORT_FORMAT, "debezium")
+		envelope, err := encoder.Envelope("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "cs.log")
+		e := New(&ExporterConfig{
+			ChangeStreamKey: "cs",
+			WriterConfig:    WriterConfig{Writer: path},
+			Envelope:        envelope,
+		})
+		if err := e.Export(context.Background(), []primitive.M{
+			{"_id": primitive.M{"_data": "00000"}, "operationType": "insert", "fullDocument": primitive.M{"name": "Alice"}},
+			{"_id": primitive.M{"_data": "00001"}, "operationType": "drop"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], `"cs":{"before":null,"after":"{\"name\":\"Alice\"}"`) || !strings.Contains(lines[0], `"op":"c"`) {
+			t.Fatalf("change streams are not output in the envelope, got: %s", b)
+		}
+	})
+
 	t.Run("Marshal and Unmarshal", func(t *testing.T) {
 		ts := timestamp{
 			Time: time.Now(),
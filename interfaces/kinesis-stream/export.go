@@ -2,89 +2,367 @@ package kinesis_stream
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
 	kinesisConfig "github.com/cam-inc/mxtransporter/config/kinesis-stream"
+	retryConfig "github.com/cam-inc/mxtransporter/config/retry"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
+	"github.com/cam-inc/mxtransporter/pkg/retry"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"strings"
 	"time"
 )
 
+const (
+	// maxPutRecordsEntries and maxPutRecordsBytes are the maximum number of records and the maximum size,
+	// the data and the partition keys, a single PutRecords request accepts.
+	maxPutRecordsEntries = 500
+	maxPutRecordsBytes   = 5 * 1024 * 1024
+
+	// defaultPutRecordsMaxAttempts is the attempts of the records PutRecords fails to put when KINESIS_PUT_RECORDS_MAX_ATTEMPTS is not set.
+	defaultPutRecordsMaxAttempts = 5
+
+	// maxSequenceNumbers is the number of partition keys whose last sequence numbers are kept for SequenceNumberForOrdering.
+	// Over it, they are forgotten, which only loses the chain to the records put before, already in order since they are put one by one.
+	maxSequenceNumbers = 10000
+)
+
+// retryableErrorCodes are the error codes of PutRecords entries which putting the record again may succeed.
+var retryableErrorCodes = map[string]struct{}{
+	"ProvisionedThroughputExceededException": {},
+	"InternalFailure":                        {},
+}
+
 type (
 	kinesisStreamClient interface {
-		putRecord(ctx context.Context, streamName string, rt interface{}, csArray []string) error
+		// putRecords puts the records by a single PutRecords request, and returns the error code of each record, empty for the ones put.
+		putRecords(ctx context.Context, streamName string, records []record) ([]string, error)
+		// putRecord puts the record by PutRecord after the record of sequenceNumberForOrdering, and returns its sequence number.
+		putRecord(ctx context.Context, streamName string, r record, sequenceNumberForOrdering string) (string, error)
 	}
 
 	KinesisStreamImpl struct {
 		KinesisStream kinesisStreamClient
+		// Encoder encodes the data of records, which is the legacy format if nil.
+		Encoder encoder.Encoder
+		// Stream is the name of the stream per change stream, which is KINESIS_STREAM_NAME if nil.
+		Stream *naming.Template
+		// Retry is the retries of the records PutRecords fails to put partially. The zero value does not retry.
+		Retry retry.Policy
+		// PartitionKey is the strategy of the partition keys, which is the resume token if nil.
+		PartitionKey *PartitionKey
+		// SequenceNumberForOrdering puts the records one by one by PutRecord, chaining the sequence numbers per partition key.
+		SequenceNumberForOrdering bool
+
+		buffers         []*streamRecords
+		sequenceNumbers map[sequenceKey]string
+	}
+
+	sequenceKey struct {
+		streamName   string
+		partitionKey string
+	}
+
+	// putRecordsError is the error of the records PutRecords fails to put. It keeps the error code of the records,
+	// so that the retry policy of the destination retries throttling and internal failures.
+	putRecordsError struct {
+		code string
+		msg  string
 	}
 
 	KinesisStreamClientImpl struct {
 		KinesisStreamClient *kinesis.Client
 	}
+
+	record struct {
+		partitionKey string
+		data         []byte
+	}
+
+	// streamRecords are the records buffered for a stream, in the order of change streams.
+	streamRecords struct {
+		streamName string
+		records    []record
+		size       int
+	}
 )
 
-func (k *KinesisStreamClientImpl) putRecord(ctx context.Context, streamName string, rt interface{}, csArray []string) error {
-	_, err := k.KinesisStreamClient.PutRecord(ctx, &kinesis.PutRecordInput{
-		Data:         []byte(strings.Join(csArray, "|") + "\n"),
-		PartitionKey: aws.String(rt.(string)),
-		StreamName:   aws.String(streamName),
+// NewRetryPolicy returns the retries of KINESIS_PUT_RECORDS_*, which attempts 5 times if not set.
+func NewRetryPolicy(cfg kinesisConfig.KinesisStream) retry.Policy {
+	maxAttempts := cfg.PutRecordsMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultPutRecordsMaxAttempts
+	}
+	return retry.NewPolicy(retryConfig.Retry{
+		MaxAttempts:     maxAttempts,
+		BaseBackoffMSec: cfg.PutRecordsBaseBackoffMSec,
+		MaxBackoffMSec:  cfg.PutRecordsMaxBackoffMSec,
+		Jitter:          true,
 	})
+}
 
-	return err
+func (k *KinesisStreamClientImpl) putRecord(ctx context.Context, streamName string, r record, sequenceNumberForOrdering string) (string, error) {
+	input := &kinesis.PutRecordInput{
+		Data:         r.data,
+		PartitionKey: aws.String(r.partitionKey),
+		StreamName:   aws.String(streamName),
+	}
+	if sequenceNumberForOrdering != "" {
+		input.SequenceNumberForOrdering = aws.String(sequenceNumberForOrdering)
+	}
+	out, err := k.KinesisStreamClient.PutRecord(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.SequenceNumber), nil
 }
 
-func (k *KinesisStreamImpl) ExportToKinesisStream(ctx context.Context, cs primitive.M) error {
-	ksCfg := kinesisConfig.KinesisStreamConfig()
+func (k *KinesisStreamClientImpl) putRecords(ctx context.Context, streamName string, records []record) ([]string, error) {
+	entries := make([]types.PutRecordsRequestEntry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, types.PutRecordsRequestEntry{
+			Data:         r.data,
+			PartitionKey: aws.String(r.partitionKey),
+		})
+	}
 
-	id, err := json.Marshal(cs["_id"])
+	out, err := k.KinesisStreamClient.PutRecords(ctx, &kinesis.PutRecordsInput{
+		Records:    entries,
+		StreamName: aws.String(streamName),
+	})
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", err)
+		return nil, err
 	}
-	opType := cs["operationType"].(string)
-	clusterTime := cs["clusterTime"].(primitive.Timestamp).T
-	fullDoc, err := json.Marshal(cs["fullDocument"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocument parameter.", err)
+
+	codes := make([]string, len(records))
+	for i, r := range out.Records {
+		if i < len(codes) {
+			codes[i] = aws.ToString(r.ErrorCode)
+		}
 	}
-	ns, err := json.Marshal(cs["ns"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json ns parameter.", err)
+	return codes, nil
+}
+
+// ExportToKinesisStream buffers the change streams for their streams, which are put by Flush.
+// A stream with records for a full PutRecords request is put without waiting for Flush.
+func (k *KinesisStreamImpl) ExportToKinesisStream(ctx context.Context, css []primitive.M) (err error) {
+	// The batch is exported again after a failure, so the records buffered from it must not be put twice.
+	defer func() {
+		if err != nil {
+			k.buffers = nil
+		}
+	}()
+
+	stream := k.Stream
+	if stream == nil {
+		if stream, err = naming.Parse(kinesisConfig.KinesisStreamConfig().StreamName); err != nil {
+			return err
+		}
 	}
-	docKey, err := json.Marshal(cs["documentKey"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json documentKey parameter.", err)
+
+	enc := k.Encoder
+	if enc == nil {
+		enc = encoder.Legacy()
 	}
-	updDesc, err := json.Marshal(cs["updateDescription"])
+	// Text formats are delimited by new lines, so that consumers can split the records aggregated in a file.
+	delimit := encoder.IsText(enc)
+
+	for _, cs := range css {
+		rt, r, err := toRecord(enc, cs)
+		if err != nil {
+			return err
+		}
+		// The change stream is not represented in the format.
+		if r == nil {
+			continue
+		}
+		if delimit {
+			r = append(r, '\n')
+		}
+		streamName, err := stream.Execute(cs)
+		if err != nil {
+			return err
+		}
+		resumeToken, ok := rt.(string)
+		if !ok {
+			return errors.InternalServerError.New("Failed to assert _data parameters of change streams.")
+		}
+		partitionKey := resumeToken
+		if k.PartitionKey != nil {
+			if partitionKey, err = k.PartitionKey.key(cs, resumeToken); err != nil {
+				return err
+			}
+		}
+
+		sr := k.buffer(streamName)
+		sr.records = append(sr.records, record{partitionKey: partitionKey, data: r})
+		sr.size += len(partitionKey) + len(r)
+		if len(sr.records) >= maxPutRecordsEntries || sr.size >= maxPutRecordsBytes {
+			if err := k.put(ctx, sr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Flush puts the buffered records, so that the resume token is saved after all of them are put.
+func (k *KinesisStreamImpl) Flush(ctx context.Context) error {
+	for _, sr := range k.buffers {
+		if err := k.put(ctx, sr); err != nil {
+			return err
+		}
+	}
+	k.buffers = nil
+	return nil
+}
+
+func (k *KinesisStreamImpl) buffer(streamName string) *streamRecords {
+	for _, sr := range k.buffers {
+		if sr.streamName == streamName {
+			return sr
+		}
+	}
+	sr := &streamRecords{streamName: streamName}
+	k.buffers = append(k.buffers, sr)
+	return sr
+}
+
+// put puts the buffered records of the stream by PutRecords, retrying the records failed by throttling or internal failures.
+// On a failure, all the buffered records are dropped, since the batch is exported again from the resume token.
+func (k *KinesisStreamImpl) put(ctx context.Context, sr *streamRecords) error {
+	var err error
+	if k.SequenceNumberForOrdering {
+		err = k.putRecordsInOrder(ctx, sr.streamName, sr.records)
+	} else {
+		err = k.putRecords(ctx, sr.streamName, sr.records)
+	}
+	sr.records, sr.size = nil, 0
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json updateDescription parameter.", err)
+		k.buffers = nil
+		return errors.InternalServerErrorKinesisStreamPut.Wrap(fmt.Sprintf("Failed to put message into kinesis stream %s.", sr.streamName), err)
 	}
+	return nil
+}
+
+func (k *KinesisStreamImpl) putRecords(ctx context.Context, streamName string, pending []record) error {
+	for attempt := 1; len(pending) > 0; {
+		var chunk []record
+		chunk, pending = nextChunk(pending)
+
+		codes, err := k.KinesisStream.putRecords(ctx, streamName, chunk)
+		if err != nil {
+			return err
+		}
+
+		// The records of a partition key from the first failed one are put again, including the ones put after it,
+		// so that the last record of the partition key in the stream is still the latest one.
+		var retried []record
+		var code string
+		failed := 0
+		failedKeys := make(map[string]struct{})
+		for i, c := range codes {
+			if c == "" {
+				if _, ok := failedKeys[chunk[i].partitionKey]; ok {
+					retried = append(retried, chunk[i])
+				}
+				continue
+			}
+			if _, ok := retryableErrorCodes[c]; !ok {
+				return &putRecordsError{code: c, msg: "a record failed to be put"}
+			}
+			failedKeys[chunk[i].partitionKey] = struct{}{}
+			retried = append(retried, chunk[i])
+			failed++
+			code = c
+		}
+		if failed == 0 {
+			attempt = 1
+			continue
+		}
 
-	r := []string{
-		string(id),
-		opType,
-		time.Unix(int64(clusterTime), 0).Format("2006-01-02 15:04:05"),
-		string(fullDoc),
-		string(ns),
-		string(docKey),
-		string(updDesc),
+		if attempt >= k.Retry.MaxAttempts {
+			return &putRecordsError{code: code, msg: fmt.Sprintf("%d of %d records failed to be put after %d attempts", failed, len(chunk), attempt)}
+		}
+		// A chunk is the head of the pending records, so the retried records are still before the rest.
+		pending = append(retried, pending...)
+
+		t := time.NewTimer(k.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		attempt++
+	}
+	return nil
+}
+
+func (e *putRecordsError) Error() string {
+	return fmt.Sprintf("%s with %s", e.msg, e.code)
+}
+
+// ErrorCode returns the error code of the records, e.g. ProvisionedThroughputExceededException.
+func (e *putRecordsError) ErrorCode() string {
+	return e.code
+}
+
+// RetryableError reports whether putting the records again may succeed, which the AWS SDK and errors.IsRetryable read.
+func (e *putRecordsError) RetryableError() bool {
+	_, ok := retryableErrorCodes[e.code]
+	return ok
+}
+
+// putRecordsInOrder puts the records one by one, each after the last record of its partition key by SequenceNumberForOrdering,
+// so that the sequence numbers of a partition key strictly increase. PutRecord is retried by the AWS SDK on throttling.
+func (k *KinesisStreamImpl) putRecordsInOrder(ctx context.Context, streamName string, records []record) error {
+	for _, r := range records {
+		sk := sequenceKey{streamName, r.partitionKey}
+		seq, err := k.KinesisStream.putRecord(ctx, streamName, r, k.sequenceNumbers[sk])
+		if err != nil {
+			return err
+		}
+		if k.sequenceNumbers == nil || len(k.sequenceNumbers) >= maxSequenceNumbers {
+			k.sequenceNumbers = make(map[sequenceKey]string)
+		}
+		k.sequenceNumbers[sk] = seq
+	}
+	return nil
+}
+
+// nextChunk returns the head of the pending records for a PutRecords request, and the rest.
+func nextChunk(pending []record) ([]record, []record) {
+	size := 0
+	for i, r := range pending {
+		n := len(r.partitionKey) + len(r.data)
+		if i == maxPutRecordsEntries || (i > 0 && size+n > maxPutRecordsBytes) {
+			return pending[:i], pending[i:]
+		}
+		size += n
+	}
+	return pending, nil
+}
+
+func toRecord(enc encoder.Encoder, cs primitive.M) (interface{}, []byte, error) {
+	r, err := enc.Encode(cs)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	pm, ok := cs["_id"].(primitive.M)
 	if !ok {
-		return errors.InternalServerError.New("Failed to assert _id parameters of change streams.")
+		return nil, nil, errors.InternalServerError.New("Failed to assert _id parameters of change streams.")
 	}
 
 	rt, exists := pm["_data"]
 	if !exists {
-		return errors.InternalServerError.New("Failed to get _data parameters of change streams.")
-	}
-
-	if err := k.KinesisStream.putRecord(ctx, ksCfg.StreamName, rt, r); err != nil {
-		return errors.InternalServerErrorKinesisStreamPut.Wrap("Failed to put message into kinesis stream.", err)
+		return nil, nil, errors.InternalServerError.New("Failed to get _data parameters of change streams.")
 	}
 
-	return nil
+	return rt, r, nil
 }
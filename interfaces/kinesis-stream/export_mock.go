@@ -7,7 +7,12 @@ import (
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/cam-inc/mxtransporter/pkg/retry"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type mockKinesisStreamClientImpl struct {
@@ -16,25 +21,103 @@ type mockKinesisStreamClientImpl struct {
 	cs                  []string
 }
 
+// mockKinesisStreamClientImplRecorder records the partition keys put into each stream.
+type mockKinesisStreamClientImplRecorder struct {
+	streams map[string][]interface{}
+	order   []string
+}
+
 type mockKinesisStreamClientImplError struct {
 	kinesisStreamClient *kinesis.Client
 	rt                  string
 	cs                  []string
 }
 
-func (m *mockKinesisStreamClientImpl) putRecord(_ context.Context, _ string, rt interface{}, csArray []string) error {
-	if csArray == nil {
-		return fmt.Errorf("Expect csItems to not be nil.")
+// mockKinesisStreamClientImplPartial fails the records of the partition keys in failures, with the error code, as many times as set,
+// and records the partition keys of every request.
+type mockKinesisStreamClientImplPartial struct {
+	failures map[string][]string
+	requests [][]string
+	// puts are the partition keys put by PutRecord with the sequence numbers for ordering, e.g. a>1.
+	puts []string
+}
+
+func (m *mockKinesisStreamClientImpl) putRecords(_ context.Context, _ string, records []record) ([]string, error) {
+	if records == nil {
+		return nil, fmt.Errorf("Expect csItems to not be nil.")
 	}
-	if e, a := m.rt, rt; !reflect.DeepEqual(e, a) {
-		return fmt.Errorf("expect %v, got %v", e, a)
+	for _, r := range records {
+		if e, a := m.rt, r.partitionKey; !reflect.DeepEqual(e, a) {
+			return nil, fmt.Errorf("expect %v, got %v", e, a)
+		}
+		if e, a := strings.Join(m.cs, "|")+"\n", string(r.data); !reflect.DeepEqual(e, a) {
+			return nil, fmt.Errorf("expect %v, got %v", e, a)
+		}
 	}
-	if e, a := m.cs, csArray; !reflect.DeepEqual(e, a) {
-		return fmt.Errorf("expect %v, got %v", e, a)
+	return make([]string, len(records)), nil
+}
+
+func (m *mockKinesisStreamClientImpl) putRecord(ctx context.Context, streamName string, r record, _ string) (string, error) {
+	if _, err := m.putRecords(ctx, streamName, []record{r}); err != nil {
+		return "", err
 	}
-	return nil
+	return "1", nil
+}
+
+func (m *mockKinesisStreamClientImplError) putRecord(_ context.Context, _ string, _ record, _ string) (string, error) {
+	return "", fmt.Errorf("Expected errors for error handling.")
+}
+
+func (m *mockKinesisStreamClientImplRecorder) putRecord(ctx context.Context, streamName string, r record, _ string) (string, error) {
+	if _, err := m.putRecords(ctx, streamName, []record{r}); err != nil {
+		return "", err
+	}
+	return "1", nil
+}
+
+func (m *mockKinesisStreamClientImplPartial) putRecord(_ context.Context, _ string, r record, sequenceNumberForOrdering string) (string, error) {
+	m.puts = append(m.puts, r.partitionKey+">"+sequenceNumberForOrdering)
+	return strconv.Itoa(len(m.puts)), nil
 }
 
-func (m *mockKinesisStreamClientImplError) putRecord(_ context.Context, _ string, _ interface{}, _ []string) error {
-	return fmt.Errorf("Expected errors for error handling.")
+func (m *mockKinesisStreamClientImplError) putRecords(_ context.Context, _ string, _ []record) ([]string, error) {
+	return nil, fmt.Errorf("Expected errors for error handling.")
+}
+
+func (m *mockKinesisStreamClientImplRecorder) putRecords(_ context.Context, streamName string, records []record) ([]string, error) {
+	if m.streams == nil {
+		m.streams = make(map[string][]interface{})
+	}
+	if _, ok := m.streams[streamName]; !ok {
+		m.order = append(m.order, streamName)
+	}
+	for _, r := range records {
+		m.streams[streamName] = append(m.streams[streamName], r.partitionKey)
+	}
+	return make([]string, len(records)), nil
+}
+
+func (m *mockKinesisStreamClientImplPartial) putRecords(_ context.Context, _ string, records []record) ([]string, error) {
+	codes := make([]string, len(records))
+	keys := make([]string, len(records))
+	for i, r := range records {
+		keys[i] = r.partitionKey
+		if f := m.failures[r.partitionKey]; len(f) > 0 {
+			codes[i] = f[0]
+			m.failures[r.partitionKey] = f[1:]
+		}
+	}
+	m.requests = append(m.requests, keys)
+	return codes, nil
+}
+
+// testRetry retries without waiting long.
+var testRetry = retry.Policy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+// exportAndFlush exports css and puts the records, as the exporter does for a batch.
+func exportAndFlush(ctx context.Context, k *KinesisStreamImpl, css []primitive.M) error {
+	if err := k.ExportToKinesisStream(ctx, css); err != nil {
+		return err
+	}
+	return k.Flush(ctx)
 }
@@ -5,8 +5,15 @@ package kinesis_stream
 
 import (
 	"context"
+	"fmt"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	kinesisConfig "github.com/cam-inc/mxtransporter/config/kinesis-stream"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -44,18 +51,198 @@ func Test_ExportToKinesisStream(t *testing.T) {
 			name: "Pass to put a record to kinesis data streams.",
 			runner: func(t *testing.T) {
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, testRt, testCsArray}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err != nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err != nil {
 					t.Fatalf("Testing Error, ErrorMessage: %v", err)
 				}
 			},
 		},
+		{
+			name: "Pass to put multiple records to kinesis data streams at once.",
+			runner: func(t *testing.T) {
+				ksClientImpl := &mockKinesisStreamClientImpl{nil, testRt, testCsArray}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap, csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to append the pre-image to the message.",
+			runner: func(t *testing.T) {
+				csWithPreImage := primitive.M{"fullDocumentBeforeChange": primitive.M{"wwwww": "test before change"}}
+				for k, v := range csMap {
+					csWithPreImage[k] = v
+				}
+				ksClientImpl := &mockKinesisStreamClientImpl{nil, testRt, append(testCsArray, `{"wwwww":"test before change"}`)}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csWithPreImage}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to skip change streams which the format does not represent.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "debezium")
+				enc, err := encoder.New("kinesisStream")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				ksClientImpl := &mockKinesisStreamClientImplError{nil, testRt, testCsArray}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl, Encoder: enc}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{{"_id": primitive.M{"_data": "00001"}, "operationType": "drop"}}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to put records into the stream of the template per change stream.",
+			runner: func(t *testing.T) {
+				stream, err := naming.Parse("{{.Collection}}_{{.OperationType}}")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				event := func(data, coll, opType string) primitive.M {
+					return primitive.M{
+						"_id":           primitive.M{"_data": data},
+						"operationType": opType,
+						"clusterTime":   primitive.Timestamp{},
+						"ns":            primitive.M{"db": "shop", "coll": coll},
+					}
+				}
+				ksClientImpl := &mockKinesisStreamClientImplRecorder{}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl, Stream: stream}
+				css := []primitive.M{event("1", "orders", "insert"), event("2", "users", "insert"), event("3", "orders", "insert"), event("4", "orders", "delete")}
+				if err := exportAndFlush(ctx, &mockKsImpl, css); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if e, a := []string{"orders_insert", "users_insert", "orders_delete"}, ksClientImpl.order; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+				if e, a := []interface{}{"1", "3"}, ksClientImpl.streams["orders_insert"]; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Pass to buffer the records until Flush, or a full PutRecords request.",
+			runner: func(t *testing.T) {
+				event := func(data string) primitive.M {
+					return primitive.M{"_id": primitive.M{"_data": data}, "operationType": "insert", "clusterTime": primitive.Timestamp{}}
+				}
+				ksClientImpl := &mockKinesisStreamClientImplRecorder{}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := mockKsImpl.ExportToKinesisStream(ctx, []primitive.M{event("1"), event("2")}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(ksClientImpl.order) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: the records are put before Flush.")
+				}
+				if err := mockKsImpl.Flush(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if e, a := []interface{}{"1", "2"}, ksClientImpl.streams[""]; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+
+				ksClientImpl = &mockKinesisStreamClientImplRecorder{}
+				mockKsImpl = KinesisStreamImpl{KinesisStream: ksClientImpl}
+				css := make([]primitive.M, 0, maxPutRecordsEntries+1)
+				for i := 0; i <= maxPutRecordsEntries; i++ {
+					css = append(css, event(fmt.Sprintf("%05d", i)))
+				}
+				if err := mockKsImpl.ExportToKinesisStream(ctx, css); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(ksClientImpl.streams[""]) != maxPutRecordsEntries {
+					t.Fatalf("Testing Error, ErrorMessage: put %d records before Flush.", len(ksClientImpl.streams[""]))
+				}
+			},
+		},
+		{
+			name: "Pass to retry the records of a partition key from the one failed by throttling or internal failures, in order.",
+			runner: func(t *testing.T) {
+				ksClientImpl := &mockKinesisStreamClientImplPartial{failures: map[string][]string{
+					"a": {"ProvisionedThroughputExceededException"},
+					"b": {"InternalFailure", "InternalFailure"},
+				}}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl, Retry: testRetry}
+				records := []record{{partitionKey: "a"}, {partitionKey: "b"}, {partitionKey: "c"}, {partitionKey: "a"}}
+				if err := mockKsImpl.putRecords(ctx, "stream", records); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if e, a := [][]string{{"a", "b", "c", "a"}, {"a", "b", "a"}, {"b"}}, ksClientImpl.requests; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+			},
+		},
+		{
+			name: "Pass to put the records one by one, chaining the sequence numbers per partition key.",
+			runner: func(t *testing.T) {
+				partitionKey, err := ParsePartitionKey("documentKey")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				event := func(data, id string) primitive.M {
+					return primitive.M{
+						"_id":           primitive.M{"_data": data},
+						"operationType": "update",
+						"clusterTime":   primitive.Timestamp{},
+						"documentKey":   primitive.M{"_id": id},
+					}
+				}
+				ksClientImpl := &mockKinesisStreamClientImplPartial{}
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl, PartitionKey: partitionKey, SequenceNumberForOrdering: true}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{event("1", "a"), event("2", "b"), event("3", "a")}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{event("4", "b")}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if e, a := []string{"a>", "b>", "a>1", "b>2"}, ksClientImpl.puts; !reflect.DeepEqual(e, a) {
+					t.Fatalf("expect %v, got %v", e, a)
+				}
+				if len(ksClientImpl.requests) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: PutRecords is called.")
+				}
+			},
+		},
+		{
+			name: "Failed to put the records failed more than the attempts, or by an error which retrying cannot fix.",
+			runner: func(t *testing.T) {
+				event := primitive.M{"_id": primitive.M{"_data": "a"}, "operationType": "insert", "clusterTime": primitive.Timestamp{}}
+				for _, c := range []struct {
+					failures  []string
+					retryable bool
+				}{
+					{[]string{"InternalFailure", "InternalFailure", "InternalFailure"}, true},
+					{[]string{"ProvisionedThroughputExceededException", "ProvisionedThroughputExceededException", "ProvisionedThroughputExceededException"}, true},
+					{[]string{"ValidationException"}, false},
+				} {
+					ksClientImpl := &mockKinesisStreamClientImplPartial{failures: map[string][]string{"a": c.failures}}
+					mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl, Retry: testRetry}
+					err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{event})
+					if err == nil {
+						t.Fatalf("Not behaving as intended.")
+					}
+					// The destination retries throttling and internal failures as the other transient errors.
+					if errors.IsRetryable(err) != c.retryable {
+						t.Fatalf("Testing Error, ErrorMessage: %v is retryable: %v", err, errors.IsRetryable(err))
+					}
+					requests := len(ksClientImpl.requests)
+					if err := mockKsImpl.Flush(ctx); err != nil || len(ksClientImpl.requests) != requests {
+						t.Fatalf("Testing Error, ErrorMessage: the records are left after the failure.")
+					}
+				}
+			},
+		},
 		{
 			name: "Failed to put a record to kinesis data streams.",
 			runner: func(t *testing.T) {
 				ksClientImpl := &mockKinesisStreamClientImplError{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -75,8 +262,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -96,8 +283,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -117,8 +304,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -138,8 +325,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -159,8 +346,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -180,8 +367,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -201,8 +388,8 @@ func Test_ExportToKinesisStream(t *testing.T) {
 				}
 
 				ksClientImpl := &mockKinesisStreamClientImpl{nil, "", nil}
-				mockKsImpl := KinesisStreamImpl{ksClientImpl}
-				if err := mockKsImpl.ExportToKinesisStream(ctx, csMap); err == nil {
+				mockKsImpl := KinesisStreamImpl{KinesisStream: ksClientImpl}
+				if err := exportAndFlush(ctx, &mockKsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -213,3 +400,60 @@ func Test_ExportToKinesisStream(t *testing.T) {
 		t.Run(v.name, v.runner)
 	}
 }
+
+func Test_nextChunk(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to limit a request by the number of records.",
+			runner: func(t *testing.T) {
+				records := make([]record, 0, maxPutRecordsEntries+1)
+				for i := 0; i <= maxPutRecordsEntries; i++ {
+					records = append(records, record{partitionKey: fmt.Sprintf("%05d", i)})
+				}
+				chunk, rest := nextChunk(records)
+				if len(chunk) != maxPutRecordsEntries || len(rest) != 1 || rest[0].partitionKey != fmt.Sprintf("%05d", maxPutRecordsEntries) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to limit a request by the size of records.",
+			runner: func(t *testing.T) {
+				data := make([]byte, 2*1024*1024)
+				records := []record{{partitionKey: "a", data: data}, {partitionKey: "b", data: data}, {partitionKey: "c", data: data}}
+				chunk, rest := nextChunk(records)
+				if len(chunk) != 2 || len(rest) != 1 || rest[0].partitionKey != "c" {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to put the records of a partition key in a request.",
+			runner: func(t *testing.T) {
+				records := []record{{partitionKey: "a"}, {partitionKey: "a"}, {partitionKey: "b"}}
+				chunk, rest := nextChunk(records)
+				if len(chunk) != 3 || len(rest) != 0 {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
+func Test_NewRetryPolicy(t *testing.T) {
+	t.Run("Check to attempt 5 times by default.", func(t *testing.T) {
+		if p := NewRetryPolicy(kinesisConfig.KinesisStream{}); p.MaxAttempts != defaultPutRecordsMaxAttempts {
+			t.Fatalf("Not behaving as intended. got: %v", p)
+		}
+		if p := NewRetryPolicy(kinesisConfig.KinesisStream{PutRecordsMaxAttempts: 2}); p.MaxAttempts != 2 {
+			t.Fatalf("Not behaving as intended. got: %v", p)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package kinesis_stream
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/fieldpath"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	partitionKeyResumeToken = "resumeToken"
+	partitionKeyDocumentKey = "documentKey"
+	partitionKeyNamespace   = "namespace"
+	partitionKeyConstant    = "constant:"
+	partitionKeyField       = "fullDocument."
+
+	// maxPartitionKeyLength is the maximum number of characters of partition keys Kinesis Data Streams accepts.
+	maxPartitionKeyLength = 256
+)
+
+// PartitionKey is the strategy of the partition keys of records.
+type PartitionKey struct {
+	strategy string
+	path     []string
+	value    string
+}
+
+// ParsePartitionKey parses KINESIS_PARTITION_KEY, which is resumeToken (default), documentKey, namespace,
+// a dotted path of fullDocument, e.g. fullDocument.tenantId, or constant:{value}.
+func ParsePartitionKey(s string) (*PartitionKey, error) {
+	switch {
+	case s == "" || s == partitionKeyResumeToken:
+		return &PartitionKey{strategy: partitionKeyResumeToken}, nil
+	case s == partitionKeyDocumentKey || s == partitionKeyNamespace:
+		return &PartitionKey{strategy: s}, nil
+	case strings.HasPrefix(s, partitionKeyConstant):
+		value := strings.TrimPrefix(s, partitionKeyConstant)
+		if value == "" || utf8.RuneCountInString(value) > maxPartitionKeyLength {
+			return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The constant of KINESIS_PARTITION_KEY must be 1 to 256 characters. you set %s", s))
+		}
+		return &PartitionKey{strategy: partitionKeyConstant, value: value}, nil
+	case strings.HasPrefix(s, partitionKeyField):
+		path, err := fieldpath.Parse(s)
+		if err != nil {
+			return nil, errors.InternalServerErrorEnvGet.Wrap("KINESIS_PARTITION_KEY must be a dotted path of fullDocument.", err)
+		}
+		return &PartitionKey{strategy: partitionKeyField, path: path}, nil
+	}
+	return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("KINESIS_PARTITION_KEY must be resumeToken, documentKey, namespace, fullDocument.{path} or constant:{value}. you set %s", s))
+}
+
+// SingleShard reports whether every record has the same partition key, and so is put into one shard.
+func (p *PartitionKey) SingleShard() bool {
+	return p.strategy == partitionKeyConstant
+}
+
+// key returns the partition key of cs, whose resume token is rt.
+// Change streams without the value, e.g. deletes for a path of fullDocument or drops for documentKey, are keyed by rt.
+func (p *PartitionKey) key(cs primitive.M, rt string) (string, error) {
+	var v interface{}
+	switch p.strategy {
+	case partitionKeyConstant:
+		return p.value, nil
+	case partitionKeyDocumentKey:
+		if dk, ok := cs["documentKey"].(primitive.M); ok {
+			v = dk["_id"]
+		}
+	case partitionKeyNamespace:
+		if ns, ok := cs["ns"].(primitive.M); ok {
+			db, _ := ns["db"].(string)
+			coll, _ := ns["coll"].(string)
+			if db != "" {
+				v = strings.TrimSuffix(db+"."+coll, ".")
+			}
+		}
+	case partitionKeyField:
+		v, _ = fieldpath.Lookup(cs, p.path)
+	}
+	if v == nil {
+		return rt, nil
+	}
+
+	key, err := encoder.StringValue(v)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return rt, nil
+	}
+	// A longer key is hashed as Kinesis Data Streams does to map it to a shard, so that the same value is still the same shard.
+	if utf8.RuneCountInString(key) > maxPartitionKeyLength {
+		sum := md5.Sum([]byte(key))
+		key = hex.EncodeToString(sum[:])
+	}
+	return key, nil
+}
@@ -0,0 +1,105 @@
+//go:build test
+// +build test
+
+package kinesis_stream
+
+import (
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_PartitionKey(t *testing.T) {
+	oid, _ := primitive.ObjectIDFromHex("62b2f4e1a3bbc7b5f4a1d2c3")
+	cs := primitive.M{
+		"_id":          primitive.M{"_data": "00001"},
+		"ns":           primitive.M{"db": "shop", "coll": "orders"},
+		"documentKey":  primitive.M{"_id": oid},
+		"fullDocument": primitive.M{"tenantId": int64(7), "owner": primitive.M{"name": "Alice"}},
+	}
+	deleted := primitive.M{"_id": primitive.M{"_data": "00002"}, "operationType": "drop", "ns": primitive.M{"db": "shop", "coll": "orders"}}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to key change streams by the strategy.",
+			runner: func(t *testing.T) {
+				for s, want := range map[string]string{
+					"":                        "00001",
+					"resumeToken":             "00001",
+					"documentKey":             "62b2f4e1a3bbc7b5f4a1d2c3",
+					"namespace":               "shop.orders",
+					"fullDocument.tenantId":   "7",
+					"fullDocument.owner.name": "Alice",
+					"constant:orders":         "orders",
+				} {
+					p, err := ParsePartitionKey(s)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					key, err := p.key(cs, "00001")
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if key != want {
+						t.Fatalf("Testing Error, ErrorMessage: %s: expect %s, got %s", s, want, key)
+					}
+					if p.SingleShard() != (s == "constant:orders") {
+						t.Fatalf("Not behaving as intended. strategy: %s", s)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to key change streams without the value by the resume token.",
+			runner: func(t *testing.T) {
+				for _, s := range []string{"documentKey", "fullDocument.tenantId"} {
+					p, err := ParsePartitionKey(s)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if key, err := p.key(deleted, "00002"); err != nil || key != "00002" {
+						t.Fatalf("Testing Error, ErrorMessage: %s: got %s, %v", s, key, err)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to hash a key over 256 characters.",
+			runner: func(t *testing.T) {
+				p, err := ParsePartitionKey("fullDocument.tenantId")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				long := primitive.M{"fullDocument": primitive.M{"tenantId": strings.Repeat("a", 300)}}
+				key, err := p.key(long, "00001")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(key) != 32 {
+					t.Fatalf("Testing Error, ErrorMessage: got %s", key)
+				}
+				if again, _ := p.key(long, "00002"); again != key {
+					t.Fatalf("Testing Error, ErrorMessage: the same value is keyed differently.")
+				}
+			},
+		},
+		{
+			name: "Failed to parse a wrong strategy.",
+			runner: func(t *testing.T) {
+				for _, s := range []string{"documentKey._id", "fullDocument..name", "constant:", "constant:" + strings.Repeat("a", 257)} {
+					if _, err := ParsePartitionKey(s); err == nil {
+						t.Fatalf("Not behaving as intended. strategy: %s", s)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
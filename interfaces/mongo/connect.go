@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"fmt"
 	"github.com/cam-inc/mxtransporter/config/mongodb"
 	"github.com/cam-inc/mxtransporter/pkg/common"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
@@ -48,18 +49,41 @@ func fetchCollection(ctx context.Context, db *mongo.Database) (*mongo.Collection
 	return cl, nil
 }
 
+// Watch opens the change streams of the collection, the database or the whole cluster set in MONGODB_WATCH_SCOPE.
 func Watch(ctx context.Context, client *mongo.Client, ops *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
-	db, err := fetchDatabase(ctx, client)
+	pipeline, err := namespacePipeline(mongoCfg.NamespaceInclude, mongoCfg.NamespaceExclude)
 	if err != nil {
 		return nil, err
 	}
-
-	coll, err := fetchCollection(ctx, db)
+	custom, err := customPipeline(mongoCfg.Pipeline, mongoCfg.PipelineFile)
 	if err != nil {
 		return nil, err
 	}
+	pipeline = append(pipeline, custom...)
 
-	cs, err := coll.Watch(ctx, mongo.Pipeline{}, ops)
+	var cs *mongo.ChangeStream
+	switch mongoCfg.WatchScope {
+	case mongodb.WatchScopeCluster:
+		cs, err = client.Watch(ctx, pipeline, ops)
+	case mongodb.WatchScopeDatabase:
+		var db *mongo.Database
+		if db, err = fetchDatabase(ctx, client); err != nil {
+			return nil, err
+		}
+		cs, err = db.Watch(ctx, pipeline, ops)
+	case mongodb.WatchScopeCollection:
+		var db *mongo.Database
+		if db, err = fetchDatabase(ctx, client); err != nil {
+			return nil, err
+		}
+		var coll *mongo.Collection
+		if coll, err = fetchCollection(ctx, db); err != nil {
+			return nil, err
+		}
+		cs, err = coll.Watch(ctx, pipeline, ops)
+	default:
+		return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("MONGODB_WATCH_SCOPE must be collection, database or cluster. you set %s", mongoCfg.WatchScope))
+	}
 	if err != nil {
 		return nil, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to watch mongodb.", err)
 	}
@@ -0,0 +1,81 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	resumableErrorLabel = "ResumableChangeStreamError"
+	networkErrorLabel   = "NetworkError"
+
+	cursorNotFoundCode          = 43
+	changeStreamFatalErrorCode  = 280
+	changeStreamHistoryLostCode = 286
+)
+
+// Error codes that servers older than 4.4, which do not add the ResumableChangeStreamError label, return for resumable errors.
+var resumableErrorCodes = map[int32]struct{}{
+	6:     {}, // HostUnreachable
+	7:     {}, // HostNotFound
+	89:    {}, // NetworkTimeout
+	91:    {}, // ShutdownInProgress
+	189:   {}, // PrimarySteppedDown
+	262:   {}, // ExceededTimeLimit
+	9001:  {}, // SocketException
+	10107: {}, // NotMaster
+	11600: {}, // InterruptedAtShutdown
+	11602: {}, // InterruptedDueToReplStateChange
+	13435: {}, // NotMasterNoSlaveOk
+	13436: {}, // NotMasterOrSecondary
+	63:    {}, // StaleShardVersion
+	150:   {}, // StaleEpoch
+	13388: {}, // StaleConfig
+	234:   {}, // RetryChangeStream
+	133:   {}, // FailedToSatisfyReadPreference
+}
+
+// IsResumableError reports whether the change streams can be reopened from the last resume token after err,
+// e.g. a primary step-down or a network error.
+func IsResumableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || IsChangeStreamHistoryLost(err) {
+		return false
+	}
+
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		if cmdErr.HasErrorLabel(resumableErrorLabel) || cmdErr.HasErrorLabel(networkErrorLabel) || cmdErr.Code == cursorNotFoundCode {
+			return true
+		}
+		_, ok := resumableErrorCodes[cmdErr.Code]
+		return ok
+	}
+
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var selectErr topology.ServerSelectionError
+	if errors.As(err, &selectErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// IsChangeStreamHistoryLost reports whether err means the resume point is no longer in the oplog.
+func IsChangeStreamHistoryLost(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	if cmdErr.Code == changeStreamHistoryLostCode {
+		return true
+	}
+	// Servers older than 4.2 return ChangeStreamFatalError instead.
+	return cmdErr.Code == changeStreamFatalErrorCode && strings.Contains(cmdErr.Message, "no longer be in the oplog")
+}
@@ -0,0 +1,62 @@
+//go:build test
+// +build test
+
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_IsResumableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "resumable label", err: mongo.CommandError{Code: 189, Labels: []string{resumableErrorLabel}}, want: true},
+		{name: "network label", err: mongo.CommandError{Code: 1, Labels: []string{networkErrorLabel}}, want: true},
+		{name: "resumable code of old servers", err: mongo.CommandError{Code: 10107}, want: true},
+		{name: "cursor not found", err: mongo.CommandError{Code: cursorNotFoundCode}, want: true},
+		{name: "wrapped", err: errors.InternalServerError.Wrap("Could not get the next event for change stream.", mongo.CommandError{Code: 11602}), want: true},
+		{name: "timeout", err: context.DeadlineExceeded, want: true},
+		{name: "unauthorized", err: mongo.CommandError{Code: 13, Name: "Unauthorized"}, want: false},
+		{name: "history lost", err: mongo.CommandError{Code: changeStreamHistoryLostCode}, want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "not mongo error", err: fmt.Errorf("decode error"), want: false},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := IsResumableError(v.err); got != v.want {
+				t.Fatalf("IsResumableError(%v) = %v, want %v", v.err, got, v.want)
+			}
+		})
+	}
+}
+
+func Test_IsChangeStreamHistoryLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "history lost", err: errors.InternalServerError.Wrap("Could not get the next event for change stream.", mongo.CommandError{Code: changeStreamHistoryLostCode}), want: true},
+		{name: "fatal error of old servers", err: mongo.CommandError{Code: changeStreamFatalErrorCode, Message: "Resume of change stream was not possible, as the resume point may no longer be in the oplog."}, want: true},
+		{name: "other fatal error", err: mongo.CommandError{Code: changeStreamFatalErrorCode, Message: "cannot resume stream"}, want: false},
+		{name: "not mongo error", err: fmt.Errorf("decode error"), want: false},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := IsChangeStreamHistoryLost(v.err); got != v.want {
+				t.Fatalf("IsChangeStreamHistoryLost(%v) = %v, want %v", v.err, got, v.want)
+			}
+		})
+	}
+}
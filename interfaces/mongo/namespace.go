@@ -0,0 +1,75 @@
+package mongo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// namespacePipeline returns the $match stage which keeps the change streams of the included namespaces
+// and drops those of the excluded ones. Both lists are comma separated, see namespaceFilter.
+func namespacePipeline(include, exclude string) (mongo.Pipeline, error) {
+	var match bson.D
+
+	inc, err := namespaceFilters(include)
+	if err != nil {
+		return nil, err
+	}
+	if len(inc) > 0 {
+		match = append(match, bson.E{Key: "$or", Value: inc})
+	}
+
+	exc, err := namespaceFilters(exclude)
+	if err != nil {
+		return nil, err
+	}
+	if len(exc) > 0 {
+		match = append(match, bson.E{Key: "$nor", Value: exc})
+	}
+
+	if len(match) == 0 {
+		return mongo.Pipeline{}, nil
+	}
+	return mongo.Pipeline{{{Key: "$match", Value: match}}}, nil
+}
+
+func namespaceFilters(list string) (bson.A, error) {
+	var filters bson.A
+	for _, ns := range strings.Split(list, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" {
+			continue
+		}
+		f, err := namespaceFilter(ns)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// namespaceFilter matches "{db}" to every collection in the database and "{db}.{coll}" to the collection.
+// "/{regex}/" is matched against "{db}.{coll}", which needs MongoDB 4.2 or later.
+func namespaceFilter(ns string) (bson.D, error) {
+	if len(ns) > 2 && strings.HasPrefix(ns, "/") && strings.HasSuffix(ns, "/") {
+		re := ns[1 : len(ns)-1]
+		if _, err := regexp.Compile(re); err != nil {
+			return nil, errors.InternalServerErrorEnvGet.Wrap(fmt.Sprintf("The namespace regular expression %s is wrong.", ns), err)
+		}
+		return bson.D{{Key: "$expr", Value: bson.D{{Key: "$regexMatch", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$concat", Value: bson.A{"$ns.db", ".", bson.D{{Key: "$ifNull", Value: bson.A{"$ns.coll", ""}}}}}}},
+			{Key: "regex", Value: re},
+		}}}}}, nil
+	}
+
+	db, coll, ok := strings.Cut(ns, ".")
+	if !ok {
+		return bson.D{{Key: "ns.db", Value: db}}, nil
+	}
+	return bson.D{{Key: "ns.db", Value: db}, {Key: "ns.coll", Value: coll}}, nil
+}
@@ -0,0 +1,86 @@
+//go:build test
+// +build test
+
+package mongo
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_namespacePipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to watch every namespace without include and exclude.",
+			runner: func(t *testing.T) {
+				p, err := namespacePipeline("", " , ")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(p) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: expect an empty pipeline, got %v.", p)
+				}
+			},
+		},
+		{
+			name: "Pass to match included databases and collections, and not excluded ones.",
+			runner: func(t *testing.T) {
+				p, err := namespacePipeline("shop, audit.logs", "shop.sessions")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := mongo.Pipeline{{{Key: "$match", Value: bson.D{
+					{Key: "$or", Value: bson.A{
+						bson.D{{Key: "ns.db", Value: "shop"}},
+						bson.D{{Key: "ns.db", Value: "audit"}, {Key: "ns.coll", Value: "logs"}},
+					}},
+					{Key: "$nor", Value: bson.A{
+						bson.D{{Key: "ns.db", Value: "shop"}, {Key: "ns.coll", Value: "sessions"}},
+					}},
+				}}}}
+				if !reflect.DeepEqual(want, p) {
+					t.Fatalf("Testing Error, ErrorMessage: want %v, got %v.", want, p)
+				}
+			},
+		},
+		{
+			name: "Pass to match namespaces by a regular expression.",
+			runner: func(t *testing.T) {
+				p, err := namespacePipeline("", `/^shop\.tmp_/`)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				nor := p[0][0].Value.(bson.D)[0]
+				if nor.Key != "$nor" {
+					t.Fatalf("Testing Error, ErrorMessage: expect $nor, got %v.", nor.Key)
+				}
+				expr := nor.Value.(bson.A)[0].(bson.D)[0]
+				if expr.Key != "$expr" {
+					t.Fatalf("Testing Error, ErrorMessage: expect $expr, got %v.", expr.Key)
+				}
+				regex := expr.Value.(bson.D)[0].Value.(bson.D)[1]
+				if regex.Value != `^shop\.tmp_` {
+					t.Fatalf("Testing Error, ErrorMessage: regular expression is wrong, got %v.", regex.Value)
+				}
+			},
+		},
+		{
+			name: "Failed by a wrong regular expression.",
+			runner: func(t *testing.T) {
+				if _, err := namespacePipeline("/shop(/", ""); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,39 @@
+package mongo
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// keyStringTimestampType is the type byte of a timestamp in the KeyString format which the resume token is encoded in.
+const keyStringTimestampType = 0x82
+
+// OldestOplogTime returns the time of the oldest entry in the oplog, before which change streams cannot be opened.
+// The oplog cannot be read through mongos, or without the read privilege on the local database.
+func OldestOplogTime(ctx context.Context, client *mongo.Client) (primitive.Timestamp, error) {
+	var entry struct {
+		Ts primitive.Timestamp `bson:"ts"`
+	}
+	ops := options.FindOne().SetSort(bson.D{{Key: "$natural", Value: 1}}).SetProjection(bson.D{{Key: "ts", Value: 1}})
+	if err := client.Database("local").Collection("oplog.rs").FindOne(ctx, bson.D{}, ops).Decode(&entry); err != nil {
+		return primitive.Timestamp{}, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to find the oldest oplog entry.", err)
+	}
+	return entry.Ts, nil
+}
+
+// ResumeTokenTime returns the cluster time of the change stream which the resume token, the _data of _id, points to.
+func ResumeTokenTime(resumeToken string) (primitive.Timestamp, error) {
+	b, err := hex.DecodeString(resumeToken)
+	if err != nil || len(b) < 9 || b[0] != keyStringTimestampType {
+		return primitive.Timestamp{}, errors.InternalServerError.New(fmt.Sprintf("Failed to read the cluster time of the resume token %s.", resumeToken))
+	}
+	return primitive.Timestamp{T: binary.BigEndian.Uint32(b[1:5]), I: binary.BigEndian.Uint32(b[5:9])}, nil
+}
@@ -0,0 +1,30 @@
+//go:build test
+// +build test
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_ResumeTokenTime(t *testing.T) {
+	t.Run("Pass to read the cluster time of a resume token.", func(t *testing.T) {
+		ts, err := ResumeTokenTime("8262470A80000000032B022C0100296E5A1004")
+		if err != nil {
+			t.Fatalf("Testing Error, ErrorMessage: %v", err)
+		}
+		if want := (primitive.Timestamp{T: 1648822912, I: 3}); ts != want {
+			t.Fatalf("Not behaving as intended. want: %v, got: %v", want, ts)
+		}
+	})
+
+	t.Run("Failed to read a resume token which is not in the KeyString format.", func(t *testing.T) {
+		for _, rt := range []string{"", "xyz", "00000", "8262470A80"} {
+			if _, err := ResumeTokenTime(rt); err == nil {
+				t.Fatalf("Not behaving as intended. resume token: %s", rt)
+			}
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package mongo
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RequiredFields are the fields of change streams which MxTransporter and the exporters read, so a pipeline must not remove them.
+var RequiredFields = []string{"_id", "operationType", "clusterTime"}
+
+// customPipeline returns the aggregation pipeline given as a JSON array of stages in MONGODB_PIPELINE,
+// or in the file of MONGODB_PIPELINE_FILE. Extended JSON, e.g. {"$date": ...}, is accepted.
+func customPipeline(pipeline, pipelineFile string) (mongo.Pipeline, error) {
+	if pipeline != "" && pipelineFile != "" {
+		return nil, errors.InternalServerErrorEnvGet.New("Only one of MONGODB_PIPELINE and MONGODB_PIPELINE_FILE can be set.")
+	}
+	if pipelineFile != "" {
+		b, err := os.ReadFile(pipelineFile)
+		if err != nil {
+			return nil, errors.InternalServerError.Wrap("Failed to read the pipeline file.", err)
+		}
+		pipeline = string(b)
+	}
+	if pipeline == "" {
+		return mongo.Pipeline{}, nil
+	}
+
+	// Extended JSON can only be unmarshaled into a document, so the array is wrapped with one.
+	var doc struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(fmt.Sprintf(`{"pipeline": %s}`, pipeline)), false, &doc); err != nil {
+		return nil, errors.InternalServerErrorEnvGet.Wrap("The pipeline is not a JSON array of stages.", err)
+	}
+
+	for _, stage := range doc.Pipeline {
+		if err := validateStage(stage); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Pipeline, nil
+}
+
+// validateStage checks that the stage keeps the RequiredFields.
+func validateStage(stage bson.D) error {
+	if len(stage) != 1 {
+		return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("A pipeline stage must have exactly one field. got %v", stage))
+	}
+
+	switch stage[0].Key {
+	case "$project":
+		spec, ok := stage[0].Value.(bson.D)
+		if !ok {
+			return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("$project must be a document. got %v", stage[0].Value))
+		}
+		return validateProject(spec)
+	case "$unset":
+		var fields []interface{}
+		switch v := stage[0].Value.(type) {
+		case string:
+			fields = []interface{}{v}
+		case bson.A:
+			fields = v
+		}
+		for _, f := range fields {
+			if isRequiredField(fmt.Sprintf("%v", f)) {
+				return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The pipeline must not remove %s from change streams.", f))
+			}
+		}
+	}
+	return nil
+}
+
+func validateProject(spec bson.D) error {
+	inclusion := false
+	projected := map[string]bool{}
+	for _, e := range spec {
+		excluded := isExclusion(e.Value)
+		if e.Key != "_id" && !excluded {
+			inclusion = true
+		}
+		projected[e.Key] = !excluded
+	}
+
+	for _, f := range RequiredFields {
+		kept, ok := projected[f]
+		// _id is kept unless it is excluded explicitly, and the others are kept in the exclusion mode.
+		if ok && !kept || !ok && inclusion && f != "_id" {
+			return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The pipeline must not remove %s from change streams.", f))
+		}
+	}
+	return nil
+}
+
+func isExclusion(v interface{}) bool {
+	switch n := v.(type) {
+	case bool:
+		return !n
+	case int32:
+		return n == 0
+	case int64:
+		return n == 0
+	case float64:
+		return n == 0
+	}
+	return false
+}
+
+func isRequiredField(f string) bool {
+	for _, r := range RequiredFields {
+		if f == r {
+			return true
+		}
+	}
+	return false
+}
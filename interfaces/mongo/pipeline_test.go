@@ -0,0 +1,109 @@
+//go:build test
+// +build test
+
+package mongo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_customPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to use an empty pipeline without configuration.",
+			runner: func(t *testing.T) {
+				p, err := customPipeline("", "")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(p) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: expect an empty pipeline, got %v.", p)
+				}
+			},
+		},
+		{
+			name: "Pass to parse the pipeline keeping the order of fields.",
+			runner: func(t *testing.T) {
+				p, err := customPipeline(`[{"$match": {"operationType": {"$in": ["insert", "update"]}}}, {"$project": {"fullDocument.largeBlob": 0}}]`, "")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := mongo.Pipeline{
+					{{Key: "$match", Value: bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: bson.A{"insert", "update"}}}}}}},
+					{{Key: "$project", Value: bson.D{{Key: "fullDocument.largeBlob", Value: int32(0)}}}},
+				}
+				if !reflect.DeepEqual(want, p) {
+					t.Fatalf("Testing Error, ErrorMessage: want %v, got %v.", want, p)
+				}
+			},
+		},
+		{
+			name: "Pass to read the pipeline from the file.",
+			runner: func(t *testing.T) {
+				file := filepath.Join(t.TempDir(), "pipeline.json")
+				if err := os.WriteFile(file, []byte(`[{"$match": {"fullDocument.status": "paid"}}]`), 0644); err != nil {
+					t.Fatal(err)
+				}
+				p, err := customPipeline("", file)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(p) != 1 || p[0][0].Key != "$match" {
+					t.Fatalf("Testing Error, ErrorMessage: pipeline is not read from the file, got %v.", p)
+				}
+			},
+		},
+		{
+			name: "Failed by both the pipeline and the file.",
+			runner: func(t *testing.T) {
+				if _, err := customPipeline(`[]`, "pipeline.json"); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by a pipeline which is not an array.",
+			runner: func(t *testing.T) {
+				if _, err := customPipeline(`{"$match": {}}`, ""); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by a pipeline removing the fields MxTransporter reads.",
+			runner: func(t *testing.T) {
+				for _, p := range []string{
+					`[{"$project": {"_id": 0}}]`,
+					`[{"$project": {"fullDocument": 1, "operationType": 1}}]`,
+					`[{"$unset": ["fullDocument.blob", "clusterTime"]}]`,
+					`[{"$unset": "_id"}]`,
+				} {
+					if _, err := customPipeline(p, ""); err == nil {
+						t.Fatalf("Not behaving as intended. pipeline: %s", p)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to project the fields MxTransporter reads.",
+			runner: func(t *testing.T) {
+				if _, err := customPipeline(`[{"$project": {"fullDocument": 1, "operationType": 1, "clusterTime": true, "ns": 1}}]`, ""); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
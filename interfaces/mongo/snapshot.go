@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cam-inc/mxtransporter/config/mongodb"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ClusterTime returns the operation time of the cluster. Change streams opened at it get every write after it.
+func ClusterTime(ctx context.Context, client *mongo.Client) (primitive.Timestamp, error) {
+	var res struct {
+		OperationTime primitive.Timestamp `bson:"operationTime"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "ping", Value: 1}}).Decode(&res); err != nil {
+		return primitive.Timestamp{}, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to get the cluster time.", err)
+	}
+	if res.OperationTime.IsZero() {
+		return primitive.Timestamp{}, errors.InternalServerErrorMongoDbOperate.New("The cluster time is not available. The snapshot needs a replica set or a sharded cluster.")
+	}
+	return res.OperationTime, nil
+}
+
+// SnapshotSplitPoints returns the _id values which split the watched collection into up to n ranges of about the same number of documents.
+// Range operators only match _id of the same BSON type as the bound, so no split point is returned,
+// that is, the collection is scanned in a single range, when _id has more than one type.
+func SnapshotSplitPoints(ctx context.Context, client *mongo.Client, n int) ([]bson.RawValue, error) {
+	coll, err := snapshotCollection(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 1 {
+		return nil, nil
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$bucketAuto", Value: bson.D{{Key: "groupBy", Value: "$_id"}, {Key: "buckets", Value: n}}}}}
+	cur, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to split the collection for the snapshot.", err)
+	}
+	var buckets []struct {
+		ID struct {
+			Min bson.RawValue `bson:"min"`
+		} `bson:"_id"`
+	}
+	if err := cur.All(ctx, &buckets); err != nil {
+		return nil, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to split the collection for the snapshot.", err)
+	}
+
+	points := make([]bson.RawValue, 0, len(buckets))
+	for i := 1; i < len(buckets); i++ {
+		points = append(points, buckets[i].ID.Min)
+	}
+
+	// _id is sorted by the BSON type first, so the smallest and the largest _id have different types if any do.
+	first, err := snapshotEndID(ctx, coll, 1)
+	if err != nil {
+		return nil, err
+	}
+	last, err := snapshotEndID(ctx, coll, -1)
+	if err != nil {
+		return nil, err
+	}
+	if !sameIDType(append([]bson.RawValue{first, last}, points...)) {
+		return nil, nil
+	}
+	return points, nil
+}
+
+// snapshotEndID returns the smallest _id of the watched collection if order is 1, or the largest if -1.
+func snapshotEndID(ctx context.Context, coll *mongo.Collection, order int) (bson.RawValue, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: order}}).SetProjection(bson.D{{Key: "_id", Value: 1}})
+	raw, err := coll.FindOne(ctx, bson.D{}, opts).DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return bson.RawValue{}, nil
+	}
+	if err != nil {
+		return bson.RawValue{}, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to split the collection for the snapshot.", err)
+	}
+	return raw.Lookup("_id"), nil
+}
+
+// sameIDType reports whether the non-zero ids are all comparable by range operators.
+// Numbers of any type are compared by value, so they are of the same type here.
+func sameIDType(ids []bson.RawValue) bool {
+	var typ bsontype.Type
+	for _, id := range ids {
+		if id.IsZero() {
+			continue
+		}
+		t := id.Type
+		switch t {
+		case bsontype.Int32, bsontype.Int64, bsontype.Decimal128:
+			t = bsontype.Double
+		case bsontype.Symbol:
+			t = bsontype.String
+		}
+		if typ == 0 {
+			typ = t
+		} else if t != typ {
+			return false
+		}
+	}
+	return true
+}
+
+// ScanSnapshot returns the documents of the watched collection in the order of _id, where min <= _id < max, or after < _id if after is set.
+// A zero bound is not applied.
+func ScanSnapshot(ctx context.Context, client *mongo.Client, min, max, after bson.RawValue) (*mongo.Cursor, error) {
+	coll, err := snapshotCollection(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := bson.D{}
+	if !after.IsZero() {
+		cond = append(cond, bson.E{Key: "$gt", Value: after})
+	} else if !min.IsZero() {
+		cond = append(cond, bson.E{Key: "$gte", Value: min})
+	}
+	if !max.IsZero() {
+		cond = append(cond, bson.E{Key: "$lt", Value: max})
+	}
+	filter := bson.D{}
+	if len(cond) > 0 {
+		filter = bson.D{{Key: "_id", Value: cond}}
+	}
+
+	cur, err := coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, errors.InternalServerErrorMongoDbOperate.Wrap("Failed to scan the collection for the snapshot.", err)
+	}
+	return cur, nil
+}
+
+// snapshotCollection returns the watched collection. The snapshot is supported only in the collection scope.
+func snapshotCollection(ctx context.Context, client *mongo.Client) (*mongo.Collection, error) {
+	if mongoCfg.WatchScope != mongodb.WatchScopeCollection {
+		return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The snapshot is supported only when MONGODB_WATCH_SCOPE is collection. you set %s", mongoCfg.WatchScope))
+	}
+	db, err := fetchDatabase(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return fetchCollection(ctx, db)
+}
@@ -0,0 +1,58 @@
+//go:build test
+// +build test
+
+package mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_sameIDType(t *testing.T) {
+	raw := func(v interface{}) bson.RawValue {
+		typ, data, err := bson.MarshalValue(v)
+		if err != nil {
+			t.Fatalf("Testing Error, ErrorMessage: %v", err)
+		}
+		return bson.RawValue{Type: typ, Value: data}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to split by ids of one type.",
+			runner: func(t *testing.T) {
+				ids := []bson.RawValue{raw(primitive.NewObjectID()), raw(primitive.NewObjectID()), {}}
+				if !sameIDType(ids) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to split by numbers of different types, which are compared by value.",
+			runner: func(t *testing.T) {
+				ids := []bson.RawValue{raw(int32(1)), raw(int64(100)), raw(2.5)}
+				if !sameIDType(ids) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed to split by ids of mixed types, which range operators do not match.",
+			runner: func(t *testing.T) {
+				ids := []bson.RawValue{raw(int32(1)), raw("abc"), raw(primitive.NewObjectID())}
+				if sameIDType(ids) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/fieldpath"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxAttributeValueBytes is the maximum size of a value of attributes Pub/Sub accepts.
+const maxAttributeValueBytes = 1024
+
+// Attribute is an attribute of messages whose value is taken from the field of change streams at Path.
+type Attribute struct {
+	Name string
+	Path []string
+}
+
+// ParseAttributes parses the comma separated attributes by name=path, e.g. collection=ns.coll.
+// An attribute without name, e.g. operationType, is named by its path.
+func ParseAttributes(s string) ([]Attribute, error) {
+	var attrs []Attribute
+	for _, a := range strings.Split(s, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		name, path := a, a
+		if i := strings.Index(a, "="); i >= 0 {
+			name, path = strings.TrimSpace(a[:i]), strings.TrimSpace(a[i+1:])
+		}
+		segments, err := fieldpath.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" || strings.HasPrefix(name, "goog") {
+			return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("PUBSUB_ATTRIBUTES must be named without the prefix goog. you set %s", a))
+		}
+		attrs = append(attrs, Attribute{Name: name, Path: segments})
+	}
+	return attrs, nil
+}
+
+// attributes returns the attributes of the message of cs. Missing fields and null are not set,
+// and values over the limit of Pub/Sub are not set either, since a truncated value would be matched wrongly by filters.
+func (p *PubsubImpl) attributes(cs primitive.M) (map[string]string, error) {
+	if len(p.Attributes) == 0 {
+		return nil, nil
+	}
+	attrs := make(map[string]string, len(p.Attributes))
+	for _, a := range p.Attributes {
+		v, ok := fieldpath.Lookup(cs, a.Path)
+		if !ok || v == nil {
+			continue
+		}
+		s, err := encoder.StringValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(s) > maxAttributeValueBytes {
+			p.Log.Warnf("The attribute %s is not set, since its value is over %d bytes.", a.Name, maxAttributeValueBytes)
+			continue
+		}
+		attrs[a.Name] = s
+	}
+	return attrs, nil
+}
@@ -0,0 +1,117 @@
+//go:build test
+// +build test
+
+package pubsub
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_Attributes(t *testing.T) {
+	l := logger.New(config.LogConfig())
+
+	oid, _ := primitive.ObjectIDFromHex("62a3f1a5e2a8b1c2d3e4f5a6")
+	cs := primitive.M{
+		"_id":           primitive.M{"_data": "00001"},
+		"operationType": "update",
+		"ns":            primitive.M{"db": "shop", "coll": "orders"},
+		"documentKey":   primitive.M{"_id": oid},
+		"fullDocument": primitive.M{
+			"status": "paid",
+			"total":  int64(1200),
+			"items":  primitive.A{primitive.D{{Key: "sku", Value: "A-1"}}},
+			"note":   strings.Repeat("x", maxAttributeValueBytes+1),
+			"coupon": nil,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to set the attributes from the fields of change streams.",
+			runner: func(t *testing.T) {
+				attrs, err := ParseAttributes("database=ns.db, collection=ns.coll,operationType,documentKey=documentKey._id,status=fullDocument.status,total=fullDocument.total,sku=fullDocument.items.0.sku,note=fullDocument.note,coupon=fullDocument.coupon,missing=fullDocument.missing")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{}, Log: l, Attributes: attrs}
+				m, err := mockPsImpl.message(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := map[string]string{
+					"database":      "shop",
+					"collection":    "orders",
+					"operationType": "update",
+					"documentKey":   "62a3f1a5e2a8b1c2d3e4f5a6",
+					"status":        "paid",
+					"total":         "1200",
+					"sku":           "A-1",
+				}
+				if !reflect.DeepEqual(want, m.Attributes) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, m.Attributes)
+				}
+			},
+		},
+		{
+			name: "Pass to give precedence to the attributes of the encoder.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "cloudevents")
+				t.Setenv(constant.EXPORT_CLOUDEVENTS_MODE, "binary")
+				enc, err := encoder.New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				attrs, err := ParseAttributes("ce-id=ns.db,collection=ns.coll")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{}, Log: l, Encoder: enc, Attributes: attrs}
+				m, err := mockPsImpl.message(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if m.Attributes["ce-id"] != "00001" || m.Attributes["collection"] != "orders" {
+					t.Fatalf("Testing Error, ErrorMessage: got: %v", m.Attributes)
+				}
+			},
+		},
+		{
+			name: "Pass to set no attributes without the configuration.",
+			runner: func(t *testing.T) {
+				mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{}, Log: l}
+				m, err := mockPsImpl.message(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if m.Attributes != nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by the attributes which are wrong.",
+			runner: func(t *testing.T) {
+				for _, s := range []string{"=ns.db", "db=ns..db", "db=", "googDb=ns.db"} {
+					if _, err := ParseAttributes(s); err == nil {
+						t.Fatalf("Not behaving as intended. attributes: %s", s)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
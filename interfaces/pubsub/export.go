@@ -2,47 +2,127 @@ package pubsub
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/pubsub"
 	pubsubConfig "github.com/cam-inc/mxtransporter/config/pubsub"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
 	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/cam-inc/mxtransporter/pkg/fieldpath"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
-var results []*pubsub.PublishResult
-
 type (
 	IPubsub interface {
 		topicExists(ctx context.Context, topicID string) (bool, error)
 		createTopic(ctx context.Context, topicID string) (*pubsub.Topic, error)
-		publishMessage(ctx context.Context, topicID string, csArray []string, pmo ...publishMessageOption) error
+		// publish publishes the message without waiting, and the result tells whether it is accepted.
+		publish(ctx context.Context, topicID string, message *pubsub.Message) publishResult
+		// resumePublish resumes publishing with the ordering key, which the topic pauses after a failure of it.
+		resumePublish(topicID, orderingKey string)
+		// stop publishes the remaining messages and releases the topics.
+		stop()
+	}
+
+	publishResult interface {
+		Get(ctx context.Context) (serverID string, err error)
 	}
 
 	PubsubImpl struct {
-		Pubsub     IPubsub
-		Log        *zap.SugaredLogger
-		OrderingBy string
+		Pubsub IPubsub
+		Log    *zap.SugaredLogger
+		// Topic is the name of the topic per change stream, which is PUBSUB_TOPIC_NAME if nil.
+		Topic *naming.Template
+		// OrderingBy is the path of change streams whose value is the ordering key, e.g. documentKey._id, see ParseOrderingBy.
+		OrderingBy []string
+		// Encoder encodes the data of messages, which is the legacy format if nil.
+		Encoder encoder.Encoder
+		// Attributes are set on messages from the fields of change streams.
+		Attributes []Attribute
+
+		// topics are the topics known to exist, which are checked and created once per topic.
+		topics      map[string]struct{}
+		outstanding []outstandingMessage
+	}
+
+	outstandingMessage struct {
+		result      publishResult
+		topicID     string
+		orderingKey string
 	}
 
 	PubsubClientImpl struct {
 		PubsubClient *pubsub.Client
 		Log          *zap.SugaredLogger
+		// Settings is the PublishSettings of the topics, which batch the messages to publish.
+		Settings pubsub.PublishSettings
+		// Ordering enables the message ordering of the topics, which is required to publish with ordering keys.
+		Ordering bool
+
+		mu     sync.Mutex
+		topics map[string]*pubsub.Topic
 	}
 )
 
+// NewPublishSettings returns the PublishSettings of the configuration, which is pubsub.DefaultPublishSettings for the ones not set.
+func NewPublishSettings(cfg pubsubConfig.PubSub) pubsub.PublishSettings {
+	settings := pubsub.DefaultPublishSettings
+	if cfg.PublishDelayThresholdMSec > 0 {
+		settings.DelayThreshold = time.Duration(cfg.PublishDelayThresholdMSec) * time.Millisecond
+	}
+	if cfg.PublishCountThreshold > 0 {
+		settings.CountThreshold = cfg.PublishCountThreshold
+	}
+	if cfg.PublishByteThreshold > 0 {
+		settings.ByteThreshold = cfg.PublishByteThreshold
+	}
+	if cfg.PublishNumGoroutines > 0 {
+		settings.NumGoroutines = cfg.PublishNumGoroutines
+	}
+	if cfg.PublishTimeoutMSec > 0 {
+		settings.Timeout = time.Duration(cfg.PublishTimeoutMSec) * time.Millisecond
+	}
+	if cfg.PublishBufferedByteLimit > 0 {
+		settings.BufferedByteLimit = cfg.PublishBufferedByteLimit
+	}
+	// Publish blocks while the messages published but not yet confirmed are over the limits.
+	if cfg.MaxOutstandingMessages > 0 || cfg.MaxOutstandingBytes > 0 {
+		settings.FlowControlSettings = pubsub.FlowControlSettings{
+			MaxOutstandingMessages: cfg.MaxOutstandingMessages,
+			MaxOutstandingBytes:    cfg.MaxOutstandingBytes,
+			LimitExceededBehavior:  pubsub.FlowControlBlock,
+		}
+	}
+	return settings
+}
+
 func withOrderingKey(orderingKey string) publishMessageOption {
 	return func(o *pubsub.Message) {
 		o.OrderingKey = orderingKey
 	}
 }
 
+func withAttributes(attrs map[string]string) publishMessageOption {
+	return func(o *pubsub.Message) {
+		o.Attributes = attrs
+	}
+}
+
 type publishMessageOption func(opts *pubsub.Message)
 
+func newMessage(data []byte, pmo ...publishMessageOption) *pubsub.Message {
+	message := &pubsub.Message{
+		Data: data,
+	}
+	for _, pmo := range pmo {
+		pmo(message)
+	}
+	return message
+}
+
 func (p *PubsubClientImpl) topicExists(ctx context.Context, topicID string) (bool, error) {
 	return p.PubsubClient.Topic(topicID).Exists(ctx)
 }
@@ -51,38 +131,91 @@ func (p *PubsubClientImpl) createTopic(ctx context.Context, topicID string) (*pu
 	return p.PubsubClient.CreateTopic(ctx, topicID)
 }
 
-func (p *PubsubClientImpl) publishMessage(ctx context.Context, topicID string, csArray []string, pmo ...publishMessageOption) error {
-	topic := p.PubsubClient.Topic(topicID)
-	defer topic.Stop()
-	message := &pubsub.Message{
-		Data: []byte(strings.Join(csArray, "|")),
+// publish publishes the message by the topic handle, which is created once per topic and kept, so that messages are batched across exports.
+func (p *PubsubClientImpl) publish(ctx context.Context, topicID string, message *pubsub.Message) publishResult {
+	p.mu.Lock()
+	topic, ok := p.topics[topicID]
+	if !ok {
+		topic = p.PubsubClient.Topic(topicID)
+		topic.PublishSettings = p.Settings
+		topic.EnableMessageOrdering = p.Ordering
+		if p.topics == nil {
+			p.topics = make(map[string]*pubsub.Topic)
+		}
+		p.topics[topicID] = topic
 	}
-	for _, pmo := range pmo {
-		pmo(message)
+	p.mu.Unlock()
+	return topic.Publish(ctx, message)
+}
+
+func (p *PubsubClientImpl) resumePublish(topicID, orderingKey string) {
+	p.mu.Lock()
+	topic, ok := p.topics[topicID]
+	p.mu.Unlock()
+	if ok {
+		topic.ResumePublish(orderingKey)
+	}
+}
+
+func (p *PubsubClientImpl) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, topic := range p.topics {
+		topic.Stop()
 	}
-	r := topic.Publish(ctx, message)
+	p.topics = nil
+}
 
-	for _, r := range append(results, r) {
-		id, err := r.Get(ctx)
+// Init creates the topic if it does not exist. It is done once, not per export.
+// The topics of a template are created when the first message is published to each of them.
+func (p *PubsubImpl) Init(ctx context.Context) error {
+	topic, err := p.topic()
+	if err != nil {
+		return err
+	}
+	if !topic.Static() {
+		return nil
+	}
+	return p.ensureTopic(ctx, topic.String())
+}
+
+func (p *PubsubImpl) topic() (*naming.Template, error) {
+	if p.Topic == nil {
+		topic, err := naming.Parse(pubsubConfig.PubSubConfig().TopicName)
 		if err != nil {
-			return errors.InternalServerErrorPubSubPublish.Wrap("Failed to publish message.", err)
+			return nil, err
 		}
-		p.Log.Info("Published a message with a message ID: ", id)
+		p.Topic = topic
 	}
-
-	return nil
+	return p.Topic, nil
 }
 
-func (p *PubsubImpl) ExportToPubsub(ctx context.Context, cs primitive.M) error {
-	psCfg := pubsubConfig.PubSubConfig()
+// topicID returns the topic of the change stream, which is created if it does not exist.
+func (p *PubsubImpl) topicID(ctx context.Context, cs primitive.M) (string, error) {
+	topic, err := p.topic()
+	if err != nil {
+		return "", err
+	}
+	topicID, err := topic.Execute(cs)
+	if err != nil {
+		return "", err
+	}
+	if err := p.ensureTopic(ctx, topicID); err != nil {
+		return "", err
+	}
+	return topicID, nil
+}
 
-	topicID := psCfg.TopicName
+func (p *PubsubImpl) ensureTopic(ctx context.Context, topicID string) error {
+	if _, ok := p.topics[topicID]; ok {
+		return nil
+	}
 	topicExistence, err := p.Pubsub.topicExists(ctx, topicID)
 	if err != nil {
 		return errors.InternalServerErrorPubSubFind.Wrap("Failed to check topic existence.", err)
 	}
 	if !topicExistence {
-		p.Log.Info("Topic is not exists. Creating a topic.")
+		p.Log.Infof("Topic %s is not exists. Creating a topic.", topicID)
 
 		var err error
 		_, err = p.Pubsub.createTopic(ctx, topicID)
@@ -91,55 +224,153 @@ func (p *PubsubImpl) ExportToPubsub(ctx context.Context, cs primitive.M) error {
 		}
 		p.Log.Info("Successed to create topic. ")
 	}
+	if p.topics == nil {
+		p.topics = make(map[string]struct{})
+	}
+	p.topics[topicID] = struct{}{}
+	return nil
+}
 
-	id, err := json.Marshal(cs["_id"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json _id parameter.", err)
+// ExportToPubsub publishes the change streams without waiting for the results, which are confirmed by Flush.
+// The messages not yet confirmed are limited by the flow control of the topics, see NewPublishSettings.
+func (p *PubsubImpl) ExportToPubsub(ctx context.Context, css []primitive.M) error {
+	for _, cs := range css {
+		message, err := p.message(cs)
+		if err != nil {
+			return err
+		}
+		if message == nil {
+			continue
+		}
+		topicID, err := p.topicID(ctx, cs)
+		if err != nil {
+			return err
+		}
+		p.outstanding = append(p.outstanding, outstandingMessage{
+			result:      p.Pubsub.publish(ctx, topicID, message),
+			topicID:     topicID,
+			orderingKey: message.OrderingKey,
+		})
 	}
-	opType := cs["operationType"].(string)
-	clusterTime := cs["clusterTime"].(primitive.Timestamp).T
-	fullDoc, err := json.Marshal(cs["fullDocument"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocument parameter.", err)
+
+	return nil
+}
+
+// Flush blocks until every message published by ExportToPubsub is accepted by Pub/Sub, so that the resume token is saved after them.
+func (p *PubsubImpl) Flush(ctx context.Context) error {
+	for len(p.outstanding) > 0 {
+		if err := p.confirmOldest(ctx); err != nil {
+			return err
+		}
 	}
-	ns, err := json.Marshal(cs["ns"])
+	return nil
+}
+
+// Close publishes the remaining messages and releases the topics.
+func (p *PubsubImpl) Close() {
+	p.Pubsub.stop()
+}
+
+// confirmOldest waits for the result of the oldest outstanding message.
+// On a failure, the other outstanding messages are dropped as well, since the batch is exported again from the resume token.
+func (p *PubsubImpl) confirmOldest(ctx context.Context) error {
+	m := p.outstanding[0]
+	p.outstanding = p.outstanding[1:]
+
+	id, err := m.result.Get(ctx)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json ns parameter.", err)
+		p.resumeOrderingKeys(append([]outstandingMessage{m}, p.outstanding...))
+		p.outstanding = nil
+		return errors.InternalServerErrorPubSubPublish.Wrap("Failed to publish message.", err)
 	}
-	docKey, err := json.Marshal(cs["documentKey"])
-	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json documentKey parameter.", err)
+	p.Log.Info("Published a message with a message ID: ", id)
+	return nil
+}
+
+// resumeOrderingKeys resumes publishing with the ordering keys of the dropped messages. The topic pauses a key after
+// a failure of it and fails every later message with that key, so without this the retry of the batch could never succeed.
+// The messages in flight with a failed key have failed with it, so resuming here does not reorder them.
+func (p *PubsubImpl) resumeOrderingKeys(dropped []outstandingMessage) {
+	type topicKey struct{ topicID, orderingKey string }
+	resumed := make(map[topicKey]struct{})
+	for _, m := range dropped {
+		if m.orderingKey == "" {
+			continue
+		}
+		k := topicKey{m.topicID, m.orderingKey}
+		if _, ok := resumed[k]; ok {
+			continue
+		}
+		resumed[k] = struct{}{}
+		p.Pubsub.resumePublish(m.topicID, m.orderingKey)
 	}
-	updDesc, err := json.Marshal(cs["updateDescription"])
+}
+
+func (p *PubsubImpl) message(cs primitive.M) (*pubsub.Message, error) {
+	enc := p.Encoder
+	if enc == nil {
+		enc = encoder.Legacy()
+	}
+	data, err := enc.Encode(cs)
 	if err != nil {
-		return errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json updateDescription parameter.", err)
+		return nil, err
 	}
-
-	r := []string{
-		string(id),
-		opType,
-		time.Unix(int64(clusterTime), 0).Format("2006-01-02 15:04:05"),
-		string(fullDoc),
-		string(ns),
-		string(docKey),
-		string(updDesc),
+	// The change stream is not represented in the format.
+	if data == nil {
+		return nil, nil
 	}
 
-	if p.OrderingBy != "" {
+	var pmo []publishMessageOption
+	attrs, err := p.attributes(cs)
+	if err != nil {
+		return nil, err
+	}
+	// The attributes of the encoder, e.g. ce-* of CloudEvents, take precedence over the configured ones.
+	if ae, ok := enc.(encoder.AttributesEncoder); ok {
+		encAttrs, err := ae.Attributes(cs)
+		if err != nil {
+			return nil, err
+		}
+		if len(encAttrs) > 0 && attrs == nil {
+			attrs = make(map[string]string, len(encAttrs))
+		}
+		for k, v := range encAttrs {
+			attrs[k] = v
+		}
+	}
+	if len(attrs) > 0 {
+		pmo = append(pmo, withAttributes(attrs))
+	}
+	if p.OrderingBy != nil {
 		key, err := p.orderingKey(cs)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		return p.Pubsub.publishMessage(ctx, topicID, r, withOrderingKey(key))
+		pmo = append(pmo, withOrderingKey(key))
 	}
 
-	return p.Pubsub.publishMessage(ctx, topicID, r)
+	return newMessage(data, pmo...), nil
 }
 
+// ParseOrderingBy parses PUBSUB_ORDERING_BY, a dotted path of change streams, or returns nil if it is empty.
+func ParseOrderingBy(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	path, err := fieldpath.Parse(s)
+	if err != nil {
+		return nil, errors.InternalServerErrorEnvGet.Wrap("PUBSUB_ORDERING_BY is not a dotted path of change streams.", err)
+	}
+	return path, nil
+}
+
+// orderingKey returns the value of change streams at OrderingBy, rendered as the attributes are,
+// so that the same document always has the same key, e.g. the hex of documentKey._id.
+// Change streams without the value, e.g. deletes for a path of fullDocument, are published without ordering key.
 func (p *PubsubImpl) orderingKey(cs primitive.M) (string, error) {
-	key, ok := cs[p.OrderingBy]
-	if !ok {
-		return "", errors.InvalidErrorPubSubOrderingKey.New(fmt.Sprintf("Failed to get orderingKey cs: %v, orderingBy: %s", cs, p.OrderingBy))
+	key, ok := fieldpath.Lookup(cs, p.OrderingBy)
+	if !ok || key == nil {
+		return "", nil
 	}
-	return fmt.Sprintf("%v", key), nil
+	return encoder.StringValue(key)
 }
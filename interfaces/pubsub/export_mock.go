@@ -7,29 +7,69 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"cloud.google.com/go/pubsub"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type mockPubsubClientImpl struct {
 	pubsubClient *pubsub.Client
 	cs           []string
+	exists       bool
+	created      []string
+	results      []*mockPublishResult
+	resumed      []string
+	stopped      bool
 }
 
-func (*mockPubsubClientImpl) topicExists(ctx context.Context, topicID string) (bool, error) {
-	return false, nil
+type mockPublishResult struct {
+	topicID   string
+	message   *pubsub.Message
+	err       error
+	confirmed bool
 }
 
-func (*mockPubsubClientImpl) createTopic(ctx context.Context, topicID string) (*pubsub.Topic, error) {
+func (m *mockPubsubClientImpl) topicExists(ctx context.Context, topicID string) (bool, error) {
+	return m.exists, nil
+}
+
+func (m *mockPubsubClientImpl) createTopic(ctx context.Context, topicID string) (*pubsub.Topic, error) {
+	m.created = append(m.created, topicID)
 	return nil, nil
 }
 
-func (m *mockPubsubClientImpl) publishMessage(_ context.Context, _ string, csArray []string, pmo ...publishMessageOption) error {
-	if csArray == nil {
-		return fmt.Errorf("Expect csItems to not be nil.")
+func (m *mockPubsubClientImpl) publish(_ context.Context, topicID string, message *pubsub.Message) publishResult {
+	r := &mockPublishResult{topicID: topicID, message: message}
+	if message == nil {
+		r.err = fmt.Errorf("Expect message to not be nil.")
+	} else if e, a := strings.Join(m.cs, "|"), string(message.Data); !reflect.DeepEqual(e, a) {
+		r.err = fmt.Errorf("expect %v, got %v", e, a)
 	}
-	if e, a := m.cs, csArray; !reflect.DeepEqual(e, a) {
-		return fmt.Errorf("expect %v, got %v", e, a)
+	m.results = append(m.results, r)
+	return r
+}
+
+func (m *mockPubsubClientImpl) resumePublish(_, orderingKey string) {
+	m.resumed = append(m.resumed, orderingKey)
+}
+
+func (m *mockPubsubClientImpl) stop() {
+	m.stopped = true
+}
+
+func (r *mockPublishResult) Get(_ context.Context) (string, error) {
+	r.confirmed = true
+	if r.err != nil {
+		return "", r.err
+	}
+	return "message-id", nil
+}
+
+// exportAndFlush exports css and waits for the results, as the exporter does for a batch.
+func exportAndFlush(ctx context.Context, p *PubsubImpl, css []primitive.M) error {
+	if err := p.ExportToPubsub(ctx, css); err != nil {
+		return err
 	}
-	return nil
+	return p.Flush(ctx)
 }
@@ -6,11 +6,17 @@ package pubsub
 import (
 	"context"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 
+	"cloud.google.com/go/pubsub"
 	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	pubsubConfig "github.com/cam-inc/mxtransporter/config/pubsub"
+	"github.com/cam-inc/mxtransporter/pkg/encoder"
 	"github.com/cam-inc/mxtransporter/pkg/logger"
+	"github.com/cam-inc/mxtransporter/pkg/naming"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
@@ -50,8 +56,8 @@ func Test_ExportToPubSub(t *testing.T) {
 			name: "Pass to publish a message to pubsub.",
 			runner: func(t *testing.T) {
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: testCsArray}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err != nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err != nil {
 					t.Fatalf("Testing Error, ErrorMessage: %v", err)
 				}
 			},
@@ -60,8 +66,240 @@ func Test_ExportToPubSub(t *testing.T) {
 			name: "Pass to publish a message to pubsub with ordering key.",
 			runner: func(t *testing.T) {
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: testCsArray}
-				mockPsImpl := PubsubImpl{psClientImpl, l, "documentKey"}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err != nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l, OrderingBy: []string{"documentKey"}}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to get the ordering key from a dotted path.",
+			runner: func(t *testing.T) {
+				oid, _ := primitive.ObjectIDFromHex("62b2f4e1a3bbc7b5f4a1d2c3")
+				cs := primitive.M{
+					"documentKey":  primitive.M{"_id": oid},
+					"fullDocument": primitive.M{"tenantId": int32(7), "tags": primitive.A{"a", "b"}},
+				}
+				for orderingBy, want := range map[string]string{
+					"documentKey._id":       "62b2f4e1a3bbc7b5f4a1d2c3",
+					"documentKey":           `{"_id":{"$oid":"62b2f4e1a3bbc7b5f4a1d2c3"}}`,
+					"fullDocument.tenantId": "7",
+					"fullDocument.tags.1":   "b",
+				} {
+					path, err := ParseOrderingBy(orderingBy)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{}, Log: l, OrderingBy: path}
+					key, err := mockPsImpl.orderingKey(cs)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if key != want {
+						t.Fatalf("Testing Error, ErrorMessage: orderingBy: %s, expect %s, got %s", orderingBy, want, key)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to resume publishing with the ordering keys of the failed messages.",
+			runner: func(t *testing.T) {
+				other := primitive.M{}
+				for k, v := range csMap {
+					other[k] = v
+				}
+				other["documentKey"] = primitive.M{"yyyyy": "other document key"}
+				psClientImpl := &mockPubsubClientImpl{cs: nil}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l, OrderingBy: []string{"documentKey", "yyyyy"}}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap, csMap, other}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if !reflect.DeepEqual(psClientImpl.resumed, []string{"test document key", "other document key"}) {
+					t.Fatalf("Testing Error, ErrorMessage: resumed: %v", psClientImpl.resumed)
+				}
+
+				psClientImpl = &mockPubsubClientImpl{cs: nil}
+				mockPsImpl = PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil || len(psClientImpl.resumed) != 0 {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to publish multiple messages to pubsub at once.",
+			runner: func(t *testing.T) {
+				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: testCsArray}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap, csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to append the pre-image to the message.",
+			runner: func(t *testing.T) {
+				csWithPreImage := primitive.M{"fullDocumentBeforeChange": primitive.M{"wwwww": "test before change"}}
+				for k, v := range csMap {
+					csWithPreImage[k] = v
+				}
+				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: append(testCsArray, `{"wwwww":"test before change"}`)}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csWithPreImage}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to skip change streams which the format does not represent.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "debezium")
+				enc, err := encoder.New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l, Encoder: enc}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{{"_id": primitive.M{"_data": "00001"}, "operationType": "drop"}}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to put the attributes of the encoder on the message.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "cloudevents")
+				t.Setenv(constant.EXPORT_CLOUDEVENTS_MODE, "binary")
+				enc, err := encoder.New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{}, Log: l, OrderingBy: []string{"documentKey"}, Encoder: enc}
+				cs := primitive.M{"_id": primitive.M{"_data": "00001"}, "operationType": "insert", "documentKey": primitive.M{"_id": "a"}}
+				m, err := mockPsImpl.message(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if m.Attributes["ce-id"] != "00001" || m.Attributes["ce-type"] != "com.mongodb.changestream.insert" || m.OrderingKey == "" {
+					t.Fatalf("Testing Error, ErrorMessage: got: %v", m)
+				}
+			},
+		},
+		{
+			name: "Pass to create the topic once at Init.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.PUBSUB_TOPIC_NAME, "topic")
+				psClientImpl := &mockPubsubClientImpl{cs: testCsArray}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := mockPsImpl.Init(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if !reflect.DeepEqual(psClientImpl.created, []string{"topic"}) || psClientImpl.results[1].topicID != "topic" {
+					t.Fatalf("Testing Error, ErrorMessage: created: %v", psClientImpl.created)
+				}
+
+				existing := &mockPubsubClientImpl{exists: true}
+				mockPsImpl = PubsubImpl{Pubsub: existing, Log: l}
+				if err := mockPsImpl.Init(ctx); err != nil || len(existing.created) != 0 {
+					t.Fatalf("Not behaving as intended.")
+				}
+				mockPsImpl.Close()
+				if !existing.stopped {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to publish to the topic of the template per change stream.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.PUBSUB_TOPIC_NAME, "cdc.{{.Database}}.{{.Collection}}")
+				orders := primitive.M{"ns": primitive.M{"db": "shop", "coll": "orders"}}
+				users := primitive.M{"ns": primitive.M{"db": "shop", "coll": "users"}}
+				for k, v := range csMap {
+					if k != "ns" {
+						orders[k], users[k] = v, v
+					}
+				}
+				psClientImpl := &mockPubsubClientImpl{}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := mockPsImpl.Init(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if len(psClientImpl.created) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: created: %v", psClientImpl.created)
+				}
+				if err := mockPsImpl.ExportToPubsub(ctx, []primitive.M{orders, users, orders}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if !reflect.DeepEqual(psClientImpl.created, []string{"cdc.shop.orders", "cdc.shop.users"}) {
+					t.Fatalf("Testing Error, ErrorMessage: created: %v", psClientImpl.created)
+				}
+				var topicIDs []string
+				for _, r := range psClientImpl.results {
+					topicIDs = append(topicIDs, r.topicID)
+				}
+				if !reflect.DeepEqual(topicIDs, []string{"cdc.shop.orders", "cdc.shop.users", "cdc.shop.orders"}) {
+					t.Fatalf("Testing Error, ErrorMessage: published to: %v", topicIDs)
+				}
+			},
+		},
+		{
+			name: "Failed to evaluate the topic of the template.",
+			runner: func(t *testing.T) {
+				topic, err := naming.Parse("{{.Event.fullDocument.missing}}")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				mockPsImpl := PubsubImpl{Pubsub: &mockPubsubClientImpl{cs: testCsArray}, Log: l, Topic: topic}
+				if err := mockPsImpl.ExportToPubsub(ctx, []primitive.M{csMap}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to confirm the results at Flush.",
+			runner: func(t *testing.T) {
+				psClientImpl := &mockPubsubClientImpl{cs: testCsArray}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := mockPsImpl.ExportToPubsub(ctx, []primitive.M{csMap, csMap, csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				for _, r := range psClientImpl.results {
+					if r.confirmed {
+						t.Fatalf("Testing Error, ErrorMessage: the result is waited for before Flush.")
+					}
+				}
+				if err := mockPsImpl.Flush(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				for _, r := range psClientImpl.results {
+					if !r.confirmed {
+						t.Fatalf("Testing Error, ErrorMessage: the result is not waited for at Flush.")
+					}
+				}
+
+			},
+		},
+		{
+			name: "Failed to publish a message, which is returned at Flush.",
+			runner: func(t *testing.T) {
+				psClientImpl := &mockPubsubClientImpl{cs: nil}
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := mockPsImpl.ExportToPubsub(ctx, []primitive.M{csMap, csMap}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := mockPsImpl.Flush(ctx); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if len(mockPsImpl.outstanding) != 0 {
+					t.Fatalf("Testing Error, ErrorMessage: the outstanding messages are left after the failure.")
+				}
+				if err := mockPsImpl.Flush(ctx); err != nil {
 					t.Fatalf("Testing Error, ErrorMessage: %v", err)
 				}
 			},
@@ -81,8 +319,8 @@ func Test_ExportToPubSub(t *testing.T) {
 				}
 
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -102,8 +340,8 @@ func Test_ExportToPubSub(t *testing.T) {
 				}
 
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -123,8 +361,8 @@ func Test_ExportToPubSub(t *testing.T) {
 				}
 
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -144,8 +382,8 @@ func Test_ExportToPubSub(t *testing.T) {
 				}
 
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -165,18 +403,37 @@ func Test_ExportToPubSub(t *testing.T) {
 				}
 
 				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: nil}
-				mockPsImpl := PubsubImpl{psClientImpl, l, ""}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l}
+				if err := exportAndFlush(ctx, &mockPsImpl, []primitive.M{csMap}); err == nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
 		},
 		{
-			name: "Failed to get ordering key.",
+			name: "Pass to publish a message without ordering key when the change stream has no value at the path.",
 			runner: func(t *testing.T) {
-				psClientImpl := &mockPubsubClientImpl{pubsubClient: nil, cs: testCsArray}
-				mockPsImpl := PubsubImpl{psClientImpl, l, "invalid-key"}
-				if err := mockPsImpl.ExportToPubsub(ctx, csMap); err == nil {
+				psClientImpl := &mockPubsubClientImpl{}
+				for _, orderingBy := range [][]string{{"invalid-key"}, {"documentKey", "invalid-key"}, {"fullDocument", "tenantId"}} {
+					mockPsImpl := PubsubImpl{Pubsub: psClientImpl, Log: l, OrderingBy: orderingBy}
+					m, err := mockPsImpl.message(csMap)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if m.OrderingKey != "" {
+						t.Fatalf("Testing Error, ErrorMessage: orderingBy: %v, got %s", orderingBy, m.OrderingKey)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed to parse the ordering key path.",
+			runner: func(t *testing.T) {
+				for _, orderingBy := range []string{"documentKey..yyyyy", ".documentKey", "documentKey."} {
+					if _, err := ParseOrderingBy(orderingBy); err == nil {
+						t.Fatalf("Not behaving as intended. orderingBy: %s", orderingBy)
+					}
+				}
+				if path, err := ParseOrderingBy(""); err != nil || path != nil {
 					t.Fatalf("Not behaving as intended.")
 				}
 			},
@@ -187,3 +444,50 @@ func Test_ExportToPubSub(t *testing.T) {
 		t.Run(v.name, v.runner)
 	}
 }
+
+func Test_NewPublishSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to use the defaults of the client library.",
+			runner: func(t *testing.T) {
+				if !reflect.DeepEqual(NewPublishSettings(pubsubConfig.PubSub{}), pubsub.DefaultPublishSettings) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to override the settings set.",
+			runner: func(t *testing.T) {
+				settings := NewPublishSettings(pubsubConfig.PubSub{PublishDelayThresholdMSec: 50, PublishCountThreshold: 10, PublishTimeoutMSec: 3000})
+				want := pubsub.DefaultPublishSettings
+				want.DelayThreshold = 50 * time.Millisecond
+				want.CountThreshold = 10
+				want.Timeout = 3 * time.Second
+				if !reflect.DeepEqual(settings, want) {
+					t.Fatalf("Testing Error, ErrorMessage: %v", settings)
+				}
+			},
+		},
+		{
+			name: "Pass to block publishing over the outstanding limits by the flow control.",
+			runner: func(t *testing.T) {
+				settings := NewPublishSettings(pubsubConfig.PubSub{MaxOutstandingMessages: 1000, MaxOutstandingBytes: 10000000})
+				want := pubsub.FlowControlSettings{
+					MaxOutstandingMessages: 1000,
+					MaxOutstandingBytes:    10000000,
+					LimitExceededBehavior:  pubsub.FlowControlBlock,
+				}
+				if !reflect.DeepEqual(settings.FlowControlSettings, want) {
+					t.Fatalf("Testing Error, ErrorMessage: %v", settings.FlowControlSettings)
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
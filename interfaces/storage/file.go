@@ -28,7 +28,7 @@ func (f *fileStorageCli) PutObject(_ context.Context, key, value string) error {
 		os.MkdirAll(f.volumePath, 0777)
 	}
 
-	fp, err := os.OpenFile(key, os.O_WRONLY|os.O_CREATE, 0664)
+	fp, err := os.OpenFile(key, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
 
 	if err != nil {
 		return errors.InternalServerError.Wrap("Failed to open file.", err)
@@ -0,0 +1,146 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// avroEmbedded writes each message as an Avro Object Container File, which has the schema in its header.
+	avroEmbedded = "embedded"
+	// avroReferenced writes each message in the Avro single object encoding, which refers to the schema by its fingerprint.
+	avroReferenced = "referenced"
+)
+
+// avroSchema is the schema of change streams. The documents are in relaxed Extended JSON.
+const avroSchema = `{
+  "type": "record",
+  "name": "ChangeStream",
+  "namespace": "mxtransporter",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "operationType", "type": "string"},
+    {"name": "clusterTime", "type": {
+      "type": "record",
+      "name": "Timestamp",
+      "fields": [{"name": "t", "type": "long"}, {"name": "i", "type": "long"}]
+    }},
+    {"name": "ns", "type": ["null", {
+      "type": "record",
+      "name": "Namespace",
+      "fields": [{"name": "db", "type": "string"}, {"name": "coll", "type": ["null", "string"], "default": null}]
+    }], "default": null},
+    {"name": "documentKey", "type": ["null", "string"], "default": null},
+    {"name": "fullDocument", "type": ["null", "string"], "default": null},
+    {"name": "updateDescription", "type": ["null", "string"], "default": null},
+    {"name": "fullDocumentBeforeChange", "type": ["null", "string"], "default": null}
+  ]
+}`
+
+// avroSingleObjectMarker is the header of the Avro single object encoding.
+var avroSingleObjectMarker = []byte{0xC3, 0x01}
+
+type (
+	avroEncoder struct {
+		schema      avro.Schema
+		referenced  bool
+		fingerprint []byte
+	}
+
+	avroRecord struct {
+		ID                       string         `avro:"id"`
+		OperationType            string         `avro:"operationType"`
+		ClusterTime              avroTimestamp  `avro:"clusterTime"`
+		Ns                       *avroNamespace `avro:"ns"`
+		DocumentKey              *string        `avro:"documentKey"`
+		FullDocument             *string        `avro:"fullDocument"`
+		UpdateDescription        *string        `avro:"updateDescription"`
+		FullDocumentBeforeChange *string        `avro:"fullDocumentBeforeChange"`
+	}
+
+	avroTimestamp struct {
+		T int64 `avro:"t"`
+		I int64 `avro:"i"`
+	}
+
+	avroNamespace struct {
+		Db   string  `avro:"db"`
+		Coll *string `avro:"coll"`
+	}
+)
+
+func newAvroEncoder(schemaMode string) (*avroEncoder, error) {
+	schema, err := avro.Parse(avroSchema)
+	if err != nil {
+		return nil, errors.InternalServerError.Wrap("Failed to parse the Avro schema.", err)
+	}
+	e := &avroEncoder{schema: schema}
+	switch schemaMode {
+	case "", avroEmbedded:
+	case avroReferenced:
+		fp, err := schema.FingerprintUsing(avro.CRC64AvroLE)
+		if err != nil {
+			return nil, errors.InternalServerError.Wrap("Failed to get the fingerprint of the Avro schema.", err)
+		}
+		e.referenced = true
+		e.fingerprint = fp
+	default:
+		return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("EXPORT_AVRO_SCHEMA must be embedded or referenced. you set %s", schemaMode))
+	}
+	return e, nil
+}
+
+func (e *avroEncoder) Encode(cs primitive.M) ([]byte, error) {
+	r, err := newRecord(cs)
+	if err != nil {
+		return nil, err
+	}
+	ar := avroRecord{
+		ID:                       r.ID,
+		OperationType:            r.OperationType,
+		ClusterTime:              avroTimestamp{T: int64(r.ClusterTime.T), I: int64(r.ClusterTime.I)},
+		DocumentKey:              r.DocumentKey,
+		FullDocument:             r.FullDocument,
+		UpdateDescription:        r.UpdateDescription,
+		FullDocumentBeforeChange: r.FullDocumentBeforeChange,
+	}
+	if r.Ns != nil {
+		ar.Ns = &avroNamespace{Db: r.Ns.Db, Coll: r.Ns.Coll}
+	}
+
+	if e.referenced {
+		b, err := avro.Marshal(e.schema, ar)
+		if err != nil {
+			return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to encode change streams to Avro.", err)
+		}
+		out := make([]byte, 0, len(avroSingleObjectMarker)+len(e.fingerprint)+len(b))
+		out = append(out, avroSingleObjectMarker...)
+		out = append(out, e.fingerprint...)
+		return append(out, b...), nil
+	}
+
+	var buf bytes.Buffer
+	enc, err := ocf.NewEncoderWithSchema(e.schema, &buf)
+	if err != nil {
+		return nil, errors.InternalServerError.Wrap("Failed to create the Avro encoder.", err)
+	}
+	if err := enc.Encode(ar); err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to encode change streams to Avro.", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to encode change streams to Avro.", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *avroEncoder) ContentType() string {
+	if e.referenced {
+		return "application/vnd.apache.avro+binary"
+	}
+	return "application/avro"
+}
@@ -0,0 +1,152 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// cloudEventsStructured puts the attributes and the data of an event in a JSON object.
+	cloudEventsStructured = "structured"
+	// cloudEventsBinary puts the data of an event in the payload and the attributes in the attributes of messages.
+	cloudEventsBinary = "binary"
+
+	cloudEventsSpecVersion       = "1.0"
+	cloudEventsDefaultTypePrefix = "com.mongodb.changestream."
+	// cloudEventsAttributePrefix is the prefix of the attributes of messages in the binary content mode.
+	cloudEventsAttributePrefix = "ce-"
+)
+
+type (
+	cloudEventsEncoder struct {
+		canonical  bool
+		binary     bool
+		typePrefix string
+	}
+
+	// cloudEvent is a CloudEvents 1.0 event in the structured content mode.
+	cloudEvent struct {
+		SpecVersion     string          `json:"specversion"`
+		ID              string          `json:"id"`
+		Source          string          `json:"source"`
+		Type            string          `json:"type"`
+		Time            string          `json:"time,omitempty"`
+		Subject         string          `json:"subject,omitempty"`
+		DataContentType string          `json:"datacontenttype"`
+		Data            json.RawMessage `json:"data"`
+	}
+)
+
+func newCloudEventsEncoder(canonical bool, mode, typePrefix string) (*cloudEventsEncoder, error) {
+	e := &cloudEventsEncoder{canonical: canonical, typePrefix: typePrefix}
+	switch mode {
+	case "", cloudEventsStructured:
+	case cloudEventsBinary:
+		e.binary = true
+	default:
+		return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("EXPORT_CLOUDEVENTS_MODE must be structured or binary. you set %s", mode))
+	}
+	if e.typePrefix == "" {
+		e.typePrefix = cloudEventsDefaultTypePrefix
+	}
+	return e, nil
+}
+
+// Encode returns the event in the structured content mode, or its data in the binary content mode.
+func (e *cloudEventsEncoder) Encode(cs primitive.M) ([]byte, error) {
+	ev, err := e.event(cs)
+	if err != nil {
+		return nil, err
+	}
+	if e.binary {
+		return ev.Data, nil
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams to CloudEvents.", err)
+	}
+	return b, nil
+}
+
+func (e *cloudEventsEncoder) ContentType() string {
+	if e.binary {
+		return "application/json"
+	}
+	return "application/cloudevents+json"
+}
+
+// Attributes returns the ce-* attributes of the event in the binary content mode.
+func (e *cloudEventsEncoder) Attributes(cs primitive.M) (map[string]string, error) {
+	if !e.binary {
+		return nil, nil
+	}
+	ev, err := e.event(cs)
+	if err != nil {
+		return nil, err
+	}
+	attrs := map[string]string{
+		cloudEventsAttributePrefix + "specversion": ev.SpecVersion,
+		cloudEventsAttributePrefix + "id":          ev.ID,
+		cloudEventsAttributePrefix + "source":      ev.Source,
+		cloudEventsAttributePrefix + "type":        ev.Type,
+		"content-type":                             ev.DataContentType,
+	}
+	if ev.Time != "" {
+		attrs[cloudEventsAttributePrefix+"time"] = ev.Time
+	}
+	if ev.Subject != "" {
+		attrs[cloudEventsAttributePrefix+"subject"] = ev.Subject
+	}
+	return attrs, nil
+}
+
+// event makes the event of cs, whose data is cs in Extended JSON.
+func (e *cloudEventsEncoder) event(cs primitive.M) (*cloudEvent, error) {
+	ev := &cloudEvent{SpecVersion: cloudEventsSpecVersion, DataContentType: "application/json"}
+	if id, ok := cs["_id"].(primitive.M); ok {
+		ev.ID, _ = id["_data"].(string)
+	}
+	if ev.ID == "" {
+		return nil, errors.InternalServerError.New("Failed to get _data parameters of change streams for id of CloudEvents.")
+	}
+
+	opType, _ := cs["operationType"].(string)
+	ev.Type = e.typePrefix + opType
+
+	ev.Source = "/"
+	if ns, ok := cs["ns"].(primitive.M); ok {
+		if db, ok := ns["db"].(string); ok {
+			ev.Source += db
+		}
+		if coll, ok := ns["coll"].(string); ok {
+			ev.Source += "/" + coll
+		}
+	}
+
+	// wallTime is in milliseconds and set since MongoDB 6.0, while clusterTime is in seconds.
+	if wt, ok := cs["wallTime"].(primitive.DateTime); ok {
+		ev.Time = wt.Time().UTC().Format(time.RFC3339Nano)
+	} else if ts, ok := cs["clusterTime"].(primitive.Timestamp); ok {
+		ev.Time = time.Unix(int64(ts.T), 0).UTC().Format(time.RFC3339)
+	}
+
+	// subject is _id of the document, which is the hex of ObjectId, a string as it is, or Extended JSON of the others.
+	if dk, ok := cs["documentKey"].(primitive.M); ok && dk["_id"] != nil {
+		subject, err := StringValue(dk["_id"])
+		if err != nil {
+			return nil, err
+		}
+		ev.Subject = subject
+	}
+
+	data, err := MarshalExtJSON(cs, e.canonical)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams to Extended JSON.", err)
+	}
+	ev.Data = data
+	return ev, nil
+}
@@ -0,0 +1,174 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// debeziumDefaultName is source.name of the Debezium format when EXPORT_DEBEZIUM_NAME is not set.
+const debeziumDefaultName = "mxtransporter"
+
+// debeziumOps is op of the Debezium format for operationType.
+// snapshot is the operationType of the documents exported by the initial snapshot.
+var debeziumOps = map[string]string{
+	"insert":   "c",
+	"update":   "u",
+	"replace":  "u",
+	"delete":   "d",
+	"snapshot": "r",
+}
+
+type (
+	debeziumEncoder struct {
+		canonical  bool
+		name       string
+		replicaSet string
+		now        func() time.Time
+	}
+
+	// debeziumEvent is the envelope of the Debezium MongoDB connector, whose documents are strings of Extended JSON.
+	debeziumEvent struct {
+		Before            *string         `json:"before"`
+		After             *string         `json:"after"`
+		UpdateDescription *debeziumUpdate `json:"updateDescription"`
+		Source            debeziumSource  `json:"source"`
+		Op                string          `json:"op"`
+		TsMs              int64           `json:"ts_ms"`
+		Transaction       interface{}     `json:"transaction"`
+	}
+
+	debeziumUpdate struct {
+		RemovedFields   []string                 `json:"removedFields"`
+		UpdatedFields   *string                  `json:"updatedFields"`
+		TruncatedArrays []debeziumTruncatedArray `json:"truncatedArrays"`
+	}
+
+	debeziumTruncatedArray struct {
+		Field string `json:"field"`
+		Size  int32  `json:"size"`
+	}
+
+	debeziumSource struct {
+		Connector  string  `json:"connector"`
+		Name       string  `json:"name"`
+		TsMs       int64   `json:"ts_ms"`
+		Snapshot   string  `json:"snapshot"`
+		Db         string  `json:"db"`
+		Rs         string  `json:"rs"`
+		Collection string  `json:"collection"`
+		Ord        uint32  `json:"ord"`
+		Lsid       *string `json:"lsid"`
+		TxnNumber  *int64  `json:"txnNumber"`
+	}
+)
+
+func newDebeziumEncoder(canonical bool, name, replicaSet string) *debeziumEncoder {
+	if name == "" {
+		name = debeziumDefaultName
+	}
+	return &debeziumEncoder{canonical: canonical, name: name, replicaSet: replicaSet, now: time.Now}
+}
+
+// Encode returns nil for the operations which the Debezium format has no op for, e.g. drop and rename.
+func (e *debeziumEncoder) Encode(cs primitive.M) ([]byte, error) {
+	opType, _ := cs["operationType"].(string)
+	op, ok := debeziumOps[opType]
+	if !ok {
+		return nil, nil
+	}
+
+	ts, _ := cs["clusterTime"].(primitive.Timestamp)
+	ev := debeziumEvent{
+		Source: debeziumSource{
+			Connector: "mongodb",
+			Name:      e.name,
+			TsMs:      int64(ts.T) * 1000,
+			Snapshot:  fmt.Sprintf("%t", op == "r"),
+			Rs:        e.replicaSet,
+			Ord:       ts.I,
+		},
+		Op:   op,
+		TsMs: e.now().UnixMilli(),
+	}
+	if ns, ok := cs["ns"].(primitive.M); ok {
+		ev.Source.Db, _ = ns["db"].(string)
+		ev.Source.Collection, _ = ns["coll"].(string)
+	}
+	if txnNumber, ok := cs["txnNumber"].(int64); ok {
+		ev.Source.TxnNumber = &txnNumber
+	}
+
+	var err error
+	if ev.Source.Lsid, err = e.document(cs, "lsid"); err != nil {
+		return nil, err
+	}
+	if ev.Before, err = e.document(cs, "fullDocumentBeforeChange"); err != nil {
+		return nil, err
+	}
+	if op != "d" {
+		if ev.After, err = e.document(cs, "fullDocument"); err != nil {
+			return nil, err
+		}
+	}
+	if ud, ok := cs["updateDescription"].(primitive.M); ok && opType == "update" {
+		if ev.UpdateDescription, err = e.update(ud); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams to the Debezium format.", err)
+	}
+	return b, nil
+}
+
+func (*debeziumEncoder) ContentType() string {
+	return "application/json"
+}
+
+// document returns the field k of cs in Extended JSON, or nil if it is not set.
+func (e *debeziumEncoder) document(cs primitive.M, k string) (*string, error) {
+	v, ok := cs[k]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	b, err := MarshalExtJSON(v, e.canonical)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap(fmt.Sprintf("Failed to marshal change streams json %s parameter.", k), err)
+	}
+	s := string(b)
+	return &s, nil
+}
+
+func (e *debeziumEncoder) update(ud primitive.M) (*debeziumUpdate, error) {
+	u := &debeziumUpdate{}
+	var err error
+	if u.UpdatedFields, err = e.document(ud, "updatedFields"); err != nil {
+		return nil, err
+	}
+	if removed, ok := ud["removedFields"].(primitive.A); ok {
+		for _, f := range removed {
+			if s, ok := f.(string); ok {
+				u.RemovedFields = append(u.RemovedFields, s)
+			}
+		}
+	}
+	if truncated, ok := ud["truncatedArrays"].(primitive.A); ok {
+		for _, t := range truncated {
+			m, ok := t.(primitive.M)
+			if !ok {
+				continue
+			}
+			ta := debeziumTruncatedArray{}
+			ta.Field, _ = m["field"].(string)
+			ta.Size, _ = m["newSize"].(int32)
+			u.TruncatedArrays = append(u.TruncatedArrays, ta)
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,252 @@
+package encoder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	encoderConfig "github.com/cam-inc/mxtransporter/config/encoder"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type format string
+
+const (
+	// legacy joins the JSON of the fields of change streams with |, which is the format before EXPORT_FORMAT.
+	legacy format = "legacy"
+	// jsonFormat is a single JSON object of change streams.
+	jsonFormat format = "json"
+	// extJSON is MongoDB Extended JSON of change streams, which keeps the BSON types.
+	extJSON format = "extjson"
+	// avroFormat is Avro of the record in avroSchema.
+	avroFormat format = "avro"
+	// protobuf is Protocol Buffers of the message in change_stream.proto.
+	protobuf format = "protobuf"
+	// debezium is the envelope of the Debezium MongoDB connector in JSON.
+	debezium format = "debezium"
+	// cloudEvents is a CloudEvents 1.0 event in the structured or binary content mode.
+	cloudEvents format = "cloudevents"
+)
+
+const (
+	extJSONRelaxed   = "relaxed"
+	extJSONCanonical = "canonical"
+)
+
+// Encoder encodes change streams into the payload of a message.
+type Encoder interface {
+	// Encode returns nil for change streams which the format does not represent, which are not exported.
+	Encode(cs primitive.M) ([]byte, error)
+	// ContentType is the media type of the payload, e.g. application/json.
+	ContentType() string
+}
+
+// AttributesEncoder is an Encoder which also puts metadata of change streams in the attributes of messages,
+// e.g. the binary content mode of CloudEvents. Only destinations with attributes of messages (Pub/Sub) support it.
+type AttributesEncoder interface {
+	Encoder
+	// Attributes returns the attributes of the message of cs, which are nil if the Encoder does not use them.
+	Attributes(cs primitive.M) (map[string]string, error)
+}
+
+// New returns the Encoder of EXPORT_FORMAT for the export destination, which is legacy by default.
+func New(dst string) (Encoder, error) {
+	cfg := encoderConfig.EncoderConfig(dst)
+	canonical, err := isCanonical(cfg.ExtJSONMode)
+	if err != nil {
+		return nil, err
+	}
+	switch format(cfg.Format) {
+	case "", legacy:
+		return &legacyEncoder{canonical: canonical}, nil
+	case jsonFormat:
+		return &jsonEncoder{canonical: canonical}, nil
+	case extJSON:
+		return &extJSONEncoder{canonical: canonical}, nil
+	case avroFormat:
+		return newAvroEncoder(cfg.AvroSchema)
+	case protobuf:
+		return &protobufEncoder{}, nil
+	case debezium:
+		return newDebeziumEncoder(canonical, cfg.DebeziumName, cfg.DebeziumReplicaSet), nil
+	case cloudEvents:
+		return newCloudEventsEncoder(canonical, cfg.CloudEventsMode, cfg.CloudEventsTypePrefix)
+	}
+	return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("EXPORT_FORMAT must be legacy, json, extjson, avro, protobuf, debezium or cloudevents. you set %s", cfg.Format))
+}
+
+// Envelope returns the Encoder of EXPORT_FORMAT for the export destination if the format is an event envelope in JSON,
+// which destinations with their own format (e.g. the file exporter) can output instead. Otherwise it returns nil.
+func Envelope(dst string) (Encoder, error) {
+	switch format(encoderConfig.EncoderConfig(dst).Format) {
+	case debezium, cloudEvents:
+		e, err := New(dst)
+		if err != nil {
+			return nil, err
+		}
+		if err := WithoutAttributes(e); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+	return nil, nil
+}
+
+// WithoutAttributes returns an error if e puts metadata in the attributes of messages,
+// for the export destinations which have no attributes of messages.
+func WithoutAttributes(e Encoder) error {
+	if ce, ok := e.(*cloudEventsEncoder); ok && ce.binary {
+		return errors.InternalServerErrorEnvGet.New("EXPORT_CLOUDEVENTS_MODE=binary is supported by Pub/Sub only.")
+	}
+	return nil
+}
+
+// Legacy returns the Encoder of the legacy format in relaxed Extended JSON.
+func Legacy() Encoder {
+	return &legacyEncoder{}
+}
+
+// CanonicalExtJSON reports whether EXPORT_EXTJSON_MODE for the export destination is canonical.
+func CanonicalExtJSON(dst string) (bool, error) {
+	return isCanonical(encoderConfig.EncoderConfig(dst).ExtJSONMode)
+}
+
+// MarshalExtJSON returns v, a value of change streams, in Extended JSON, so that BSON types are kept.
+// Unlike bson.MarshalExtJSON, v is not required to be a document, nil is null,
+// and the keys of maps are sorted as encoding/json does, so that the same change stream is the same bytes.
+func MarshalExtJSON(v interface{}, canonical bool) ([]byte, error) {
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: sortKeys(v)}}, canonical, false)
+	if err != nil {
+		return nil, err
+	}
+	// Trim {"v": and }, which wrap the value.
+	return b[len(`{"v":`) : len(b)-1], nil
+}
+
+// StringValue returns v, a value of change streams, as a string: strings as they are, ObjectId in hex,
+// and the others in relaxed Extended JSON, so that the same value is always the same string.
+func StringValue(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case primitive.ObjectID:
+		return t.Hex(), nil
+	}
+	b, err := MarshalExtJSON(v, false)
+	if err != nil {
+		return "", errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal the value of change streams.", err)
+	}
+	return string(b), nil
+}
+
+func sortKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.M:
+		return sortMap(t)
+	case map[string]interface{}:
+		return sortMap(t)
+	case primitive.D:
+		d := make(primitive.D, len(t))
+		for i, e := range t {
+			d[i] = primitive.E{Key: e.Key, Value: sortKeys(e.Value)}
+		}
+		return d
+	case primitive.A:
+		a := make(primitive.A, len(t))
+		for i, e := range t {
+			a[i] = sortKeys(e)
+		}
+		return a
+	case []interface{}:
+		a := make(primitive.A, len(t))
+		for i, e := range t {
+			a[i] = sortKeys(e)
+		}
+		return a
+	}
+	return v
+}
+
+func sortMap(m map[string]interface{}) primitive.D {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	d := make(primitive.D, 0, len(m))
+	for _, k := range keys {
+		d = append(d, primitive.E{Key: k, Value: sortKeys(m[k])})
+	}
+	return d
+}
+
+func isCanonical(mode string) (bool, error) {
+	switch mode {
+	case "", extJSONRelaxed:
+		return false, nil
+	case extJSONCanonical:
+		return true, nil
+	}
+	return false, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("EXPORT_EXTJSON_MODE must be relaxed or canonical. you set %s", mode))
+}
+
+// IsText reports whether the payload of e is text, which can be delimited by new lines.
+func IsText(e Encoder) bool {
+	ct := e.ContentType()
+	return strings.HasPrefix(ct, "text/") || ct == "application/json" || strings.HasSuffix(ct, "+json")
+}
+
+// record is the fields of change streams in the schemas of Avro and Protocol Buffers.
+// The documents are in relaxed Extended JSON, since they have no fixed schema.
+type record struct {
+	ID                       string
+	OperationType            string
+	ClusterTime              primitive.Timestamp
+	Ns                       *namespace
+	DocumentKey              *string
+	FullDocument             *string
+	UpdateDescription        *string
+	FullDocumentBeforeChange *string
+}
+
+type namespace struct {
+	Db   string
+	Coll *string
+}
+
+func newRecord(cs primitive.M) (*record, error) {
+	r := &record{}
+	if id, ok := cs["_id"].(primitive.M); ok {
+		r.ID, _ = id["_data"].(string)
+	}
+	r.OperationType, _ = cs["operationType"].(string)
+	r.ClusterTime, _ = cs["clusterTime"].(primitive.Timestamp)
+	if ns, ok := cs["ns"].(primitive.M); ok {
+		r.Ns = &namespace{}
+		r.Ns.Db, _ = ns["db"].(string)
+		if coll, ok := ns["coll"].(string); ok {
+			r.Ns.Coll = &coll
+		}
+	}
+
+	for k, f := range map[string]**string{
+		"documentKey":              &r.DocumentKey,
+		"fullDocument":             &r.FullDocument,
+		"updateDescription":        &r.UpdateDescription,
+		"fullDocumentBeforeChange": &r.FullDocumentBeforeChange,
+	} {
+		v, ok := cs[k]
+		if !ok || v == nil {
+			continue
+		}
+		b, err := MarshalExtJSON(v, false)
+		if err != nil {
+			return nil, errors.InternalServerErrorJsonMarshal.Wrap(fmt.Sprintf("Failed to marshal change streams json %s parameter.", k), err)
+		}
+		s := string(b)
+		*f = &s
+	}
+	return r, nil
+}
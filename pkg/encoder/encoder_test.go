@@ -0,0 +1,442 @@
+//go:build test
+// +build test
+
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/hamba/avro/v2"
+	"github.com/hamba/avro/v2/ocf"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func Test_Encode(t *testing.T) {
+	oid, _ := primitive.ObjectIDFromHex("62a3f1a5e2a8b1c2d3e4f5a6")
+	cs := primitive.M{
+		"_id":           primitive.M{"_data": "00001"},
+		"operationType": "insert",
+		"clusterTime":   primitive.Timestamp{T: 1654000000, I: 2},
+		"fullDocument":  primitive.M{"_id": oid, "count": int64(3)},
+		"ns":            primitive.M{"db": "test", "coll": "users"},
+		"documentKey":   primitive.M{"_id": oid},
+	}
+	clusterTime := formatClusterTime(cs["clusterTime"])
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to encode in the legacy format by default.",
+			runner: func(t *testing.T) {
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := `{"_data":"00001"}|insert|` + clusterTime + `|{"_id":{"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"},"count":3}|{"coll":"users","db":"test"}|{"_id":{"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"}}|null`
+				if string(b) != want {
+					t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s", want, b)
+				}
+				if !IsText(e) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to encode the legacy format in canonical Extended JSON.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_EXTJSON_MODE, "canonical")
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if want := `|{"_id":{"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"},"count":{"$numberLong":"3"}}|`; !bytes.Contains(b, []byte(want)) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s", want, b)
+				}
+			},
+		},
+		{
+			name: "Pass to marshal values to Extended JSON with the keys sorted.",
+			runner: func(t *testing.T) {
+				d, _ := primitive.ParseDecimal128("1.10")
+				for v, want := range map[interface{}]string{
+					"text":                          `"text"`,
+					int32(1):                        `1`,
+					primitive.Timestamp{T: 1, I: 2}: `{"$timestamp":{"t":1,"i":2}}`,
+				} {
+					b, err := MarshalExtJSON(v, false)
+					if err != nil || string(b) != want {
+						t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s, err: %v", want, b, err)
+					}
+				}
+				b, err := MarshalExtJSON(primitive.M{"z": d, "a": primitive.A{primitive.M{"y": nil, "b": primitive.NewDateTimeFromTime(time.Unix(0, 0))}}}, true)
+				if want := `{"a":[{"b":{"$date":{"$numberLong":"0"}},"y":null}],"z":{"$numberDecimal":"1.10"}}`; err != nil || string(b) != want {
+					t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s, err: %v", want, b, err)
+				}
+				if b, err := MarshalExtJSON(nil, false); err != nil || string(b) != "null" {
+					t.Fatalf("Testing Error, ErrorMessage: want: null, got: %s, err: %v", b, err)
+				}
+			},
+		},
+		{
+			name: "Pass to encode in JSON.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "json")
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				var got map[string]interface{}
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got["operationType"] != "insert" || got["clusterTime"] != clusterTime {
+					t.Fatalf("Testing Error, ErrorMessage: got: %s", b)
+				}
+				if _, ok := got["updateDescription"]; ok {
+					t.Fatalf("Testing Error, ErrorMessage: the field which is not in change streams is encoded, got: %s", b)
+				}
+			},
+		},
+		{
+			name: "Pass to encode in relaxed and canonical Extended JSON.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "extjson")
+				for mode, want := range map[string]string{
+					"relaxed":   `"count":3`,
+					"canonical": `"count":{"$numberLong":"3"}`,
+				} {
+					t.Setenv(constant.EXPORT_EXTJSON_MODE, mode)
+					e, err := New("pubsub")
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					b, err := e.Encode(cs)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if !bytes.Contains(b, []byte(want)) || !bytes.Contains(b, []byte(`"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"`)) {
+						t.Fatalf("Testing Error, ErrorMessage: mode: %s, got: %s", mode, b)
+					}
+					var got primitive.M
+					if err := bson.UnmarshalExtJSON(b, true, &got); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if got["clusterTime"] != cs["clusterTime"] {
+						t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", cs["clusterTime"], got["clusterTime"])
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to encode in Avro with the schema embedded.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "avro")
+				e, err := New("kinesisStream")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				dec, err := ocf.NewDecoder(bytes.NewReader(b))
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				var got avroRecord
+				if !dec.HasNext() {
+					t.Fatalf("Testing Error, ErrorMessage: no record is encoded.")
+				}
+				if err := dec.Decode(&got); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got.ID != "00001" || got.ClusterTime.T != 1654000000 || got.Ns == nil || *got.Ns.Coll != "users" || got.UpdateDescription != nil {
+					t.Fatalf("Testing Error, ErrorMessage: got: %+v", got)
+				}
+				if IsText(e) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to encode in Avro with the schema referenced by its fingerprint.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "avro")
+				t.Setenv(constant.EXPORT_AVRO_SCHEMA, "referenced")
+				e, err := New("kinesisStream")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				schema := avro.MustParse(avroSchema)
+				if !bytes.HasPrefix(b, avroSingleObjectMarker) {
+					t.Fatalf("Testing Error, ErrorMessage: the header is wrong, got: %x", b)
+				}
+				fp, err := schema.FingerprintUsing(avro.CRC64AvroLE)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got := b[2:10]; !bytes.Equal(got, fp) || binary.LittleEndian.Uint64(got) == 0 {
+					t.Fatalf("Testing Error, ErrorMessage: want: %x, got: %x", fp, got)
+				}
+				var got avroRecord
+				if err := avro.Unmarshal(schema, b[10:], &got); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got.OperationType != "insert" || *got.DocumentKey != `{"_id":{"$oid":"62a3f1a5e2a8b1c2d3e4f5a6"}}` {
+					t.Fatalf("Testing Error, ErrorMessage: got: %+v", got)
+				}
+			},
+		},
+		{
+			name: "Pass to encode in Protocol Buffers.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "protobuf")
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				m := dynamicpb.NewMessage(changeStreamDescriptor)
+				if err := proto.Unmarshal(b, m); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				fields := changeStreamDescriptor.Fields()
+				if got := m.Get(fields.ByName("operation_type")).String(); got != "insert" {
+					t.Fatalf("Testing Error, ErrorMessage: want: insert, got: %s", got)
+				}
+				ts := m.Get(fields.ByName("cluster_time")).Message()
+				if got := ts.Get(ts.Descriptor().Fields().ByName("i")).Uint(); got != 2 {
+					t.Fatalf("Testing Error, ErrorMessage: want: 2, got: %d", got)
+				}
+				if m.Has(fields.ByName("update_description")) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to encode in the Debezium envelope.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "debezium")
+				t.Setenv(constant.EXPORT_DEBEZIUM_REPLICA_SET, "rs0")
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				e.(*debeziumEncoder).now = func() time.Time { return time.UnixMilli(1654000001234) }
+
+				update := primitive.M{
+					"_id":           primitive.M{"_data": "00002"},
+					"operationType": "update",
+					"clusterTime":   primitive.Timestamp{T: 1654000000, I: 3},
+					"ns":            primitive.M{"db": "test", "coll": "users"},
+					"documentKey":   primitive.M{"_id": oid},
+					"txnNumber":     int64(5),
+					"updateDescription": primitive.M{
+						"updatedFields":   primitive.M{"count": int64(4)},
+						"removedFields":   primitive.A{"name"},
+						"truncatedArrays": primitive.A{primitive.M{"field": "tags", "newSize": int32(1)}},
+					},
+					"fullDocumentBeforeChange": primitive.M{"_id": oid, "count": int64(3), "name": "Alice"},
+				}
+				deleted := primitive.M{
+					"_id":           primitive.M{"_data": "00003"},
+					"operationType": "delete",
+					"clusterTime":   primitive.Timestamp{T: 1654000000, I: 4},
+					"ns":            primitive.M{"db": "test", "coll": "users"},
+					"documentKey":   primitive.M{"_id": oid},
+				}
+				snapshot := primitive.M{}
+				for k, v := range cs {
+					snapshot[k] = v
+				}
+				snapshot["operationType"] = "snapshot"
+
+				for _, c := range []struct {
+					cs   primitive.M
+					want string
+				}{
+					{cs, `{"before":null,"after":"{\"_id\":{\"$oid\":\"62a3f1a5e2a8b1c2d3e4f5a6\"},\"count\":3}","updateDescription":null,` +
+						`"source":{"connector":"mongodb","name":"mxtransporter","ts_ms":1654000000000,"snapshot":"false","db":"test","rs":"rs0","collection":"users","ord":2,"lsid":null,"txnNumber":null},` +
+						`"op":"c","ts_ms":1654000001234,"transaction":null}`},
+					{update, `{"before":"{\"_id\":{\"$oid\":\"62a3f1a5e2a8b1c2d3e4f5a6\"},\"count\":3,\"name\":\"Alice\"}","after":null,` +
+						`"updateDescription":{"removedFields":["name"],"updatedFields":"{\"count\":4}","truncatedArrays":[{"field":"tags","size":1}]},` +
+						`"source":{"connector":"mongodb","name":"mxtransporter","ts_ms":1654000000000,"snapshot":"false","db":"test","rs":"rs0","collection":"users","ord":3,"lsid":null,"txnNumber":5},` +
+						`"op":"u","ts_ms":1654000001234,"transaction":null}`},
+					{deleted, `{"before":null,"after":null,"updateDescription":null,` +
+						`"source":{"connector":"mongodb","name":"mxtransporter","ts_ms":1654000000000,"snapshot":"false","db":"test","rs":"rs0","collection":"users","ord":4,"lsid":null,"txnNumber":null},` +
+						`"op":"d","ts_ms":1654000001234,"transaction":null}`},
+				} {
+					b, err := e.Encode(c.cs)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if string(b) != c.want {
+						t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s", c.want, b)
+					}
+				}
+
+				b, err := e.Encode(snapshot)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if !bytes.Contains(b, []byte(`"snapshot":"true"`)) || !bytes.Contains(b, []byte(`"op":"r"`)) {
+					t.Fatalf("Testing Error, ErrorMessage: got: %s", b)
+				}
+
+				if b, err := e.Encode(primitive.M{"_id": primitive.M{"_data": "00004"}, "operationType": "drop"}); err != nil || b != nil {
+					t.Fatalf("Testing Error, ErrorMessage: the operation without op is encoded, got: %s, err: %v", b, err)
+				}
+			},
+		},
+		{
+			name: "Pass to encode in CloudEvents of the structured content mode.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "cloudevents")
+				e, err := New("kinesisStream")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				b, err := e.Encode(cs)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				data, _ := MarshalExtJSON(cs, false)
+				want := `{"specversion":"1.0","id":"00001","source":"/test/users","type":"com.mongodb.changestream.insert","time":"2022-05-31T12:26:40Z",` +
+					`"subject":"62a3f1a5e2a8b1c2d3e4f5a6","datacontenttype":"application/json","data":` + string(data) + `}`
+				if string(b) != want {
+					t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s", want, b)
+				}
+				if e.ContentType() != "application/cloudevents+json" || !IsText(e) {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if err := WithoutAttributes(e); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Pass to encode in CloudEvents of the binary content mode.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "cloudevents")
+				t.Setenv(constant.EXPORT_CLOUDEVENTS_MODE, "binary")
+				t.Setenv(constant.EXPORT_CLOUDEVENTS_TYPE_PREFIX, "com.example.")
+				e, err := New("pubsub")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				withWallTime := primitive.M{"wallTime": primitive.NewDateTimeFromTime(time.UnixMilli(1654000000123))}
+				for k, v := range cs {
+					withWallTime[k] = v
+				}
+				withWallTime["documentKey"] = primitive.M{"_id": int32(7)}
+
+				b, err := e.Encode(withWallTime)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if data, _ := MarshalExtJSON(withWallTime, false); !bytes.Equal(b, data) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %s, got: %s", data, b)
+				}
+				attrs, err := e.(AttributesEncoder).Attributes(withWallTime)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				want := map[string]string{
+					"ce-specversion": "1.0",
+					"ce-id":          "00001",
+					"ce-source":      "/test/users",
+					"ce-type":        "com.example.insert",
+					"ce-time":        "2022-05-31T12:26:40.123Z",
+					"ce-subject":     "7",
+					"content-type":   "application/json",
+				}
+				if !reflect.DeepEqual(want, attrs) {
+					t.Fatalf("Testing Error, ErrorMessage: want: %v, got: %v", want, attrs)
+				}
+				if err := WithoutAttributes(e); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				t.Setenv(constant.EXPORT_FORMAT+"_FILE", "cloudevents")
+				if _, err := Envelope("file"); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if _, err := e.Encode(primitive.M{"operationType": "insert"}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to return the Encoder of an envelope only.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.EXPORT_FORMAT, "json")
+				if e, err := Envelope("file"); err != nil || e != nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				t.Setenv(constant.EXPORT_FORMAT+"_FILE", "debezium")
+				if e, err := Envelope("file"); err != nil || e == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by the format and the modes which are wrong.",
+			runner: func(t *testing.T) {
+				for k, v := range map[string]string{
+					constant.EXPORT_FORMAT:           "xml",
+					constant.EXPORT_EXTJSON_MODE:     "shell",
+					constant.EXPORT_AVRO_SCHEMA:      "registry",
+					constant.EXPORT_CLOUDEVENTS_MODE: "batched",
+				} {
+					t.Run(k, func(t *testing.T) {
+						switch k {
+						case constant.EXPORT_EXTJSON_MODE:
+							t.Setenv(constant.EXPORT_FORMAT, "extjson")
+						case constant.EXPORT_AVRO_SCHEMA:
+							t.Setenv(constant.EXPORT_FORMAT, "avro")
+						case constant.EXPORT_CLOUDEVENTS_MODE:
+							t.Setenv(constant.EXPORT_FORMAT, "cloudevents")
+						}
+						t.Setenv(k, v)
+						if _, err := New("pubsub"); err == nil {
+							t.Fatalf("Not behaving as intended. %s: %s", k, v)
+						}
+					})
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
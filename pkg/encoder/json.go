@@ -0,0 +1,94 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const clusterTimeLayout = "2006-01-02 15:04:05"
+
+type (
+	legacyEncoder struct {
+		canonical bool
+	}
+	jsonEncoder struct {
+		canonical bool
+	}
+	extJSONEncoder struct {
+		canonical bool
+	}
+)
+
+// Encode joins the fields of cs in Extended JSON with |, except that operationType is the string and clusterTime is the time.
+func (e *legacyEncoder) Encode(cs primitive.M) ([]byte, error) {
+	r := make([]string, 0, 8)
+	for _, k := range []string{"_id", "operationType", "clusterTime", "fullDocument", "ns", "documentKey", "updateDescription"} {
+		switch k {
+		case "operationType":
+			opType, _ := cs[k].(string)
+			r = append(r, opType)
+		case "clusterTime":
+			r = append(r, formatClusterTime(cs[k]))
+		default:
+			b, err := MarshalExtJSON(cs[k], e.canonical)
+			if err != nil {
+				return nil, errors.InternalServerErrorJsonMarshal.Wrap(fmt.Sprintf("Failed to marshal change streams json %s parameter.", k), err)
+			}
+			r = append(r, string(b))
+		}
+	}
+	// The pre-image is appended only when change streams are opened with it, to keep the format for the others.
+	if v, ok := cs["fullDocumentBeforeChange"]; ok {
+		b, err := MarshalExtJSON(v, e.canonical)
+		if err != nil {
+			return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json fullDocumentBeforeChange parameter.", err)
+		}
+		r = append(r, string(b))
+	}
+	return []byte(strings.Join(r, "|")), nil
+}
+
+func (*legacyEncoder) ContentType() string {
+	return "text/plain"
+}
+
+// Encode returns the fields of cs in a JSON object, whose values are the same as the legacy format.
+func (e *jsonEncoder) Encode(cs primitive.M) ([]byte, error) {
+	doc := make(primitive.M, len(cs))
+	for k, v := range cs {
+		doc[k] = v
+	}
+	if v, ok := cs["clusterTime"]; ok {
+		doc["clusterTime"] = formatClusterTime(v)
+	}
+	b, err := MarshalExtJSON(doc, e.canonical)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams json.", err)
+	}
+	return b, nil
+}
+
+func (*jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (e *extJSONEncoder) Encode(cs primitive.M) ([]byte, error) {
+	b, err := MarshalExtJSON(cs, e.canonical)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams to Extended JSON.", err)
+	}
+	return b, nil
+}
+
+func (*extJSONEncoder) ContentType() string {
+	return "application/json"
+}
+
+func formatClusterTime(v interface{}) string {
+	ts, _ := v.(primitive.Timestamp)
+	return time.Unix(int64(ts.T), 0).Format(clusterTimeLayout)
+}
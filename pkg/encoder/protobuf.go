@@ -0,0 +1,109 @@
+package encoder
+
+import (
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// changeStreamDescriptor is the descriptor of ChangeStream in change_stream.proto, which is built here
+// so that no generated code has to be kept in sync with it.
+var changeStreamDescriptor = mustChangeStreamDescriptor()
+
+type protobufEncoder struct{}
+
+func mustChangeStreamDescriptor() protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	u32 := descriptorpb.FieldDescriptorProto_TYPE_UINT32
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("change_stream.proto"),
+		Package: proto.String("mxtransporter"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("ChangeStream"),
+			NestedType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("Timestamp"), Field: []*descriptorpb.FieldDescriptorProto{field("t", 1, u32, ""), field("i", 2, u32, "")}},
+				{Name: proto.String("Namespace"), Field: []*descriptorpb.FieldDescriptorProto{field("db", 1, str, ""), field("coll", 2, str, "")}},
+			},
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, str, ""),
+				field("operation_type", 2, str, ""),
+				field("cluster_time", 3, msg, ".mxtransporter.ChangeStream.Timestamp"),
+				field("ns", 4, msg, ".mxtransporter.ChangeStream.Namespace"),
+				field("document_key", 5, str, ""),
+				field("full_document", 6, str, ""),
+				field("update_description", 7, str, ""),
+				field("full_document_before_change", 8, str, ""),
+			},
+		}},
+	}
+	f, err := protodesc.NewFile(fd, nil)
+	if err != nil {
+		panic(err)
+	}
+	return f.Messages().ByName("ChangeStream")
+}
+
+func (*protobufEncoder) Encode(cs primitive.M) ([]byte, error) {
+	r, err := newRecord(cs)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := changeStreamDescriptor.Fields()
+	m := dynamicpb.NewMessage(changeStreamDescriptor)
+	setString := func(name protoreflect.Name, s *string) {
+		if s != nil {
+			m.Set(fields.ByName(name), protoreflect.ValueOfString(*s))
+		}
+	}
+	setString("id", &r.ID)
+	setString("operation_type", &r.OperationType)
+
+	ts := dynamicpb.NewMessage(fields.ByName("cluster_time").Message())
+	ts.Set(ts.Descriptor().Fields().ByName("t"), protoreflect.ValueOfUint32(r.ClusterTime.T))
+	ts.Set(ts.Descriptor().Fields().ByName("i"), protoreflect.ValueOfUint32(r.ClusterTime.I))
+	m.Set(fields.ByName("cluster_time"), protoreflect.ValueOfMessage(ts))
+
+	if r.Ns != nil {
+		ns := dynamicpb.NewMessage(fields.ByName("ns").Message())
+		ns.Set(ns.Descriptor().Fields().ByName("db"), protoreflect.ValueOfString(r.Ns.Db))
+		if r.Ns.Coll != nil {
+			ns.Set(ns.Descriptor().Fields().ByName("coll"), protoreflect.ValueOfString(*r.Ns.Coll))
+		}
+		m.Set(fields.ByName("ns"), protoreflect.ValueOfMessage(ns))
+	}
+
+	setString("document_key", r.DocumentKey)
+	setString("full_document", r.FullDocument)
+	setString("update_description", r.UpdateDescription)
+	setString("full_document_before_change", r.FullDocumentBeforeChange)
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to encode change streams to Protocol Buffers.", err)
+	}
+	return b, nil
+}
+
+func (*protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
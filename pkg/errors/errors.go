@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 )
@@ -12,9 +13,12 @@ const (
 	InternalServerErrorEnvGet      = errType("500: environment variables get error")
 	InternalServerErrorClientGet   = errType("500: client get error")
 	InternalServerErrorJsonMarshal = errType("500: json marshal error")
+	InvalidErrorNameTemplate       = errType("400: name template error")
 	// mongodb
 	InternalServerErrorMongoDbConnect = errType("500: mongodb connect error")
 	InternalServerErrorMongoDbOperate = errType("500: mongodb operate error")
+	// InternalServerErrorChangeStreams is an error of opening or iterating change streams, after which they can be reopened.
+	InternalServerErrorChangeStreams = errType("500: change streams error")
 	// bigquery
 	InternalServerErrorBigqueryInsert = errType("500: bigquery insert error")
 	// pubsub
@@ -26,6 +30,8 @@ const (
 	InternalServerErrorKinesisStreamPut = errType("500: kinesis stream put error")
 	// local storage file
 	InternalServerErrorFilePut = errType("500: file put error")
+	// dead letter
+	InternalServerErrorDeadLetterPut = errType("500: dead letter put error")
 
 	//// Storage
 	// gcs
@@ -39,12 +45,48 @@ const (
 	InternalServerErrorS3NewClient = errType("500: initialize s3 client error")
 )
 
+// Error keeps the errType and the wrapped error so that callers can classify it, see IsRetryable.
+type Error struct {
+	Type    errType
+	file    string
+	line    int
+	msg     string
+	err     error
+	wrapped bool
+}
+
+func (e *Error) Error() string {
+	if !e.wrapped {
+		return fmt.Sprintf("file: %s, line: %d, errType: %s, orgErrMsg: %s", e.file, e.line, e.Type, e.msg)
+	}
+	return fmt.Sprintf("file: %s, line: %d, errType: %s, orgErrMsg: %s, errMsg: %s", e.file, e.line, e.Type, e.msg, e.err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
 func (e errType) New(msg string) error {
 	_, file, line, _ := runtime.Caller(1)
-	return fmt.Errorf("file: %s, line: %d, errType: %s, orgErrMsg: %s", file, line, e, msg)
+	return &Error{Type: e, file: file, line: line, msg: msg}
 }
 
 func (e errType) Wrap(msg string, err error) error {
 	_, file, line, _ := runtime.Caller(1)
-	return fmt.Errorf("file: %s, line: %d, errType: %s, orgErrMsg: %s, errMsg: %s", file, line, e, msg, err)
+	return &Error{Type: e, file: file, line: line, msg: msg, err: err, wrapped: true}
+}
+
+// HasType reports whether err, or an error it wraps, is an Error of t.
+func HasType(err error, t errType) bool {
+	for err != nil {
+		var e *Error
+		if !errors.As(err, &e) {
+			return false
+		}
+		if e.Type == t {
+			return true
+		}
+		err = e.err
+	}
+	return false
 }
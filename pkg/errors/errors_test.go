@@ -4,7 +4,9 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +26,32 @@ func Test_Wrap(t *testing.T) {
 		}
 	})
 }
+
+func Test_Error(t *testing.T) {
+	t.Run("Check that the wrapped error can be unwrapped.", func(t *testing.T) {
+		e := fmt.Errorf("yyy")
+		err := InternalServerError.Wrap("test error", e)
+		if !errors.Is(err, e) {
+			t.Fatalf("The wrapped error is not unwrapped.")
+		}
+		var tErr *Error
+		if !errors.As(err, &tErr) || tErr.Type != InternalServerError {
+			t.Fatalf("The errType is not kept.")
+		}
+		if !strings.Contains(err.Error(), "errType: 500: internal server error, orgErrMsg: test error, errMsg: yyy") {
+			t.Fatalf("The error message is not formatted as before. got: %s", err.Error())
+		}
+	})
+}
+
+func Test_HasType(t *testing.T) {
+	t.Run("Check that the errType of the wrapped errors is found.", func(t *testing.T) {
+		err := InternalServerError.Wrap("test error", fmt.Errorf("xxx: %w", InternalServerErrorChangeStreams.New("yyy")))
+		if !HasType(err, InternalServerError) || !HasType(err, InternalServerErrorChangeStreams) {
+			t.Fatalf("The errType of the wrapped error is not found.")
+		}
+		if HasType(err, InternalServerErrorBigqueryInsert) || HasType(fmt.Errorf("yyy"), InternalServerError) || HasType(nil, InternalServerError) {
+			t.Fatalf("Not behaving as intended.")
+		}
+	})
+}
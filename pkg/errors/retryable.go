@@ -0,0 +1,66 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fatalErrTypes are never retried regardless of the wrapped error, because the same input fails again.
+var fatalErrTypes = map[errType]struct{}{
+	InternalServerErrorEnvGet:      {},
+	InternalServerErrorJsonMarshal: {},
+}
+
+var retryableGrpcCodes = map[codes.Code]struct{}{
+	codes.Unavailable:       {},
+	codes.ResourceExhausted: {},
+	codes.DeadlineExceeded:  {},
+	codes.Aborted:           {},
+	codes.Internal:          {},
+	codes.Unknown:           {},
+}
+
+// IsRetryable reports whether err is a transient failure such as throttling, a 5xx response or a network reset,
+// so that exporting the same change streams again may succeed.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		if _, fatal := fatalErrTypes[e.Type]; fatal || strings.HasPrefix(string(e.Type), "400") {
+			return false
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == 429 || gErr.Code >= 500
+	}
+
+	var sErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &sErr) {
+		_, ok := retryableGrpcCodes[sErr.GRPCStatus().Code()]
+		return ok
+	}
+
+	if retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary {
+		return true
+	}
+
+	var nErr net.Error
+	return errors.As(err, &nErr)
+}
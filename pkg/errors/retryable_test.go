@@ -0,0 +1,44 @@
+//go:build test
+// +build test
+
+package errors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_IsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"Unknown error", InternalServerError.Wrap("test error", fmt.Errorf("yyy")), false},
+		{"Canceled", InternalServerError.Wrap("test error", context.Canceled), false},
+		{"Deadline exceeded", InternalServerErrorBigqueryInsert.Wrap("test error", context.DeadlineExceeded), true},
+		{"Marshal error is fatal", InternalServerErrorJsonMarshal.Wrap("test error", context.DeadlineExceeded), false},
+		{"4xx error type is fatal", InvalidErrorPubSubOrderingKey.Wrap("test error", context.DeadlineExceeded), false},
+		{"googleapi 503", InternalServerErrorBigqueryInsert.Wrap("test error", &googleapi.Error{Code: 503}), true},
+		{"googleapi 429", InternalServerErrorBigqueryInsert.Wrap("test error", &googleapi.Error{Code: 429}), true},
+		{"googleapi 400", InternalServerErrorBigqueryInsert.Wrap("test error", &googleapi.Error{Code: 400}), false},
+		{"grpc unavailable", InternalServerErrorPubSubPublish.Wrap("test error", status.Error(codes.Unavailable, "yyy")), true},
+		{"grpc permission denied", InternalServerErrorPubSubPublish.Wrap("test error", status.Error(codes.PermissionDenied, "yyy")), false},
+		{"Network error", InternalServerErrorKinesisStreamPut.Wrap("test error", &net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")}), true},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			if got := IsRetryable(v.err); got != v.want {
+				t.Fatalf("expect %v, got %v", v.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package fieldpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Parse splits a dotted path of change streams, e.g. fullDocument.address.city, into its field names.
+func Parse(path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	for _, s := range segments {
+		if s == "" {
+			return nil, errors.InternalServerErrorEnvGet.New(fmt.Sprintf("A path of change streams must be dot separated field names. you set %s", path))
+		}
+	}
+	return segments, nil
+}
+
+// Lookup returns the value of cs at path, in which numeric segments index arrays.
+func Lookup(cs primitive.M, path []string) (interface{}, bool) {
+	var v interface{} = cs
+	for _, s := range path {
+		var ok bool
+		switch t := v.(type) {
+		case primitive.M:
+			v, ok = t[s]
+		case primitive.D:
+			for _, e := range t {
+				if e.Key == s {
+					v, ok = e.Value, true
+					break
+				}
+			}
+		case primitive.A:
+			if i, err := strconv.Atoi(s); err == nil && i >= 0 && i < len(t) {
+				v, ok = t[i], true
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,78 @@
+//go:build test
+// +build test
+
+package fieldpath
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_Lookup(t *testing.T) {
+	cs := primitive.M{
+		"ns": primitive.M{"db": "shop", "coll": "orders"},
+		"fullDocument": primitive.M{
+			"tags":    primitive.A{"a", primitive.M{"name": "b"}},
+			"address": primitive.D{{Key: "city", Value: "Tokyo"}},
+			"deleted": nil,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to look up the value at a dotted path.",
+			runner: func(t *testing.T) {
+				for path, want := range map[string]interface{}{
+					"ns.coll":                   "orders",
+					"fullDocument.tags.0":       "a",
+					"fullDocument.tags.1":       primitive.M{"name": "b"},
+					"fullDocument.tags.1.name":  "b",
+					"fullDocument.address.city": "Tokyo",
+					"fullDocument.deleted":      nil,
+				} {
+					segments, err := Parse(path)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					v, ok := Lookup(cs, segments)
+					if !ok || !reflect.DeepEqual(v, want) {
+						t.Fatalf("Testing Error, ErrorMessage: path: %s, want: %v, got: %v", path, want, v)
+					}
+				}
+			},
+		},
+		{
+			name: "Pass to report a missing field.",
+			runner: func(t *testing.T) {
+				for _, path := range []string{"documentKey", "ns.coll.name", "fullDocument.tags.2", "fullDocument.tags.-1", "fullDocument.tags.x"} {
+					segments, err := Parse(path)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if v, ok := Lookup(cs, segments); ok {
+						t.Fatalf("Testing Error, ErrorMessage: path: %s, got: %v", path, v)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed to parse a path with an empty field name.",
+			runner: func(t *testing.T) {
+				for _, path := range []string{"", ".ns", "ns.", "fullDocument..name"} {
+					if _, err := Parse(path); err == nil {
+						t.Fatalf("Not behaving as intended. path: %s", path)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,71 @@
+package naming
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Template is the name of a topic, a stream, a dataset or a table, which can be a text/template evaluated per change stream,
+// e.g. cdc.{{.Database}}.{{.Collection}}. A name without actions is static and the same for every change stream.
+type Template struct {
+	name string
+	tmpl *template.Template
+}
+
+// data is what templates are evaluated against. Event is the change stream, e.g. {{.Event.fullDocument.tenantId}}.
+type data struct {
+	Database      string
+	Collection    string
+	OperationType string
+	Event         primitive.M
+}
+
+// Parse parses the name, in which a missing field of Event is an error rather than "<no value>".
+func Parse(name string) (*Template, error) {
+	t := &Template{name: name}
+	if !strings.Contains(name, "{{") {
+		return t, nil
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return nil, errors.InternalServerErrorEnvGet.Wrap(fmt.Sprintf("Failed to parse the name template %s.", name), err)
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+// Static reports whether the name is the same for every change stream.
+func (t *Template) Static() bool {
+	return t.tmpl == nil
+}
+
+// String returns the name as it is set.
+func (t *Template) String() string {
+	return t.name
+}
+
+// Execute returns the name for the change stream.
+func (t *Template) Execute(cs primitive.M) (string, error) {
+	if t.tmpl == nil {
+		return t.name, nil
+	}
+	d := data{Event: cs}
+	d.OperationType, _ = cs["operationType"].(string)
+	if ns, ok := cs["ns"].(primitive.M); ok {
+		d.Database, _ = ns["db"].(string)
+		d.Collection, _ = ns["coll"].(string)
+	}
+
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, d); err != nil {
+		return "", errors.InvalidErrorNameTemplate.Wrap(fmt.Sprintf("Failed to evaluate the name template %s.", t.name), err)
+	}
+	if b.Len() == 0 {
+		return "", errors.InvalidErrorNameTemplate.New(fmt.Sprintf("The name template %s is evaluated to an empty name for cs: %v", t.name, cs))
+	}
+	return b.String(), nil
+}
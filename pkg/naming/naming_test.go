@@ -0,0 +1,89 @@
+//go:build test
+// +build test
+
+package naming
+
+import (
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_Execute(t *testing.T) {
+	cs := primitive.M{
+		"operationType": "insert",
+		"ns":            primitive.M{"db": "shop", "coll": "orders"},
+		"fullDocument":  primitive.M{"tenantId": "t1"},
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to return a static name as it is.",
+			runner: func(t *testing.T) {
+				tmpl, err := Parse("topic")
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				name, err := tmpl.Execute(nil)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if !tmpl.Static() || name != "topic" {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to evaluate the template per change stream.",
+			runner: func(t *testing.T) {
+				for text, want := range map[string]string{
+					"cdc.{{.Database}}.{{.Collection}}":                "cdc.shop.orders",
+					"{{.Collection}}_{{.OperationType}}":               "orders_insert",
+					"{{.Collection}}_{{.Event.fullDocument.tenantId}}": "orders_t1",
+				} {
+					tmpl, err := Parse(text)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					name, err := tmpl.Execute(cs)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if tmpl.Static() || name != want {
+						t.Fatalf("Testing Error, ErrorMessage: expect %s, got %s", want, name)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed to parse the template.",
+			runner: func(t *testing.T) {
+				if _, err := Parse("{{.Collection"); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed to evaluate the template to a name.",
+			runner: func(t *testing.T) {
+				for _, text := range []string{"{{.Event.fullDocument.missing}}", "{{.Event.missing.tenantId}}", "{{if false}}x{{end}}"} {
+					tmpl, err := Parse(text)
+					if err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+					if _, err := tmpl.Execute(cs); err == nil || errors.IsRetryable(err) {
+						t.Fatalf("Testing Error, ErrorMessage: %s: %v", text, err)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,93 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	retryConfig "github.com/cam-inc/mxtransporter/config/retry"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+)
+
+const (
+	defaultBaseBackoff = 100 * time.Millisecond
+	defaultMaxBackoff  = 10 * time.Second
+)
+
+// Policy retries an operation with exponential backoff while it fails with a retryable error.
+type Policy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      bool
+	// Deadline bounds all attempts including the backoff between them. Zero means no deadline.
+	Deadline time.Duration
+	// Notify is called before waiting for the next attempt.
+	Notify func(err error, attempt int, wait time.Duration)
+}
+
+// NewPolicy builds a Policy from the configuration. Without configuration the operation is attempted only once.
+func NewPolicy(cfg retryConfig.Retry) Policy {
+	p := Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseBackoff: time.Duration(cfg.BaseBackoffMSec) * time.Millisecond,
+		MaxBackoff:  time.Duration(cfg.MaxBackoffMSec) * time.Millisecond,
+		Jitter:      cfg.Jitter,
+		Deadline:    time.Duration(cfg.DeadlineSec) * time.Second,
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseBackoff <= 0 {
+		p.BaseBackoff = defaultBaseBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	return p
+}
+
+// Do calls fn until it succeeds, fails with an error that is not retryable, or the attempts or the deadline run out.
+// The error of the last attempt is returned.
+func (p Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Deadline)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.MaxAttempts || !errors.IsRetryable(err) {
+			return err
+		}
+
+		wait := p.Backoff(attempt)
+		if p.Notify != nil {
+			p.Notify(err, attempt, wait)
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// Backoff returns the wait after the attempt, BaseBackoff * 2^(attempt-1) capped by MaxBackoff,
+// or a random duration up to it with Jitter ("full jitter").
+func (p Policy) Backoff(attempt int) time.Duration {
+	d := p.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if b := p.BaseBackoff << uint(shift); b > 0 && b < p.MaxBackoff {
+			d = b
+		}
+	}
+	if p.Jitter {
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+	return d
+}
@@ -0,0 +1,115 @@
+//go:build test
+// +build test
+
+package retry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	retryConfig "github.com/cam-inc/mxtransporter/config/retry"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+)
+
+func Test_Do(t *testing.T) {
+	ctx := context.Background()
+
+	retryable := errors.InternalServerErrorBigqueryInsert.Wrap("test error", context.DeadlineExceeded)
+	fatal := errors.InternalServerErrorJsonMarshal.Wrap("test error", fmt.Errorf("yyy"))
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Attempt only once without configuration.",
+			runner: func(t *testing.T) {
+				p := NewPolicy(retryConfig.Retry{})
+				calls := 0
+				if err := p.Do(ctx, func(_ context.Context) error {
+					calls++
+					return retryable
+				}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if calls != 1 {
+					t.Fatalf("expect 1 call, got %d", calls)
+				}
+			},
+		},
+		{
+			name: "Pass after retrying a retryable error.",
+			runner: func(t *testing.T) {
+				p := NewPolicy(retryConfig.Retry{MaxAttempts: 3, BaseBackoffMSec: 1, MaxBackoffMSec: 2})
+				calls := 0
+				if err := p.Do(ctx, func(_ context.Context) error {
+					calls++
+					if calls < 3 {
+						return retryable
+					}
+					return nil
+				}); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if calls != 3 {
+					t.Fatalf("expect 3 calls, got %d", calls)
+				}
+			},
+		},
+		{
+			name: "Do not retry a fatal error.",
+			runner: func(t *testing.T) {
+				p := NewPolicy(retryConfig.Retry{MaxAttempts: 3, BaseBackoffMSec: 1})
+				calls := 0
+				if err := p.Do(ctx, func(_ context.Context) error {
+					calls++
+					return fatal
+				}); err != fatal {
+					t.Fatalf("expect %v, got %v", fatal, err)
+				}
+				if calls != 1 {
+					t.Fatalf("expect 1 call, got %d", calls)
+				}
+			},
+		},
+		{
+			name: "Give up when the deadline is exceeded.",
+			runner: func(t *testing.T) {
+				p := NewPolicy(retryConfig.Retry{MaxAttempts: 100, BaseBackoffMSec: 10000})
+				p.Deadline = 10 * time.Millisecond
+				start := time.Now()
+				if err := p.Do(ctx, func(_ context.Context) error {
+					return retryable
+				}); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+				if time.Since(start) > time.Second {
+					t.Fatalf("Retrying was not stopped by the deadline.")
+				}
+			},
+		},
+		{
+			name: "Backoff grows exponentially and is capped.",
+			runner: func(t *testing.T) {
+				p := NewPolicy(retryConfig.Retry{BaseBackoffMSec: 100, MaxBackoffMSec: 1000})
+				for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 100: time.Second} {
+					if got := p.Backoff(attempt); got != want {
+						t.Fatalf("attempt %d: expect %v, got %v", attempt, want, got)
+					}
+				}
+				p.Jitter = true
+				for i := 0; i < 100; i++ {
+					if got := p.Backoff(3); got < 0 || got > 400*time.Millisecond {
+						t.Fatalf("expect a jittered backoff up to 400ms, got %v", got)
+					}
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -0,0 +1,166 @@
+package dead_letter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/cam-inc/mxtransporter/config"
+	dlConfig "github.com/cam-inc/mxtransporter/config/dead-letter"
+	"github.com/cam-inc/mxtransporter/interfaces/storage"
+	"github.com/cam-inc/mxtransporter/pkg/client"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const pubsubType = "pubsub"
+
+// DeadLetter keeps change streams that could not be exported, so that the pipeline can continue without them.
+type DeadLetter interface {
+	Send(ctx context.Context, dst string, cs primitive.M, cause error) error
+	Close() error
+}
+
+type (
+	record struct {
+		Destination  string          `json:"destination"`
+		Error        string          `json:"error"`
+		ResumeToken  string          `json:"resumeToken"`
+		FailedAt     time.Time       `json:"failedAt"`
+		ChangeStream json.RawMessage `json:"changeStream"`
+	}
+
+	storageDeadLetterImpl struct {
+		Log    *zap.SugaredLogger
+		client storage.StorageClient
+		path   string
+	}
+
+	publisher interface {
+		publish(ctx context.Context, data []byte) error
+		close() error
+	}
+
+	pubsubDeadLetterImpl struct {
+		Log       *zap.SugaredLogger
+		publisher publisher
+	}
+
+	pubsubPublisherImpl struct {
+		client *pubsub.Client
+		topic  *pubsub.Topic
+	}
+)
+
+func newRecord(dst string, cs primitive.M, cause error) (string, []byte, error) {
+	// Extended JSON is used because the change stream may be the one json.Marshal failed with.
+	csJson, err := bson.MarshalExtJSON(cs, false, false)
+	if err != nil {
+		return "", nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal change streams for dead letter.", err)
+	}
+
+	var rt string
+	if id, ok := cs["_id"].(primitive.M); ok {
+		rt, _ = id["_data"].(string)
+	}
+
+	r, err := json.Marshal(record{
+		Destination:  dst,
+		Error:        cause.Error(),
+		ResumeToken:  rt,
+		FailedAt:     time.Now(),
+		ChangeStream: csJson,
+	})
+	if err != nil {
+		return "", nil, errors.InternalServerErrorJsonMarshal.Wrap("Failed to marshal dead letter record.", err)
+	}
+	return rt, r, nil
+}
+
+func (d *storageDeadLetterImpl) Send(ctx context.Context, dst string, cs primitive.M, cause error) error {
+	rt, r, err := newRecord(dst, cs, cause)
+	if err != nil {
+		return err
+	}
+	if rt == "" {
+		rt = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	key := path.Clean(fmt.Sprintf("%s/%s_%s.json", d.path, dst, rt))
+	if err := d.client.PutObject(ctx, key, string(r)); err != nil {
+		return errors.InternalServerErrorDeadLetterPut.Wrap("Failed to put dead letter.", err)
+	}
+	d.Log.Warnf("Sent change streams to dead letter key:%s, destination:%s", key, dst)
+	return nil
+}
+
+func (*storageDeadLetterImpl) Close() error {
+	return nil
+}
+
+func (p *pubsubPublisherImpl) close() error {
+	p.topic.Stop()
+	return p.client.Close()
+}
+
+func (p *pubsubPublisherImpl) publish(ctx context.Context, data []byte) error {
+	_, err := p.topic.Publish(ctx, &pubsub.Message{Data: data}).Get(ctx)
+	return err
+}
+
+func (d *pubsubDeadLetterImpl) Send(ctx context.Context, dst string, cs primitive.M, cause error) error {
+	rt, r, err := newRecord(dst, cs, cause)
+	if err != nil {
+		return err
+	}
+	if err := d.publisher.publish(ctx, r); err != nil {
+		return errors.InternalServerErrorDeadLetterPut.Wrap("Failed to publish dead letter.", err)
+	}
+	d.Log.Warnf("Sent change streams to dead letter resumeToken:%s, destination:%s", rt, dst)
+	return nil
+}
+
+func (d *pubsubDeadLetterImpl) Close() error {
+	return d.publisher.close()
+}
+
+// New returns the DeadLetter set by DEAD_LETTER_TYPE, or nil if it is not set.
+func New(ctx context.Context, log *zap.SugaredLogger) (DeadLetter, error) {
+	cfg := dlConfig.DeadLetterConfig()
+
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case pubsubType:
+		// The topic is checked at startup, since a dead letter is sent only when exports fail.
+		if cfg.PubSubTopicName == "" {
+			return nil, errors.InternalServerErrorEnvGet.New("DEAD_LETTER_PUBSUB_TOPIC_NAME must be set for the pubsub dead letter.")
+		}
+		projectID, err := config.FetchGcpProject()
+		if err != nil {
+			return nil, err
+		}
+		psClient, err := client.NewPubsubClient(ctx, projectID)
+		if err != nil {
+			return nil, err
+		}
+		return &pubsubDeadLetterImpl{
+			Log:       log,
+			publisher: &pubsubPublisherImpl{client: psClient, topic: psClient.Topic(cfg.PubSubTopicName)},
+		}, nil
+	}
+
+	cli, err := storage.NewStorageClient(ctx, cfg.Type, cfg.Path, cfg.BucketName, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &storageDeadLetterImpl{
+		Log:    log,
+		client: cli,
+		path:   cfg.Path,
+	}, nil
+}
@@ -0,0 +1,209 @@
+//go:build test
+// +build test
+
+package dead_letter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config"
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"github.com/cam-inc/mxtransporter/pkg/logger"
+	mocks "github.com/cam-inc/mxtransporter/usecases/resume-token/mock"
+	"github.com/golang/mock/gomock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type mockPublisher struct {
+	data []byte
+	err  error
+}
+
+func (*mockPublisher) close() error {
+	return nil
+}
+
+func (m *mockPublisher) publish(_ context.Context, data []byte) error {
+	m.data = data
+	return m.err
+}
+
+func Test_New(t *testing.T) {
+	l := logger.New(config.LogConfig())
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Dead letter is disabled without DEAD_LETTER_TYPE.",
+			runner: func(t *testing.T) {
+				if err := os.Unsetenv(constant.DEAD_LETTER_TYPE); err != nil {
+					t.Fatalf("Failed to unset file DEAD_LETTER_TYPE environment variables.")
+				}
+				dl, err := New(ctx, l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if dl != nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to create file dead letter.",
+			runner: func(t *testing.T) {
+				if err := os.Setenv(constant.DEAD_LETTER_TYPE, "file"); err != nil {
+					t.Fatalf("Failed to set file DEAD_LETTER_TYPE environment variables.")
+				}
+				defer os.Unsetenv(constant.DEAD_LETTER_TYPE)
+				dl, err := New(ctx, l)
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if _, ok := dl.(*storageDeadLetterImpl); !ok {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed to fetch gcp project id for pubsub dead letter.",
+			runner: func(t *testing.T) {
+				if err := os.Setenv(constant.DEAD_LETTER_TYPE, "pubsub"); err != nil {
+					t.Fatalf("Failed to set file DEAD_LETTER_TYPE environment variables.")
+				}
+				defer os.Unsetenv(constant.DEAD_LETTER_TYPE)
+				if err := os.Setenv(constant.DEAD_LETTER_PUBSUB_TOPIC_NAME, "dead-letter"); err != nil {
+					t.Fatalf("Failed to set file DEAD_LETTER_PUBSUB_TOPIC_NAME environment variables.")
+				}
+				defer os.Unsetenv(constant.DEAD_LETTER_PUBSUB_TOPIC_NAME)
+				if err := os.Unsetenv(constant.PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS); err != nil {
+					t.Fatalf("Failed to unset file PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS environment variables.")
+				}
+				if _, err := New(ctx, l); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},		{
+			name: "Failed by an empty topic name for pubsub dead letter.",
+			runner: func(t *testing.T) {
+				if err := os.Setenv(constant.DEAD_LETTER_TYPE, "pubsub"); err != nil {
+					t.Fatalf("Failed to set file DEAD_LETTER_TYPE environment variables.")
+				}
+				defer os.Unsetenv(constant.DEAD_LETTER_TYPE)
+				if err := os.Unsetenv(constant.DEAD_LETTER_PUBSUB_TOPIC_NAME); err != nil {
+					t.Fatalf("Failed to unset file DEAD_LETTER_PUBSUB_TOPIC_NAME environment variables.")
+				}
+				if err := os.Setenv(constant.PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS, "test-project"); err != nil {
+					t.Fatalf("Failed to set file PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS environment variables.")
+				}
+				defer os.Unsetenv(constant.PROJECT_NAME_TO_EXPORT_CHANGE_STREAMS)
+				_, err := New(ctx, l)
+				if err == nil || !strings.Contains(err.Error(), constant.DEAD_LETTER_PUBSUB_TOPIC_NAME) {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
+func Test_Send(t *testing.T) {
+	l := logger.New(config.LogConfig())
+	ctx := context.Background()
+
+	rt := "00000"
+	cs := primitive.M{
+		"_id":           primitive.M{"_data": rt},
+		"operationType": "insert",
+		"fullDocument":  primitive.M{"count": 1},
+	}
+	cause := fmt.Errorf("rejected")
+
+	checkRecord := func(t *testing.T, r []byte) {
+		var got map[string]interface{}
+		if err := json.Unmarshal(r, &got); err != nil {
+			t.Fatalf("Dead letter record is not json, ErrorMessage: %v", err)
+		}
+		if got["destination"] != "bigquery" || got["error"] != "rejected" || got["resumeToken"] != rt {
+			t.Fatalf("Dead letter record is wrong, got: %v", got)
+		}
+		if got["changeStream"].(map[string]interface{})["operationType"] != "insert" {
+			t.Fatalf("Dead letter record does not contain the change stream, got: %v", got)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to put dead letter to storage.",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				mockCli := mocks.NewMockStorageClient(ctrl)
+
+				var got string
+				mockCli.EXPECT().PutObject(gomock.Any(), "dead-letter/bigquery_00000.json", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, value string) error {
+						got = value
+						return nil
+					})
+
+				dl := &storageDeadLetterImpl{Log: l, client: mockCli, path: "dead-letter/"}
+				if err := dl.Send(ctx, "bigquery", cs, cause); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				checkRecord(t, []byte(got))
+			},
+		},
+		{
+			name: "Failed to put dead letter to storage.",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+				mockCli := mocks.NewMockStorageClient(ctrl)
+				mockCli.EXPECT().PutObject(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("put error"))
+
+				dl := &storageDeadLetterImpl{Log: l, client: mockCli, path: "dead-letter"}
+				if err := dl.Send(ctx, "bigquery", cs, cause); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to publish dead letter to pubsub.",
+			runner: func(t *testing.T) {
+				p := &mockPublisher{}
+				dl := &pubsubDeadLetterImpl{Log: l, publisher: p}
+				if err := dl.Send(ctx, "bigquery", cs, cause); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				checkRecord(t, p.data)
+			},
+		},
+		{
+			name: "Failed to publish dead letter to pubsub.",
+			runner: func(t *testing.T) {
+				dl := &pubsubDeadLetterImpl{Log: l, publisher: &mockPublisher{err: fmt.Errorf("publish error")}}
+				if err := dl.Send(ctx, "bigquery", cs, cause); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
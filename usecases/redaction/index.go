@@ -0,0 +1,274 @@
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	rdConfig "github.com/cam-inc/mxtransporter/config/redaction"
+	"github.com/cam-inc/mxtransporter/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type action string
+
+const (
+	// drop removes the field.
+	drop action = "drop"
+	// replace replaces the value with the constant of the rule.
+	replace action = "replace"
+	// hash replaces the value with its HMAC-SHA256 by REDACTION_HMAC_KEY, so that equal values can still be joined.
+	hash action = "hash"
+	// mask replaces the characters of the value with the mask character, except the ones to keep at the both ends.
+	mask action = "mask"
+)
+
+const defaultMaskChar = "*"
+
+// Redactor redacts the fields of the documents in change streams before they are exported.
+type Redactor interface {
+	Redact(cs primitive.M)
+}
+
+type (
+	// rule redacts the fields whose dotted path is Field, or matches Pattern. Array indexes are not part of the path,
+	// so "phones" matches every element of the array and "contacts.email" matches email of every contact.
+	rule struct {
+		Field     string      `json:"field"`
+		Pattern   string      `json:"pattern"`
+		Action    action      `json:"action"`
+		Value     interface{} `json:"value"`
+		KeepFirst int         `json:"keepFirst"`
+		KeepLast  int         `json:"keepLast"`
+		MaskChar  string      `json:"maskChar"`
+		re        *regexp.Regexp
+	}
+
+	redactorImpl struct {
+		rules   []rule
+		hmacKey []byte
+	}
+)
+
+// New returns the Redactor of the rules in REDACTION_RULES or REDACTION_RULES_FILE, or nil if there are no rules.
+func New() (Redactor, error) {
+	cfg := rdConfig.RedactionConfig()
+	if cfg.Rules != "" && cfg.RulesFile != "" {
+		return nil, errors.InternalServerErrorEnvGet.New("Only one of REDACTION_RULES and REDACTION_RULES_FILE can be set.")
+	}
+	rules := cfg.Rules
+	if cfg.RulesFile != "" {
+		b, err := os.ReadFile(cfg.RulesFile)
+		if err != nil {
+			return nil, errors.InternalServerError.Wrap("Failed to read the redaction rules file.", err)
+		}
+		rules = string(b)
+	}
+	if rules == "" {
+		return nil, nil
+	}
+	return newRedactor(rules, cfg.HMACKey)
+}
+
+func newRedactor(rules string, hmacKey string) (*redactorImpl, error) {
+	r := &redactorImpl{hmacKey: []byte(hmacKey)}
+	if err := json.Unmarshal([]byte(rules), &r.rules); err != nil {
+		return nil, errors.InternalServerErrorEnvGet.Wrap("The redaction rules are not a JSON array of rules.", err)
+	}
+	for i := range r.rules {
+		if err := r.rules[i].validate(hmacKey); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *rule) validate(hmacKey string) error {
+	if (r.Field == "") == (r.Pattern == "") {
+		return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("A redaction rule must have either field or pattern. got %+v", *r))
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return errors.InternalServerErrorEnvGet.Wrap(fmt.Sprintf("The redaction pattern %s is wrong.", r.Pattern), err)
+		}
+		r.re = re
+	}
+	switch r.Action {
+	case drop, replace, mask:
+	case hash:
+		if hmacKey == "" {
+			return errors.InternalServerErrorEnvGet.New("REDACTION_HMAC_KEY must be set for the hash action.")
+		}
+	default:
+		return errors.InternalServerErrorEnvGet.New(fmt.Sprintf("The redaction action must be drop, replace, hash or mask. you set %s", r.Action))
+	}
+	if r.MaskChar == "" {
+		r.MaskChar = defaultMaskChar
+	}
+	return nil
+}
+
+func (r *rule) match(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	return path == r.Field || strings.HasPrefix(path, r.Field+".")
+}
+
+// Redact redacts fullDocument, fullDocumentBeforeChange, documentKey and updateDescription.updatedFields of cs in place.
+func (r *redactorImpl) Redact(cs primitive.M) {
+	for _, k := range []string{"fullDocument", "fullDocumentBeforeChange", "documentKey"} {
+		if doc, ok := cs[k].(primitive.M); ok {
+			r.redactDocument(doc, "")
+		}
+	}
+	if ud, ok := cs["updateDescription"].(primitive.M); ok {
+		// The keys of updatedFields are dotted paths, e.g. "address.zip", which are matched as they are.
+		if uf, ok := ud["updatedFields"].(primitive.M); ok {
+			r.redactDocument(uf, "")
+		}
+	}
+}
+
+func (r *redactorImpl) redactDocument(doc primitive.M, path string) {
+	for k, v := range doc {
+		p := joinPath(path, k)
+		rl := r.ruleOf(p)
+		switch {
+		case rl == nil:
+			doc[k] = r.redactValue(v, p)
+		case rl.Action == drop:
+			delete(doc, k)
+		default:
+			doc[k] = r.apply(rl, v)
+		}
+	}
+}
+
+// redactValue returns v with the fields in it redacted. Documents of primitive.M are redacted in place.
+func (r *redactorImpl) redactValue(v interface{}, path string) interface{} {
+	switch t := v.(type) {
+	case primitive.M:
+		r.redactDocument(t, path)
+	case primitive.D:
+		d := t[:0]
+		for _, e := range t {
+			p := joinPath(path, e.Key)
+			rl := r.ruleOf(p)
+			switch {
+			case rl == nil:
+				d = append(d, primitive.E{Key: e.Key, Value: r.redactValue(e.Value, p)})
+			case rl.Action != drop:
+				d = append(d, primitive.E{Key: e.Key, Value: r.apply(rl, e.Value)})
+			}
+		}
+		return d
+	case primitive.A:
+		for i, e := range t {
+			t[i] = r.redactValue(e, path)
+		}
+	}
+	return v
+}
+
+func (r *redactorImpl) ruleOf(path string) *rule {
+	path = normalizePath(path)
+	for i := range r.rules {
+		if r.rules[i].match(path) {
+			return &r.rules[i]
+		}
+	}
+	return nil
+}
+
+// apply returns the value redacted by rl. hash and mask apply to every value in documents and arrays.
+func (r *redactorImpl) apply(rl *rule, v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.M:
+		m := make(primitive.M, len(t))
+		for k, e := range t {
+			m[k] = r.apply(rl, e)
+		}
+		return m
+	case primitive.D:
+		d := make(primitive.D, len(t))
+		for i, e := range t {
+			d[i] = primitive.E{Key: e.Key, Value: r.apply(rl, e.Value)}
+		}
+		return d
+	case primitive.A:
+		a := make(primitive.A, len(t))
+		for i, e := range t {
+			a[i] = r.apply(rl, e)
+		}
+		return a
+	case nil:
+		return nil
+	}
+
+	switch rl.Action {
+	case replace:
+		return rl.Value
+	case hash:
+		mac := hmac.New(sha256.New, r.hmacKey)
+		mac.Write([]byte(leafString(v)))
+		return hex.EncodeToString(mac.Sum(nil))
+	case mask:
+		return maskString(leafString(v), rl.KeepFirst, rl.KeepLast, rl.MaskChar)
+	}
+	return v
+}
+
+func leafString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case primitive.ObjectID:
+		return t.Hex()
+	}
+	return fmt.Sprint(v)
+}
+
+// maskString keeps keepFirst and keepLast characters of s. s is masked entirely if it is not longer than them.
+func maskString(s string, keepFirst, keepLast int, maskChar string) string {
+	rs := []rune(s)
+	if len(rs) <= keepFirst+keepLast {
+		keepFirst, keepLast = 0, 0
+	}
+	var b strings.Builder
+	for i, c := range rs {
+		if i < keepFirst || i >= len(rs)-keepLast {
+			b.WriteRune(c)
+			continue
+		}
+		b.WriteString(maskChar)
+	}
+	return b.String()
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// normalizePath removes the array indexes, e.g. "phones.0" in updatedFields.
+func normalizePath(path string) string {
+	segs := strings.Split(path, ".")
+	kept := segs[:0]
+	for _, s := range segs {
+		if _, err := strconv.Atoi(s); err == nil {
+			continue
+		}
+		kept = append(kept, s)
+	}
+	return strings.Join(kept, ".")
+}
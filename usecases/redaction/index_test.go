@@ -0,0 +1,195 @@
+//go:build test
+// +build test
+
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cam-inc/mxtransporter/config/constant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_New(t *testing.T) {
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Redaction is disabled without rules.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.REDACTION_RULES, "")
+				t.Setenv(constant.REDACTION_RULES_FILE, "")
+				r, err := New()
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if r != nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Pass to read the rules file.",
+			runner: func(t *testing.T) {
+				f := filepath.Join(t.TempDir(), "redaction.json")
+				if err := os.WriteFile(f, []byte(`[{"field": "email", "action": "drop"}]`), 0664); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				t.Setenv(constant.REDACTION_RULES, "")
+				t.Setenv(constant.REDACTION_RULES_FILE, f)
+				r, err := New()
+				if err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if r == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+		{
+			name: "Failed by the rules which are wrong.",
+			runner: func(t *testing.T) {
+				for _, rules := range []string{
+					`{"field": "email", "action": "drop"}`,
+					`[{"action": "drop"}]`,
+					`[{"field": "email", "pattern": "mail", "action": "drop"}]`,
+					`[{"pattern": "(", "action": "drop"}]`,
+					`[{"field": "email", "action": "encrypt"}]`,
+					`[{"field": "email", "action": "hash"}]`,
+				} {
+					if _, err := newRedactor(rules, ""); err == nil {
+						t.Fatalf("Not behaving as intended. rules: %s", rules)
+					}
+				}
+			},
+		},
+		{
+			name: "Failed by both the rules and the rules file.",
+			runner: func(t *testing.T) {
+				t.Setenv(constant.REDACTION_RULES, `[]`)
+				t.Setenv(constant.REDACTION_RULES_FILE, "/etc/mxt/redaction.json")
+				if _, err := New(); err == nil {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	key := "secret"
+	hmacOf := func(s string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	r, err := newRedactor(`[
+		{"field": "email", "action": "hash"},
+		{"field": "password", "action": "drop"},
+		{"field": "address.zip", "action": "replace", "value": "***"},
+		{"pattern": "(^|\\.)phone$", "action": "mask", "keepLast": 4},
+		{"field": "contacts.name", "action": "mask", "keepFirst": 1, "maskChar": "#"}
+	]`, key)
+	if err != nil {
+		t.Fatalf("Testing Error, ErrorMessage: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		runner func(t *testing.T)
+	}{
+		{
+			name: "Pass to redact fullDocument, the pre-image and documentKey.",
+			runner: func(t *testing.T) {
+				doc := func() primitive.M {
+					return primitive.M{
+						"_id":      int32(1),
+						"email":    "user@example.com",
+						"password": "p@ss",
+						"address":  primitive.M{"city": "Tokyo", "zip": "1500001"},
+						"phone":    "09012345678",
+						"contacts": primitive.A{primitive.M{"name": "Alice", "phone": "0311112222"}},
+					}
+				}
+				cs := primitive.M{
+					"fullDocument":             doc(),
+					"fullDocumentBeforeChange": doc(),
+					"documentKey":              primitive.M{"_id": int32(1), "email": "user@example.com"},
+				}
+				r.Redact(cs)
+
+				want := primitive.M{
+					"_id":      int32(1),
+					"email":    hmacOf("user@example.com"),
+					"address":  primitive.M{"city": "Tokyo", "zip": "***"},
+					"phone":    "*******5678",
+					"contacts": primitive.A{primitive.M{"name": "A####", "phone": "******2222"}},
+				}
+				for _, k := range []string{"fullDocument", "fullDocumentBeforeChange"} {
+					if !reflect.DeepEqual(cs[k], want) {
+						t.Fatalf("Testing Error, ErrorMessage: %s is not redacted. want: %v, got: %v", k, want, cs[k])
+					}
+				}
+				if want := (primitive.M{"_id": int32(1), "email": hmacOf("user@example.com")}); !reflect.DeepEqual(cs["documentKey"], want) {
+					t.Fatalf("Testing Error, ErrorMessage: documentKey is not redacted. want: %v, got: %v", want, cs["documentKey"])
+				}
+			},
+		},
+		{
+			name: "Pass to redact the dotted paths of updatedFields.",
+			runner: func(t *testing.T) {
+				cs := primitive.M{
+					"updateDescription": primitive.M{
+						"updatedFields": primitive.M{
+							"address.zip":     "1500001",
+							"contacts.0.name": "Bob",
+							"password":        "p@ss",
+							"address":         primitive.D{{Key: "zip", Value: "1500002"}, {Key: "city", Value: "Osaka"}},
+						},
+						"removedFields": primitive.A{"email"},
+					},
+				}
+				r.Redact(cs)
+
+				want := primitive.M{
+					"updatedFields": primitive.M{
+						"address.zip":     "***",
+						"contacts.0.name": "B##",
+						"address":         primitive.D{{Key: "zip", Value: "***"}, {Key: "city", Value: "Osaka"}},
+					},
+					"removedFields": primitive.A{"email"},
+				}
+				if !reflect.DeepEqual(cs["updateDescription"], want) {
+					t.Fatalf("Testing Error, ErrorMessage: updateDescription is not redacted. want: %v, got: %v", want, cs["updateDescription"])
+				}
+			},
+		},
+		{
+			name: "Pass to hash the same value to the same string.",
+			runner: func(t *testing.T) {
+				a := primitive.M{"fullDocument": primitive.M{"email": "user@example.com"}}
+				b := primitive.M{"documentKey": primitive.M{"email": "user@example.com"}}
+				r.Redact(a)
+				r.Redact(b)
+				if a["fullDocument"].(primitive.M)["email"] != b["documentKey"].(primitive.M)["email"] {
+					t.Fatalf("Not behaving as intended.")
+				}
+			},
+		},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, v.runner)
+	}
+}
@@ -16,6 +16,14 @@ import (
 type ResumeToken interface {
 	ReadResumeToken(ctx context.Context) string
 	SaveResumeToken(ctx context.Context, rt string) error
+	// FlushResumeToken saves the resume token skipped by RESUME_TOKEN_SAVE_INTERVAL_SEC, if any.
+	FlushResumeToken(ctx context.Context) error
+	// ReadSnapshotCheckpoint returns the progress of the initial snapshot saved next to the resume token, or an empty string if none.
+	ReadSnapshotCheckpoint(ctx context.Context) string
+	SaveSnapshotCheckpoint(ctx context.Context, cp string) error
+	// ReadDestinationResumeToken returns the resume token up to which dst has exported, saved when there are multiple destinations.
+	ReadDestinationResumeToken(ctx context.Context, dst string) string
+	SaveDestinationResumeToken(ctx context.Context, dst string, rt string) error
 	Env() string
 }
 
@@ -27,7 +35,12 @@ type resumeTokenImpl struct {
 	tokenFileName   string
 	saveIntervalSec int
 	savedTimestamp  time.Time
-	lock            sync.Locker
+	pendingToken    string
+	lock            sync.Mutex
+	// The resume tokens of the destinations are saved by RESUME_TOKEN_SAVE_INTERVAL_SEC per destination as the resume token.
+	destinationLock    sync.Mutex
+	destinationSaved   map[string]time.Time
+	destinationPending map[string]string
 }
 
 func (r *resumeTokenImpl) ReadResumeToken(ctx context.Context) string {
@@ -43,18 +56,119 @@ func (r *resumeTokenImpl) ReadResumeToken(ctx context.Context) string {
 
 func (r *resumeTokenImpl) SaveResumeToken(ctx context.Context, rt string) error {
 	if !r.enableSave() {
+		r.setPendingToken(rt)
 		return nil
 	}
+	return r.putResumeToken(ctx, rt)
+}
+
+func (r *resumeTokenImpl) FlushResumeToken(ctx context.Context) error {
+	r.destinationLock.Lock()
+	pending := r.destinationPending
+	r.destinationPending = nil
+	r.destinationLock.Unlock()
+	for dst, rt := range pending {
+		if err := r.putDestinationResumeToken(ctx, dst, rt); err != nil {
+			return err
+		}
+	}
+
+	r.lock.Lock()
+	rt := r.pendingToken
+	r.lock.Unlock()
+	if rt == "" {
+		return nil
+	}
+	return r.putResumeToken(ctx, rt)
+}
+
+func (r *resumeTokenImpl) putResumeToken(ctx context.Context, rt string) error {
 	tmp := fmt.Sprintf("%s/%s", r.volumePath, r.tokenFileName)
 	filePath := path.Clean(tmp)
 	if err := r.client.PutObject(ctx, filePath, rt); err != nil {
 		r.Log.Errorf("Failed SaveResumeToken key:%s, err:%v", filePath, err)
 		return errors.InternalServerError.Wrap("Failed to SaveResumeToken", err)
 	}
+	r.setPendingToken("")
 	r.setSavedTimestamp()
 	return nil
 }
 
+func (r *resumeTokenImpl) ReadSnapshotCheckpoint(ctx context.Context) string {
+	filePath := r.snapshotCheckpointPath()
+	o, err := r.client.GetObject(ctx, filePath)
+	if err != nil {
+		r.Log.Infof("Failed ReadSnapshotCheckpoint key:%s, err:%v", filePath, err)
+		return ""
+	}
+	return string(o)
+}
+
+func (r *resumeTokenImpl) SaveSnapshotCheckpoint(ctx context.Context, cp string) error {
+	filePath := r.snapshotCheckpointPath()
+	if err := r.client.PutObject(ctx, filePath, cp); err != nil {
+		r.Log.Errorf("Failed SaveSnapshotCheckpoint key:%s, err:%v", filePath, err)
+		return errors.InternalServerError.Wrap("Failed to SaveSnapshotCheckpoint", err)
+	}
+	return nil
+}
+
+func (r *resumeTokenImpl) ReadDestinationResumeToken(ctx context.Context, dst string) string {
+	filePath := r.destinationResumeTokenPath(dst)
+	o, err := r.client.GetObject(ctx, filePath)
+	if err != nil {
+		r.Log.Infof("Failed ReadDestinationResumeToken key:%s, err:%v", filePath, err)
+		return ""
+	}
+	return string(o)
+}
+
+func (r *resumeTokenImpl) SaveDestinationResumeToken(ctx context.Context, dst string, rt string) error {
+	if !r.enableDestinationSave(dst) {
+		r.destinationLock.Lock()
+		defer r.destinationLock.Unlock()
+		if r.destinationPending == nil {
+			r.destinationPending = map[string]string{}
+		}
+		r.destinationPending[dst] = rt
+		return nil
+	}
+	return r.putDestinationResumeToken(ctx, dst, rt)
+}
+
+func (r *resumeTokenImpl) putDestinationResumeToken(ctx context.Context, dst string, rt string) error {
+	filePath := r.destinationResumeTokenPath(dst)
+	if err := r.client.PutObject(ctx, filePath, rt); err != nil {
+		r.Log.Errorf("Failed SaveDestinationResumeToken key:%s, err:%v", filePath, err)
+		return errors.InternalServerError.Wrap("Failed to SaveDestinationResumeToken", err)
+	}
+	r.destinationLock.Lock()
+	defer r.destinationLock.Unlock()
+	delete(r.destinationPending, dst)
+	if r.saveIntervalSec == 0 {
+		return nil
+	}
+	if r.destinationSaved == nil {
+		r.destinationSaved = map[string]time.Time{}
+	}
+	r.destinationSaved[dst] = time.Now()
+	return nil
+}
+
+func (r *resumeTokenImpl) destinationResumeTokenPath(dst string) string {
+	return path.Clean(fmt.Sprintf("%s/%s.%s", r.volumePath, r.tokenFileName, dst))
+}
+
+func (r *resumeTokenImpl) snapshotCheckpointPath() string {
+	return path.Clean(fmt.Sprintf("%s/%s.snapshot", r.volumePath, r.tokenFileName))
+}
+
+func (r *resumeTokenImpl) setPendingToken(rt string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.pendingToken = rt
+}
+
 func (r *resumeTokenImpl) setSavedTimestamp() {
 	if r.saveIntervalSec == 0 {
 		return
@@ -67,11 +181,23 @@ func (r *resumeTokenImpl) enableSave() bool {
 	if r.saveIntervalSec == 0 {
 		return true
 	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
 	t := r.savedTimestamp.Add(time.Duration(r.saveIntervalSec) * time.Second)
 	return t.Before(time.Now())
 }
 
-func (r resumeTokenImpl) Env() string {
+func (r *resumeTokenImpl) enableDestinationSave(dst string) bool {
+	if r.saveIntervalSec == 0 {
+		return true
+	}
+	r.destinationLock.Lock()
+	defer r.destinationLock.Unlock()
+	t := r.destinationSaved[dst].Add(time.Duration(r.saveIntervalSec) * time.Second)
+	return t.Before(time.Now())
+}
+
+func (r *resumeTokenImpl) Env() string {
 	return fmt.Sprintf(`{"volumeType":"%s","volume_path":"%s", "file_name":"%s", "intaval":"%d"}`, r.volumeType, r.volumePath, r.tokenFileName, r.saveIntervalSec)
 }
 
@@ -89,14 +215,12 @@ func New(ctx context.Context, log *zap.SugaredLogger) (ResumeToken, error) {
 		return nil, err
 	}
 
-	mu := &sync.RWMutex{}
 	return &resumeTokenImpl{
 		Log:             log,
 		volumeType:      cfg.VolumeType,
 		volumePath:      cfg.Path,
 		tokenFileName:   fileName,
 		saveIntervalSec: cfg.SaveIntervalSec,
-		lock:            mu.RLocker(),
 		client:          cli,
 	}, nil
 }
@@ -519,6 +519,149 @@ func Test_SaveResumeToken(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Flush skipped resume token",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				cli := mocks.NewMockStorageClient(ctrl)
+				resumeToken := &resumeTokenImpl{
+					Log:             l,
+					client:          cli,
+					volumePath:      "mydir",
+					tokenFileName:   "test.dat",
+					saveIntervalSec: 10,
+				}
+
+				skipped := "00001"
+				gomock.InOrder(
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat", rt).Return(nil).Times(1),
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat", skipped).Return(nil).Times(1),
+				)
+
+				if err := resumeToken.SaveResumeToken(ctx, rt); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := resumeToken.SaveResumeToken(ctx, skipped); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := resumeToken.FlushResumeToken(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				// Nothing is pending after the flush.
+				if err := resumeToken.FlushResumeToken(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Flush skipped resume token of each destination",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				cli := mocks.NewMockStorageClient(ctrl)
+				resumeToken := &resumeTokenImpl{
+					Log:             l,
+					client:          cli,
+					volumePath:      "mydir",
+					tokenFileName:   "test.dat",
+					saveIntervalSec: 10,
+				}
+
+				skipped := "00002"
+				gomock.InOrder(
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat.pubsub", rt).Return(nil).Times(1),
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat.bigquery", rt).Return(nil).Times(1),
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat.pubsub", skipped).Return(nil).Times(1),
+				)
+
+				// Each destination is saved at first, and then skipped within the interval.
+				for _, dst := range []string{"pubsub", "bigquery"} {
+					if err := resumeToken.SaveDestinationResumeToken(ctx, dst, rt); err != nil {
+						t.Fatalf("Testing Error, ErrorMessage: %v", err)
+					}
+				}
+				if err := resumeToken.SaveDestinationResumeToken(ctx, "pubsub", "00001"); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := resumeToken.SaveDestinationResumeToken(ctx, "pubsub", skipped); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if err := resumeToken.FlushResumeToken(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				// Nothing is pending after the flush.
+				if err := resumeToken.FlushResumeToken(ctx); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+			},
+		},
+		{
+			name: "Save and read snapshot checkpoint next to resume token",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				cli := mocks.NewMockStorageClient(ctrl)
+				resumeToken := &resumeTokenImpl{
+					Log:           l,
+					client:        cli,
+					volumePath:    "mydir",
+					tokenFileName: "test.dat",
+				}
+
+				cp := `{"completed":true}`
+				gomock.InOrder(
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat.snapshot", cp).Return(nil).Times(1),
+					cli.EXPECT().GetObject(ctx, "mydir/test.dat.snapshot").Return([]byte(cp), nil).Times(1),
+					cli.EXPECT().GetObject(ctx, "mydir/test.dat.snapshot").Return(nil, fmt.Errorf("not found")).Times(1),
+				)
+
+				if err := resumeToken.SaveSnapshotCheckpoint(ctx, cp); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got := resumeToken.ReadSnapshotCheckpoint(ctx); got != cp {
+					t.Fatalf("Not behaving as intended. got: %s", got)
+				}
+				if got := resumeToken.ReadSnapshotCheckpoint(ctx); got != "" {
+					t.Fatalf("Not behaving as intended. got: %s", got)
+				}
+			},
+		},
+		{
+			name: "Save and read resume token of each destination next to resume token",
+			runner: func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				cli := mocks.NewMockStorageClient(ctrl)
+				resumeToken := &resumeTokenImpl{
+					Log:           l,
+					client:        cli,
+					volumePath:    "mydir",
+					tokenFileName: "test.dat",
+				}
+
+				rt := "00001"
+				gomock.InOrder(
+					cli.EXPECT().PutObject(ctx, "mydir/test.dat.pubsub", rt).Return(nil).Times(1),
+					cli.EXPECT().GetObject(ctx, "mydir/test.dat.pubsub").Return([]byte(rt), nil).Times(1),
+					cli.EXPECT().GetObject(ctx, "mydir/test.dat.bigquery").Return(nil, fmt.Errorf("not found")).Times(1),
+				)
+
+				if err := resumeToken.SaveDestinationResumeToken(ctx, "pubsub", rt); err != nil {
+					t.Fatalf("Testing Error, ErrorMessage: %v", err)
+				}
+				if got := resumeToken.ReadDestinationResumeToken(ctx, "pubsub"); got != rt {
+					t.Fatalf("Not behaving as intended. got: %s", got)
+				}
+				if got := resumeToken.ReadDestinationResumeToken(ctx, "bigquery"); got != "" {
+					t.Fatalf("Not behaving as intended. got: %s", got)
+				}
+			},
+		},
 	}
 
 	for _, v := range tests {